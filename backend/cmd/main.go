@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strings"
@@ -12,8 +13,12 @@ import (
 	"oil-gas-service-booking/internal/config"
 	"oil-gas-service-booking/internal/http-server/handlers"
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/policy"
 	"oil-gas-service-booking/internal/http-server/repository"
 	"oil-gas-service-booking/internal/http-server/router"
+	"oil-gas-service-booking/internal/jobs"
+	"oil-gas-service-booking/internal/mailer"
+	"oil-gas-service-booking/internal/models"
 	"oil-gas-service-booking/internal/storage"
 
 	docs "oil-gas-service-booking/docs"
@@ -27,13 +32,32 @@ import (
 func main() {
 	cfg := config.MustLoad()
 
-	authmw.SetJWTSecret(cfg.JWTSecret)
+	if err := setupSigningKeys(cfg); err != nil {
+		log.Fatalf("Ошибка ключей подписи: %v", err)
+	}
+	authmw.SetAccessTokenTTL(cfg.Tokens.AccessTTL)
+	models.SetVATRate(cfg.VATRate)
 
 	db, err := storage.NewGorm(cfg.Storage)
 	if err != nil {
 		log.Fatalf("Ошибка базы данных: %v", err)
 	}
 
+	mail, err := mailer.New(mailer.Config{
+		Driver:    cfg.Mail.Driver,
+		From:      cfg.Mail.From,
+		OutboxDir: cfg.Mail.OutboxDir,
+		SMTP: mailer.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+		},
+	})
+	if err != nil {
+		log.Fatalf("Ошибка почты: %v", err)
+	}
+
 	companyRepo := repository.NewCompanyRepository(db)
 	userRepo := repository.NewUserRepo(db)
 	bookingRepo := repository.NewBookingRepo(db)
@@ -41,20 +65,59 @@ func main() {
 	businessRepo := repository.NewBusinessRepo(db)
 	bookingServiceRepo := repository.NewBookingServiceRepo(db)
 	companyServiceRepo := repository.NewCompanyServiceRepo(db)
+	quoteRepo := repository.NewQuoteRepo(db)
+	matchingRepo := repository.NewMatchingRepo(db)
+	tokenRepo := repository.NewTokenRepo(db, cfg.Tokens.RefreshTTL)
+	companyMemberRepo := repository.NewCompanyMemberRepo(db)
+	customerOrgRepo := repository.NewCustomerOrgRepo(db)
+	mfaRepo := repository.NewMFARepo(db)
+	apiKeyRepo := repository.NewAPIKeyRepo(db)
+	privacyRepo := repository.NewPrivacyRepo(db)
+	impersonationRepo := repository.NewImpersonationRepo(db)
+	securityRepo := repository.NewSecurityRepo(db, repository.LoginGuard{
+		FreeAttempts:     cfg.LoginGuard.FreeAttempts,
+		BaseDelay:        cfg.LoginGuard.BaseDelay,
+		MaxDelay:         cfg.LoginGuard.MaxDelay,
+		AccountLockAfter: cfg.LoginGuard.AccountLockAfter,
+		IPLockAfter:      cfg.LoginGuard.IPLockAfter,
+		LockDuration:     cfg.LoginGuard.LockDuration,
+		Window:           cfg.LoginGuard.Window,
+	})
+	accessPolicy := policy.New(db)
+
+	authmw.SetTokenStore(tokenRepo)
+	authmw.SetAPIKeyStore(apiKeyRepo)
+	authmw.SetImpersonationAuditor(impersonationRepo)
 
 	uploadsDir := "./uploads"
 
-	companyHandler := handlers.NewCompanyHandler(companyRepo)
-	userHandler := handlers.NewUserHandler(userRepo)
-	bookingHandler := handlers.NewBookingHandler(bookingRepo, db)
+	companyHandler := handlers.NewCompanyHandler(companyRepo, accessPolicy, db)
+	userHandler := handlers.NewUserHandler(userRepo, securityRepo, mail, uploadsDir)
+	bookingHandler := handlers.NewBookingHandler(bookingRepo, companyServiceRepo, db, accessPolicy, customerOrgRepo)
 	serviceHandler := handlers.NewServiceHandler(serviceRepo, serviceRepo, companyRepo, companyServiceRepo)
 	businessHandler := handlers.NewBusinessHandler(businessRepo)
-	authHandler := handlers.NewAuthHandler(db)
-	bookingServiceHandler := handlers.NewBookingServiceHandler(bookingServiceRepo, db)
-	companyServiceHandler := handlers.NewCompanyServiceHandler(companyServiceRepo, companyRepo)
-	uploadHandler := handlers.NewUploadHandler(db, uploadsDir)
-	serviceRequestHandler := handlers.NewServiceRequestHandler(db)
-	notificationHandler := handlers.NewNotificationHandler(db)
+	authHandler := handlers.NewAuthHandler(db, tokenRepo, mail, handlers.EmailLinks{
+		AppURL:    cfg.AppURL,
+		ResetTTL:  cfg.Tokens.PasswordResetTTL,
+		VerifyTTL: cfg.Tokens.EmailVerifyTTL,
+	}, securityRepo, mfaRepo)
+	bookingServiceHandler := handlers.NewBookingServiceHandler(bookingServiceRepo, bookingRepo, companyServiceRepo, db, accessPolicy, customerOrgRepo)
+	companyServiceHandler := handlers.NewCompanyServiceHandler(companyServiceRepo, companyRepo, accessPolicy)
+	uploadHandler := handlers.NewUploadHandler(db, uploadsDir, accessPolicy)
+	serviceRequestHandler := handlers.NewServiceRequestHandler(db, bookingRepo, matchingRepo, accessPolicy, customerOrgRepo, companyServiceRepo)
+	notificationHandler := handlers.NewNotificationHandler(accessPolicy)
+	jwksHandler := handlers.NewJWKSHandler()
+	bookingQuoteHandler := handlers.NewBookingQuoteHandler(quoteRepo, bookingRepo, db, accessPolicy, customerOrgRepo)
+	companyMemberHandler := handlers.NewCompanyMemberHandler(companyMemberRepo, companyRepo, db, accessPolicy, mail, cfg.AppURL)
+	customerOrgHandler := handlers.NewCustomerOrgHandler(customerOrgRepo, bookingRepo, companyServiceRepo, db, accessPolicy, mail, cfg.AppURL)
+	securityHandler := handlers.NewSecurityHandler(securityRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo, companyRepo, securityRepo, db, accessPolicy)
+	privacyHandler := handlers.NewPrivacyHandler(privacyRepo, userRepo, securityRepo, uploadsDir, cfg.Privacy.ErasureGrace)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationRepo, userRepo, tokenRepo, securityRepo, cfg.Tokens.ImpersonationTTL)
+	sessionHandler := handlers.NewSessionHandler(tokenRepo, securityRepo)
+
+	eraser := jobs.NewEraser(privacyRepo, userRepo, securityRepo, mail, uploadsDir)
+	go eraser.Run(context.Background(), cfg.Privacy.ErasureInterval)
 
 	r := router.NewRouter(
 		companyHandler,
@@ -69,6 +132,15 @@ func main() {
 		uploadsDir,
 		serviceRequestHandler,
 		notificationHandler,
+		bookingQuoteHandler,
+		jwksHandler,
+		companyMemberHandler,
+		customerOrgHandler,
+		securityHandler,
+		apiKeyHandler,
+		privacyHandler,
+		impersonationHandler,
+		sessionHandler,
 	)
 
 	host := cfg.HTTPServer.Address
@@ -90,3 +162,21 @@ func main() {
 		log.Fatalf("Ошибка запуска сервера: %v", err)
 	}
 }
+
+// setupSigningKeys собирает набор ключей JWT: асимметричные ключи из конфига
+// и, если задан, общий секрет HS256 — для старых токенов без kid и для
+// local-окружения без ключей.
+func setupSigningKeys(cfg *config.Config) error {
+	var keys []authmw.SigningKey
+	if cfg.JWTSecret != "" {
+		keys = append(keys, authmw.NewHMACKey(authmw.HMACKeyID, []byte(cfg.JWTSecret)))
+	}
+	for _, k := range cfg.JWTKeys {
+		key, err := authmw.LoadSigningKey(k.KID, k.Algorithm, k.PrivateKeyFile, k.PublicKeyFile)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	return authmw.SetSigningKeys(keys, cfg.JWTActiveKID)
+}
@@ -9,11 +9,80 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+const (
+	EnvLocal = "local"
+	// DefaultJWTSecret допустим только в local-окружении.
+	DefaultJWTSecret = "secret"
+)
+
 type Config struct {
-	Env        string `yaml:"env" env-default:"local"`
-	Storage    string `yaml:"storage_path" env-required:"true"`
-	JWTSecret  string `yaml:"jwt_secret" env:"JWT_SECRET" env-default:"secret"`
+	Env          string   `yaml:"env" env-default:"local"`
+	Storage      string   `yaml:"storage_path" env-required:"true"`
+	JWTSecret    string   `yaml:"jwt_secret" env:"JWT_SECRET" env-default:"secret"`
+	JWTKeys      []JWTKey `yaml:"jwt_keys"`
+	JWTActiveKID string   `yaml:"jwt_active_kid" env:"JWT_ACTIVE_KID"`
+	VATRate      float64  `yaml:"vat_rate" env:"VAT_RATE" env-default:"0.22"`
+	// AppURL — адрес фронтенда, на который ведут ссылки из писем.
+	AppURL     string `yaml:"app_url" env:"APP_URL" env-default:"http://localhost:5173"`
+	Tokens     `yaml:"tokens"`
 	HTTPServer `yaml:"http_server"`
+	Mail       `yaml:"mail"`
+	LoginGuard `yaml:"login_guard"`
+	Privacy    `yaml:"privacy"`
+}
+
+// LoginGuard — защита /auth/login от перебора паролей. Первые free_attempts
+// неудач проходят без паузы, дальше пауза удваивается от base_delay до
+// max_delay; после account_lock_after (ip_lock_after) неудач учётная запись
+// (IP) блокируется на lock_duration. Счётчик обнуляется через window без неудач.
+type LoginGuard struct {
+	FreeAttempts     int           `yaml:"free_attempts" env-default:"3"`
+	BaseDelay        time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay         time.Duration `yaml:"max_delay" env-default:"5m"`
+	AccountLockAfter int           `yaml:"account_lock_after" env-default:"10"`
+	IPLockAfter      int           `yaml:"ip_lock_after" env-default:"50"`
+	LockDuration     time.Duration `yaml:"lock_duration" env-default:"15m"`
+	Window           time.Duration `yaml:"window" env-default:"1h"`
+}
+
+// Privacy — удаление персональных данных по запросу пользователя: запрос
+// исполняется не раньше erasure_grace (до этого его можно отменить), очередь
+// проверяется раз в erasure_interval.
+type Privacy struct {
+	ErasureGrace    time.Duration `yaml:"erasure_grace" env:"ERASURE_GRACE" env-default:"72h"`
+	ErasureInterval time.Duration `yaml:"erasure_interval" env:"ERASURE_INTERVAL" env-default:"1h"`
+}
+
+// Mail — доставка писем. Драйвер file складывает письма в outbox_dir
+// вместо отправки и подходит для разработки и тестов.
+type Mail struct {
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER" env-default:"file"`
+	From         string `yaml:"from" env:"MAIL_FROM" env-default:"no-reply@oilgas.local"`
+	OutboxDir    string `yaml:"outbox_dir" env:"MAIL_OUTBOX_DIR" env-default:"./outbox"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+}
+
+// JWTKey — асимметричный ключ подписи токенов. При ротации новый ключ
+// добавляется и становится активным (jwt_active_kid), а старый остаётся в
+// списке, пока не истекут подписанные им токены; закрытая часть ему уже не нужна.
+type JWTKey struct {
+	KID            string `yaml:"kid"`
+	Algorithm      string `yaml:"alg"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+type Tokens struct {
+	AccessTTL  time.Duration `yaml:"access_ttl" env:"ACCESS_TOKEN_TTL" env-default:"15m"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"REFRESH_TOKEN_TTL" env-default:"720h"`
+	// PasswordResetTTL и EmailVerifyTTL — сроки одноразовых ссылок из писем.
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env:"PASSWORD_RESET_TTL" env-default:"1h"`
+	EmailVerifyTTL   time.Duration `yaml:"email_verify_ttl" env:"EMAIL_VERIFY_TTL" env-default:"48h"`
+	// ImpersonationTTL — срок токена входа администратора от имени пользователя.
+	ImpersonationTTL time.Duration `yaml:"impersonation_ttl" env:"IMPERSONATION_TTL" env-default:"30m"`
 }
 
 type HTTPServer struct {
@@ -43,6 +112,15 @@ func MustLoad() *Config {
 		log.Fatalf("не получилось прочитать congig: %s", err)
 	}
 
+	if cfg.Env != EnvLocal && cfg.JWTSecret == DefaultJWTSecret {
+		if len(cfg.JWTKeys) == 0 {
+			log.Fatalf("jwt_secret по умолчанию запрещён в окружении %q: задайте JWT_SECRET или jwt_keys", cfg.Env)
+		}
+		// Подпись идёт асимметричными ключами, а HS256 с известным секретом
+		// не должен приниматься даже для проверки.
+		cfg.JWTSecret = ""
+	}
+
 	return &cfg
 
 }
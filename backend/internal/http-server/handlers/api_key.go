@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+const (
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+	maxAPIKeyName     = 100
+)
+
+// APIKeyHandler — личные ключи пользователя, ключи компаний и их просмотр
+// администратором.
+type APIKeyHandler struct {
+	repo        *repository.APIKeyRepo
+	companyRepo *repository.CompanyRepository
+	security    *repository.SecurityRepo
+	db          *gorm.DB
+	policy      *policy.Policy
+}
+
+func NewAPIKeyHandler(
+	repo *repository.APIKeyRepo,
+	companyRepo *repository.CompanyRepository,
+	security *repository.SecurityRepo,
+	db *gorm.DB,
+	policy *policy.Policy,
+) *APIKeyHandler {
+	return &APIKeyHandler{repo: repo, companyRepo: companyRepo, security: security, db: db, policy: policy}
+}
+
+// APIKeyResponse — ключ без секрета. Key заполнен только в ответе на
+// создание: позже получить ключ целиком нельзя.
+type APIKeyResponse struct {
+	models.APIKey
+	Scopes []string `json:"scopes"`
+	Status string   `json:"status"`
+	Key    string   `json:"key,omitempty"`
+}
+
+func apiKeyView(k models.APIKey, raw string) APIKeyResponse {
+	status := "active"
+	switch {
+	case k.RevokedAt != nil:
+		status = "revoked"
+	case !k.ExpiresAt.After(time.Now()):
+		status = "expired"
+	}
+	return APIKeyResponse{APIKey: k, Scopes: k.ScopeList(), Status: status, Key: raw}
+}
+
+func apiKeyViews(list []models.APIKey) []APIKeyResponse {
+	resp := make([]APIKeyResponse, 0, len(list))
+	for _, k := range list {
+		resp = append(resp, apiKeyView(k, ""))
+	}
+	return resp
+}
+
+type createAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// ListMine — личные ключи пользователя.
+func (h *APIKeyHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	list, err := h.repo.ListPersonal(subject.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeyViews(list))
+}
+
+// CreateMine выпускает личный ключ.
+func (h *APIKeyHandler) CreateMine(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.create(w, r, subject, subject.UserID, nil)
+}
+
+// RevokeMine отзывает личный ключ пользователя.
+func (h *APIKeyHandler) RevokeMine(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	key, ok := h.loadKey(w, r, "id")
+	if !ok {
+		return
+	}
+	if key.CompanyID != nil || key.UserID != subject.UserID {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+	h.revoke(w, r, subject, key)
+}
+
+// ListCompany — ключи компании для её владельца и менеджеров.
+func (h *APIKeyHandler) ListCompany(w http.ResponseWriter, r *http.Request) {
+	company, _, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.repo.ListByCompany(company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeyViews(list))
+}
+
+// CreateCompany выпускает ключ компании. Ключ действует от имени создателя;
+// если его создаёт администратор, не состоящий в компании, — от имени
+// основного владельца.
+func (h *APIKeyHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
+	company, subject, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+	var count int64
+	err := h.db.Model(&models.CompanyMember{}).
+		Where("company_id = ? AND user_id = ? AND role IN ?", company.CompanyID, subject.UserID, models.MemberManageRoles).
+		Count(&count).Error
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ownerID := subject.UserID
+	if count == 0 {
+		ownerID = company.UserID
+	}
+	h.create(w, r, subject, ownerID, &company.CompanyID)
+}
+
+// RevokeCompany отзывает ключ компании.
+func (h *APIKeyHandler) RevokeCompany(w http.ResponseWriter, r *http.Request) {
+	company, subject, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+	key, ok := h.loadKey(w, r, "keyId")
+	if !ok {
+		return
+	}
+	if key.CompanyID == nil || *key.CompanyID != company.CompanyID {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+	h.revoke(w, r, subject, key)
+}
+
+// List — все ключи для администратора. Фильтры: user_id, company_id,
+// active=true.
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filter repository.APIKeyFilter
+	for name, dst := range map[string]**int64{"user_id": &filter.UserID, "company_id": &filter.CompanyID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = &id
+		}
+	}
+	filter.ActiveOnly = q.Get("active") == "true"
+
+	list, err := h.repo.List(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeyViews(list))
+}
+
+// CreateForUser — администратор выпускает личный ключ пользователю.
+func (h *APIKeyHandler) CreateForUser(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	h.create(w, r, subject, userID, nil)
+}
+
+// Revoke — администратор отзывает любой ключ.
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	key, ok := h.loadKey(w, r, "id")
+	if !ok {
+		return
+	}
+	h.revoke(w, r, subject, key)
+}
+
+// create проверяет запрос и выпускает ключ, действующий от имени ownerID.
+// Области должны иметь смысл для роли владельца.
+func (h *APIKeyHandler) create(w http.ResponseWriter, r *http.Request, subject policy.Subject, ownerID int64, companyID *int64) {
+	var in createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len([]rune(in.Name)) > maxAPIKeyName {
+		http.Error(w, "name is required (up to 100 characters)", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case in.ExpiresInDays == 0:
+		in.ExpiresInDays = defaultAPIKeyDays
+	case in.ExpiresInDays < 0 || in.ExpiresInDays > maxAPIKeyDays:
+		http.Error(w, "expires_in_days must be between 1 and 365", http.StatusBadRequest)
+		return
+	}
+
+	var owner models.User
+	if err := h.db.First(&owner, ownerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(in.Scopes) == 0 {
+		http.Error(w, "at least one scope is required", http.StatusBadRequest)
+		return
+	}
+	seen := map[string]bool{}
+	scopes := make([]string, 0, len(in.Scopes))
+	for _, s := range in.Scopes {
+		if !models.IsValidAPIKeyScope(s) {
+			http.Error(w, "unknown scope: "+s, http.StatusBadRequest)
+			return
+		}
+		if !models.RoleAllowsAPIKeyScope(owner.Role, s) {
+			http.Error(w, "scope "+s+" is not available for role "+owner.Role, http.StatusForbidden)
+			return
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	key := &models.APIKey{
+		UserID:          owner.UserID,
+		CompanyID:       companyID,
+		Name:            in.Name,
+		ExpiresAt:       time.Now().UTC().Add(time.Duration(in.ExpiresInDays) * 24 * time.Hour),
+		CreatedByUserID: subject.UserID,
+	}
+	raw, err := h.repo.Create(key, scopes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logEvent(r, models.SecurityEventAPIKeyCreated, owner.UserID, subject.UserID, key)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyView(*key, raw))
+}
+
+func (h *APIKeyHandler) revoke(w http.ResponseWriter, r *http.Request, subject policy.Subject, key *models.APIKey) {
+	revoked, err := h.repo.Revoke(key.KeyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		h.logEvent(r, models.SecurityEventAPIKeyRevoked, key.UserID, subject.UserID, key)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIKeyHandler) loadKey(w http.ResponseWriter, r *http.Request, param string) (*models.APIKey, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+	key, err := h.repo.Get(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "api key not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return key, true
+}
+
+// managedCompany загружает компанию из URL и проверяет право управлять ею.
+func (h *APIKeyHandler) managedCompany(w http.ResponseWriter, r *http.Request) (*models.Company, policy.Subject, bool) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, subject, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, subject, false
+	}
+	company, err := h.companyRepo.GetByID(id)
+	if err != nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return nil, subject, false
+	}
+	allowed, err := h.policy.CanManageCompany(subject, company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, subject, false
+	}
+	if !allowed {
+		http.Error(w, "forbidden: only the company owner or a manager can manage its API keys", http.StatusForbidden)
+		return nil, subject, false
+	}
+	return company, subject, true
+}
+
+// logEvent записывает выпуск или отзыв ключа; Detail — номер, префикс и
+// области ключа.
+func (h *APIKeyHandler) logEvent(r *http.Request, eventType string, userID, actorID int64, key *models.APIKey) {
+	detail := "key " + strconv.FormatInt(key.KeyID, 10) + " " + key.Prefix + " scopes=" + key.Scopes
+	if key.CompanyID != nil {
+		detail += " company=" + strconv.FormatInt(*key.CompanyID, 10)
+	}
+	if err := h.security.LogEvent(securityEvent(r, eventType, &userID, "", &actorID, detail)); err != nil {
+		log.Printf("api keys: не удалось записать событие %s: %v", eventType, err)
+	}
+}
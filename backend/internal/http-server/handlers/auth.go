@@ -1,15 +1,29 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
+	"net"
 	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/mailer"
 	"oil-gas-service-booking/internal/models"
 )
 
+// minPasswordLength — минимальная длина нового пароля при сбросе и смене.
+const minPasswordLength = 8
+
 type RegisterRequest struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
@@ -22,22 +36,101 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type TokenResponse struct {
-	Token string `json:"token"`
-	Role  string `json:"role"`
-	User  struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Role         string `json:"role"`
+	User         struct {
 		ID    int64  `json:"id"`
 		Email string `json:"email"`
 		Role  string `json:"role"`
 	} `json:"user"`
+	// RecoveryCodes — резервные коды, если вход завершил подключение 2FA.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// EmailLinks — адрес фронтенда и сроки действия ссылок из писем.
+type EmailLinks struct {
+	AppURL    string
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
 }
 
 type AuthHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	tokens   *repository.TokenRepo
+	mailer   mailer.Mailer
+	links    EmailLinks
+	security *repository.SecurityRepo
+	mfa      *repository.MFARepo
 }
 
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(
+	db *gorm.DB,
+	tokens *repository.TokenRepo,
+	m mailer.Mailer,
+	links EmailLinks,
+	security *repository.SecurityRepo,
+	mfa *repository.MFARepo,
+) *AuthHandler {
+	links.AppURL = strings.TrimRight(links.AppURL, "/")
+	return &AuthHandler{db: db, tokens: tokens, mailer: m, links: links, security: security, mfa: mfa}
+}
+
+// writeTokens выдаёт пару access/refresh для пользователя. refreshToken —
+// уже выпущенный refresh-токен сеанса sessionID (при ротации); если пуст,
+// начинается новый сеанс.
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, r *http.Request, user *models.User, refreshToken string, sessionID int64, status int) {
+	response, err := h.issueTokens(r, user, refreshToken, sessionID)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *AuthHandler) issueTokens(r *http.Request, user *models.User, refreshToken string, sessionID int64) (*TokenResponse, error) {
+	if refreshToken == "" {
+		var err error
+		if refreshToken, sessionID, err = h.tokens.StartSession(user.UserID, clientIP(r), r.UserAgent()); err != nil {
+			return nil, err
+		}
+	}
+	token, err := authmw.GenerateToken(user.UserID, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(authmw.AccessTokenTTL().Seconds()),
+		Role:         user.Role,
+	}
+	response.User.ID = user.UserID
+	response.User.Role = user.Role
+	if user.Email != nil {
+		response.User.Email = *user.Email
+	}
+	return response, nil
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -64,7 +157,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role := "customer"
+	role := models.RoleCustomer
 
 	user := models.User{
 		Name:     in.Name,
@@ -78,31 +171,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := authmw.GenerateToken(user.UserID, user.Role)
-	if err != nil {
-		http.Error(w, "failed to generate token", http.StatusInternalServerError)
-		return
-	}
+	h.sendVerification(r.Context(), &user, in.Email)
 
-	response := TokenResponse{
-		Token: token,
-		Role:  user.Role,
-		User: struct {
-			ID    int64  `json:"id"`
-			Email string `json:"email"`
-			Role  string `json:"role"`
-		}{
-			ID:    user.UserID,
-			Email: *user.Email,
-			Role:  user.Role,
-		},
-	}
-
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.writeTokens(w, r, &user, "", 0, http.StatusCreated)
 }
 
+// Login проверяет пароль. Частые неудачи с одного IP или в одну учётную
+// запись замедляются и временно блокируются ещё до проверки пароля; каждая
+// попытка попадает в журнал безопасности.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var in LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
@@ -114,39 +190,207 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+	block, err := h.security.CheckLogin(ip, in.Email)
+	if err != nil {
+		http.Error(w, "failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if block != nil {
+		h.logSecurity(r, models.SecurityEventLoginThrottled, nil, in.Email, nil, block.Scope)
+		writeLoginBlock(w, block)
+		return
+	}
+
 	var user models.User
 	if err := h.db.Where("email = ?", in.Email).First(&user).Error; err != nil {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		h.loginFailed(w, r, nil, in.Email, "unknown email")
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.Password)); err != nil {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		h.loginFailed(w, r, &user.UserID, in.Email, "wrong password")
+		return
+	}
+	// О приостановке сообщаем только после верного пароля, чтобы по ответу
+	// нельзя было узнать статус чужой учётной записи.
+	if user.SuspendedAt != nil {
+		h.logSecurity(r, models.SecurityEventLoginFailed, &user.UserID, in.Email, nil, "account suspended")
+		http.Error(w, "account suspended, contact the administrator", http.StatusForbidden)
 		return
 	}
 
-	token, err := authmw.GenerateToken(user.UserID, user.Role)
+	if err := h.security.RecordLoginSuccess(in.Email); err != nil {
+		log.Printf("auth: не удалось сбросить счётчик входов: %v", err)
+	}
+
+	enabled, err := h.mfa.Enabled(user.UserID)
 	if err != nil {
-		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		http.Error(w, "failed to check two-factor authentication", http.StatusInternalServerError)
 		return
 	}
+	if enabled || models.RoleRequiresMFA(user.Role) {
+		h.startMFA(w, r, &user, !enabled)
+		return
+	}
+
+	h.logSecurity(r, models.SecurityEventLoginSucceeded, &user.UserID, in.Email, nil, "")
+
+	h.writeTokens(w, r, &user, "", 0, http.StatusOK)
+}
 
-	response := TokenResponse{
-		Token: token,
-		Role:  user.Role,
-		User: struct {
-			ID    int64  `json:"id"`
-			Email string `json:"email"`
-			Role  string `json:"role"`
-		}{
-			ID:    user.UserID,
-			Email: *user.Email,
-			Role:  user.Role,
-		},
+func (h *AuthHandler) loginFailed(w http.ResponseWriter, r *http.Request, userID *int64, email, reason string) {
+	locked, err := h.security.RecordLoginFailure(clientIP(r), email)
+	if err != nil {
+		log.Printf("auth: не удалось учесть неудачный вход: %v", err)
 	}
+	h.logSecurity(r, models.SecurityEventLoginFailed, userID, email, nil, reason)
+	if locked {
+		h.logSecurity(r, models.SecurityEventAccountLocked, userID, email, nil, "too many failed attempts")
+	}
+	http.Error(w, "invalid credentials", http.StatusUnauthorized)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// logSecurity пишет событие в журнал безопасности; сбой записи не мешает ответу.
+func (h *AuthHandler) logSecurity(r *http.Request, eventType string, userID *int64, email string, actorID *int64, detail string) {
+	if err := h.security.LogEvent(securityEvent(r, eventType, userID, email, actorID, detail)); err != nil {
+		log.Printf("auth: не удалось записать событие %s: %v", eventType, err)
+	}
+}
+
+func securityEvent(r *http.Request, eventType string, userID *int64, email string, actorID *int64, detail string) *models.SecurityEvent {
+	ev := &models.SecurityEvent{
+		Type:        eventType,
+		UserID:      userID,
+		IP:          clientIP(r),
+		ActorUserID: actorID,
+	}
+	if email = repository.NormalizeLoginEmail(email); email != "" {
+		ev.Email = &email
+	}
+	if ua := r.UserAgent(); ua != "" {
+		ev.UserAgent = &ua
+	}
+	if detail != "" {
+		ev.Detail = &detail
+	}
+	return ev
+}
+
+func writeLoginBlock(w http.ResponseWriter, block *repository.LoginBlock) {
+	wait := int(time.Until(block.Until).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(wait))
+	msg := "too many login attempts, try again in " + strconv.Itoa(wait) + "s"
+	if block.Locked {
+		msg = "login temporarily locked after too many failed attempts, try again in " + strconv.Itoa(wait) + "s"
+	}
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// clientIP — адрес клиента из соединения. Заголовки X-Forwarded-For не
+// учитываются: без доверенного прокси их подделывают, чтобы обойти счётчик IP.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов. Старый refresh-токен
+// после этого недействителен.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var in RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.RefreshToken == "" {
+		http.Error(w, "refresh_token required", http.StatusBadRequest)
+		return
+	}
+
+	next, user, sessionID, err := h.tokens.Rotate(in.RefreshToken, clientIP(r), r.UserAgent())
+	switch {
+	case errors.Is(err, repository.ErrRefreshTokenReused):
+		log.Printf("auth: повторное использование refresh-токена, цепочка отозвана")
+		http.Error(w, "refresh token revoked", http.StatusUnauthorized)
+		return
+	case errors.Is(err, repository.ErrRefreshTokenInvalid):
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	// Роль, требующая 2FA, без подключённого второго фактора (например, после
+	// сброса администратором) продлевать сессию не может — только войти заново.
+	if models.RoleRequiresMFA(user.Role) {
+		enabled, err := h.mfa.Enabled(user.UserID)
+		if err != nil {
+			http.Error(w, "failed to refresh token", http.StatusInternalServerError)
+			return
+		}
+		if !enabled {
+			h.tokens.RevokeRefresh(next, user.UserID)
+			http.Error(w, "two-factor authentication required, log in again", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	h.writeTokens(w, r, user, next, sessionID, http.StatusOK)
+}
+
+// Logout завершает текущий сеанс: отзывает access-токен и цепочку
+// refresh-токенов. Переданный refresh-токен отзывается тоже — для токенов,
+// выданных до появления сеансов.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var in RefreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if jti, exp, ok := authmw.GetTokenFromContext(r); ok {
+		if err := h.tokens.RevokeAccess(jti, userID, exp); err != nil {
+			http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+			return
+		}
+	}
+	if sessionID, ok := authmw.GetSessionFromContext(r); ok {
+		session, err := h.tokens.GetSession(sessionID)
+		if err == nil {
+			_, err = h.tokens.RevokeSession(session)
+		}
+		if err != nil {
+			http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+			return
+		}
+	}
+	if in.RefreshToken != "" {
+		if err := h.tokens.RevokeRefresh(in.RefreshToken, userID); err != nil {
+			http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
@@ -162,16 +406,28 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resp := meResponse(&user, role)
+	if adminID, ok := authmw.GetImpersonatorFromContext(r); ok {
+		resp["impersonated_by"] = adminID
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":         user.UserID,
-		"name":       user.Name,
-		"email":      user.Email,
-		"role":       role,
-		"avatar_url": user.AvatarURL,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
+func meResponse(user *models.User, role string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             user.UserID,
+		"name":           user.Name,
+		"email":          user.Email,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"role":           role,
+		"permissions":    models.RolePermissions(role),
+		"avatar_url":     user.AvatarURL,
+	}
+}
+
+// UpdateMe меняет профиль. Новый email сразу не записывается: на него уходит
+// ссылка подтверждения, и адрес сменится после перехода по ней.
 func (h *AuthHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 	userID, role, ok := authmw.GetUserFromContext(r)
 	if !ok {
@@ -188,37 +444,305 @@ func (h *AuthHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updates := map[string]interface{}{}
-	if in.Name != "" {
-		updates["name"] = in.Name
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
 	}
+
+	var pendingEmail string
 	if in.Email != nil {
-		updates["email"] = in.Email
+		email := strings.TrimSpace(*in.Email)
+		if user.Email == nil || !strings.EqualFold(*user.Email, email) {
+			if _, err := mail.ParseAddress(email); err != nil {
+				http.Error(w, "invalid email", http.StatusBadRequest)
+				return
+			}
+			var taken int64
+			h.db.Model(&models.User{}).Where("LOWER(email) = LOWER(?) AND user_id <> ?", email, userID).Count(&taken)
+			if taken > 0 {
+				http.Error(w, repository.ErrEmailTaken.Error(), http.StatusConflict)
+				return
+			}
+			pendingEmail = email
+		}
 	}
 
-	if len(updates) == 0 {
+	if in.Name == "" && pendingEmail == "" {
 		http.Error(w, "nothing to update", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.db.Model(&models.User{}).Where("user_id = ?", userID).Updates(updates).Error; err != nil {
-		http.Error(w, "failed to update user", http.StatusInternalServerError)
+	if in.Name != "" {
+		if err := h.db.Model(&models.User{}).Where("user_id = ?", userID).Update("name", in.Name).Error; err != nil {
+			http.Error(w, "failed to update user", http.StatusInternalServerError)
+			return
+		}
+		user.Name = in.Name
+	}
+
+	resp := meResponse(&user, role)
+	if pendingEmail != "" {
+		if !h.sendVerification(r.Context(), &user, pendingEmail) {
+			http.Error(w, "failed to send confirmation email", http.StatusInternalServerError)
+			return
+		}
+		if user.Email != nil {
+			h.send(r.Context(), mailer.Message{
+				To:      *user.Email,
+				Subject: "Смена email",
+				Body: "Здравствуйте, " + user.Name + "!\n\n" +
+					"Запрошена смена email вашей учётной записи на " + pendingEmail + ". " +
+					"Адрес изменится после подтверждения по ссылке из письма, отправленного на новый адрес.\n" +
+					"Если это были не вы, смените пароль.",
+			})
+		}
+		resp["pending_email"] = pendingEmail
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ChangePassword меняет пароль по старому паролю. Все остальные сессии
+// завершаются, текущая получает новую пару токенов.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var in ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.OldPassword == "" || in.NewPassword == "" {
+		http.Error(w, "old_password and new_password required", http.StatusBadRequest)
+		return
+	}
+	if len(in.NewPassword) < minPasswordLength {
+		http.Error(w, "password is too short", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.OldPassword)); err != nil {
+		http.Error(w, "invalid old password", http.StatusForbidden)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	if err := h.tokens.SetPassword(user.UserID, string(hash)); err != nil {
+		http.Error(w, "failed to update password", http.StatusInternalServerError)
+		return
+	}
+	if jti, exp, ok := authmw.GetTokenFromContext(r); ok {
+		if err := h.tokens.RevokeAccess(jti, user.UserID, exp); err != nil {
+			http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.logSecurity(r, models.SecurityEventPasswordChanged, &user.UserID, derefString(user.Email), nil, "")
+	h.notifyPasswordChanged(r.Context(), &user)
+
+	h.writeTokens(w, r, &user, "", 0, http.StatusOK)
+}
+
+// ForgotPassword отправляет ссылку сброса пароля. Ответ одинаков для
+// известных и неизвестных адресов, чтобы по нему нельзя было перебирать email.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	email := strings.TrimSpace(in.Email)
+	if email == "" {
+		http.Error(w, "email required", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err == nil && user.Email != nil {
+		raw, err := h.tokens.IssueUserToken(user.UserID, models.UserTokenPasswordReset, *user.Email, h.links.ResetTTL)
+		if err != nil {
+			log.Printf("auth: не удалось выпустить токен сброса пароля: %v", err)
+		} else {
+			h.send(r.Context(), mailer.Message{
+				To:      *user.Email,
+				Subject: "Сброс пароля",
+				Body: "Здравствуйте, " + user.Name + "!\n\n" +
+					"Чтобы задать новый пароль, перейдите по ссылке:\n" + h.link("/reset-password", raw) + "\n\n" +
+					"Ссылка одноразовая и действует до " + time.Now().Add(h.links.ResetTTL).Format("02.01.2006 15:04") + ".\n" +
+					"Если вы не запрашивали сброс, просто проигнорируйте это письмо.",
+			})
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword задаёт новый пароль по ссылке из письма и завершает все сессии.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var in ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.Token == "" || in.Password == "" {
+		http.Error(w, "token and password required", http.StatusBadRequest)
+		return
+	}
+	if len(in.Password) < minPasswordLength {
+		http.Error(w, "password is too short", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	userID, err := h.tokens.ResetPassword(in.Token, string(hash))
+	if err != nil {
+		writeUserTokenError(w, err)
 		return
 	}
 
 	var user models.User
-	h.db.First(&user, userID)
+	if h.db.First(&user, userID).Error == nil {
+		email := derefString(user.Email)
+		// Доступ к почте подтверждён — блокировка входа больше не нужна.
+		if err := h.security.ClearAccount(email); err != nil {
+			log.Printf("auth: не удалось снять блокировку входа: %v", err)
+		}
+		h.logSecurity(r, models.SecurityEventPasswordReset, &user.UserID, email, nil, "")
+		h.notifyPasswordChanged(r.Context(), &user)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail подтверждает email по ссылке из письма. При смене адреса
+// новый email записывается именно здесь.
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.Token == "" {
+		http.Error(w, "token required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.tokens.VerifyEmail(in.Token)
+	if err != nil {
+		writeUserTokenError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":         user.UserID,
-		"name":       user.Name,
-		"email":      user.Email,
-		"role":       role,
-		"avatar_url": user.AvatarURL,
+	json.NewEncoder(w).Encode(meResponse(user, user.Role))
+}
+
+// ResendVerification повторно отправляет ссылку подтверждения текущего email.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
+	}
+	if user.Email == nil {
+		http.Error(w, "user has no email", http.StatusBadRequest)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "email already verified", http.StatusConflict)
+		return
+	}
+	if !h.sendVerification(r.Context(), &user, *user.Email) {
+		http.Error(w, "failed to send confirmation email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendVerification отправляет на email ссылку подтверждения. Ошибка только
+// логируется: вызывающий решает, критична ли она.
+func (h *AuthHandler) sendVerification(ctx context.Context, user *models.User, email string) bool {
+	raw, err := h.tokens.IssueUserToken(user.UserID, models.UserTokenEmailVerify, email, h.links.VerifyTTL)
+	if err != nil {
+		log.Printf("auth: не удалось выпустить токен подтверждения email: %v", err)
+		return false
+	}
+	return h.send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Подтверждение email",
+		Body: "Здравствуйте, " + user.Name + "!\n\n" +
+			"Подтвердите адрес " + email + ", перейдя по ссылке:\n" + h.link("/verify-email", raw) + "\n\n" +
+			"Ссылка действует до " + time.Now().Add(h.links.VerifyTTL).Format("02.01.2006 15:04") + ".",
+	})
+}
+
+func (h *AuthHandler) notifyPasswordChanged(ctx context.Context, user *models.User) {
+	if user.Email == nil {
+		return
+	}
+	h.send(ctx, mailer.Message{
+		To:      *user.Email,
+		Subject: "Пароль изменён",
+		Body: "Здравствуйте, " + user.Name + "!\n\n" +
+			"Пароль вашей учётной записи был изменён, все остальные сеансы завершены.\n" +
+			"Если это были не вы, восстановите доступ через «Забыли пароль?».",
 	})
 }
 
+func (h *AuthHandler) send(ctx context.Context, msg mailer.Message) bool {
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("auth: не удалось отправить письмо «%s»: %v", msg.Subject, err)
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) link(path, token string) string {
+	return h.links.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func writeUserTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUserTokenInvalid):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, repository.ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (h *AuthHandler) MyStats(w http.ResponseWriter, r *http.Request) {
 	userID, _, ok := authmw.GetUserFromContext(r)
 	if !ok {
@@ -228,8 +752,8 @@ func (h *AuthHandler) MyStats(w http.ResponseWriter, r *http.Request) {
 
 	var total, active, completed int64
 	h.db.Model(&models.Booking{}).Where("user_id = ?", userID).Count(&total)
-	h.db.Model(&models.Booking{}).Where("user_id = ? AND status IN ?", userID, []string{"requested", "approved"}).Count(&active)
-	h.db.Model(&models.Booking{}).Where("user_id = ? AND status = ?", userID, "completed").Count(&completed)
+	h.db.Model(&models.Booking{}).Where("user_id = ? AND status IN ?", userID, models.BookingActiveStatuses).Count(&active)
+	h.db.Model(&models.Booking{}).Where("user_id = ? AND status = ?", userID, models.BookingStatusCompleted).Count(&completed)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2,23 +2,36 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/http-server/policy"
 	"oil-gas-service-booking/internal/http-server/repository"
 	"oil-gas-service-booking/internal/models"
 )
 
 type BookingHandler struct {
-	repo *repository.BookingRepo
-	db   *gorm.DB
+	repo               *repository.BookingRepo
+	companyServiceRepo *repository.CompanyServiceRepo
+	db                 *gorm.DB
+	policy             *policy.Policy
+	orgRepo            *repository.CustomerOrgRepo
 }
 
-func NewBookingHandler(repo *repository.BookingRepo, db *gorm.DB) *BookingHandler {
-	return &BookingHandler{repo: repo, db: db}
+func NewBookingHandler(
+	repo *repository.BookingRepo,
+	companyServiceRepo *repository.CompanyServiceRepo,
+	db *gorm.DB,
+	policy *policy.Policy,
+	orgRepo *repository.CustomerOrgRepo,
+) *BookingHandler {
+	return &BookingHandler{repo: repo, companyServiceRepo: companyServiceRepo, db: db, policy: policy, orgRepo: orgRepo}
 }
 
 func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -34,36 +47,26 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if role == "admin" {
-		if input.UserID == nil {
-			input.UserID = &userID
-		}
-	} else {
-		input.UserID = &userID
+	booking, ok := bookingFromRequest(w, &input, userID, role)
+	if !ok {
+		return
 	}
 
-	validStatuses := map[string]bool{
-		"requested": true,
-		"approved":  true,
-		"completed": true,
-		"rejected":  true,
-		"cancelled": true,
-	}
-	if input.Status == "" {
-		input.Status = "requested"
-	}
-	if !validStatuses[input.Status] {
-		http.Error(w, "status must be one of: requested, approved, completed, rejected, cancelled", http.StatusBadRequest)
+	// Пустая бронь сотрудника организации ждёт согласования, пока сумма
+	// неизвестна; автор выпустит её сам, если согласование не понадобится.
+	member, err := h.orgRepo.MembershipOf(*booking.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	booking := models.Booking{
-		UserID:      input.UserID,
-		Description: input.Description,
-		Status:      input.Status,
+	if member != nil {
+		booking.OrgID = &member.OrgID
+		if member.Org.NeedsApproval(member, nil) {
+			booking.Status = models.BookingStatusPendingApproval
+		}
 	}
 
-	if err := h.repo.Create(&booking); err != nil {
+	if err := h.repo.Create(booking); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -81,7 +84,7 @@ func (h *BookingHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		bookings, err := h.repo.GetByUserID(id)
+		bookings, err := h.repo.GetByUserID(id, repository.BookingFilter{})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -107,7 +110,13 @@ func (h *BookingHandler) GetMyBookings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bookings, err := h.repo.GetByUserID(userID)
+	filter, err := parseBookingFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bookings, err := h.repo.GetByUserID(userID, filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -139,19 +148,62 @@ func (h *BookingHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
+
 	booking, err := h.repo.GetByID(id)
 	if err != nil {
 		http.Error(w, "booking not found", http.StatusNotFound)
 		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(booking); err != nil {
+	// Декодер пишет прямо в указатели запроса, поэтому прежнее окно
+	// копируется до разбора тела.
+	prevStart, prevEnd := utcPtr(booking.ScheduledStart), utcPtr(booking.ScheduledEnd)
+
+	// Поля, которых нет в запросе, сохраняют текущие значения.
+	input := bookingUpdateRequestOf(booking)
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := input.Validate(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := h.repo.Update(booking); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Сводные статусы вычисляются по строкам, выставить их напрямую нельзя.
+	if input.Status != booking.Status && !models.IsValidBookingStatus(input.Status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	input.apply(booking)
+	windowChanged := !sameTimePtr(prevStart, booking.ScheduledStart) || !sameTimePtr(prevEnd, booking.ScheduledEnd)
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.repo.WithTx(tx).Update(booking, userID, input.Reason); err != nil {
+			return err
+		}
+		if !windowChanged || models.IsTerminalBookingStatus(booking.Status) {
+			return nil
+		}
+		// Новое окно проверяется по строкам, которые ещё занимают мощность.
+		active := repository.Where(booking.BookingServices, func(bs models.BookingService) bool {
+			return !models.IsTerminalBookingStatus(bs.Status)
+		})
+		return h.companyServiceRepo.CheckLinesAvailability(tx, booking, active)
+	})
+	if err != nil {
+		var conflict *repository.AvailabilityConflictError
+		if errors.As(err, &conflict) {
+			writeAvailabilityError(w, err)
+			return
+		}
+		writeBookingStatusError(w, err)
 		return
 	}
 
@@ -180,21 +232,51 @@ func (h *BookingHandler) GetMyCompanyBookings(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	bookings, err := h.repo.GetByCompanyOwner(userID)
+	filter, err := parseBookingFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if key, ok := authmw.GetAPIKeyFromContext(r); ok {
+		filter.CompanyID = key.CompanyID
+	}
+
+	bookings, err := h.repo.GetByCompanyMember(userID, filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(bookings)
+	resp := make([]CompanyBookingResponse, 0, len(bookings))
+	for _, b := range bookings {
+		resp = append(resp, companyBookingView(b))
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CompanyBookingResponse — бронь глазами компании: только её строки, итоги
+// по ним и CompanyStatus её части. Status остаётся сводным статусом брони.
+type CompanyBookingResponse struct {
+	models.Booking
+	CompanyStatus string
+}
+
+func companyBookingView(b models.Booking) CompanyBookingResponse {
+	b.ApplyTotals(b.BookingServices)
+	return CompanyBookingResponse{
+		Booking:       b,
+		CompanyStatus: models.DeriveBookingStatus(b.BookingServices),
+	}
 }
 
 func (h *BookingHandler) UpdateMyCompanyBookingStatus(w http.ResponseWriter, r *http.Request) {
-	userID, _, ok := authmw.GetUserFromContext(r)
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "user not authenticated", http.StatusUnauthorized)
 		return
 	}
+	userID := subject.UserID
 
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -202,7 +284,13 @@ func (h *BookingHandler) UpdateMyCompanyBookingStatus(w http.ResponseWriter, r *
 		return
 	}
 
-	owned, err := h.repo.IsBookingOwnedByCompanyOwner(id, userID)
+	// Ключ компании работает только со строками своей компании.
+	companyIDs, err := h.policy.OperatedCompanyIDs(subject)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	owned, err := h.repo.IsCompanyMemberBooking(id, companyIDs)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -213,66 +301,107 @@ func (h *BookingHandler) UpdateMyCompanyBookingStatus(w http.ResponseWriter, r *
 	}
 
 	var body struct {
-		Status string `json:"status"`
+		Status string  `json:"status"`
+		Reason *string `json:"reason"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	validStatuses := map[string]bool{
-		"requested": true,
-		"approved":  true,
-		"completed": true,
-		"rejected":  true,
-		"cancelled": true,
-	}
-	if !validStatuses[body.Status] {
+	if !models.IsValidBookingStatus(body.Status) {
 		http.Error(w, "invalid status", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.repo.UpdateStatus(id, body.Status); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Перевод из листа ожидания обратно в заявку возможен только при свободной
+	// мощности. Проверка и смена статуса идут в одной транзакции, чтобы
+	// параллельная бронь не заняла освободившееся окно между ними.
+	var changed []models.BookingService
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		repo := h.repo.WithTx(tx)
+		if body.Status == models.BookingStatusRequested {
+			booking, err := repo.GetByID(id)
+			if err != nil {
+				return err
+			}
+			lines, err := repo.GetCompanyLines(id, companyIDs)
+			if err != nil {
+				return err
+			}
+			if err := h.companyServiceRepo.CheckLinesAvailability(tx, booking, lines); err != nil {
+				return err
+			}
+		}
+		var err error
+		changed, err = repo.UpdateCompanyStatus(id, userID, companyIDs, body.Status, body.Reason)
+		return err
+	})
+	if err != nil {
+		var conflict *repository.AvailabilityConflictError
+		if errors.As(err, &conflict) {
+			writeAvailabilityError(w, err)
+			return
+		}
+		writeBookingStatusError(w, err)
 		return
 	}
 
-	if body.Status == "approved" || body.Status == "rejected" || body.Status == "completed" {
+	if body.Status != models.BookingStatusRequested && body.Status != models.BookingStatusCancelled {
 		booking, err := h.repo.GetByID(id)
 		if err == nil && booking.UserID != nil {
-			var bs models.BookingService
-			serviceName, companyName := "", ""
-			if h.db.Preload("CompanyService.Service").Preload("CompanyService.Company").
-				Where("booking_id = ?", id).First(&bs).Error == nil {
-				serviceName = bs.CompanyService.Service.Title
-				companyName = bs.CompanyService.Company.Name
-			}
-
-			title, message := "", ""
-			switch body.Status {
-			case "approved":
-				title = "Бронирование подтверждено"
-				message = "Компания «" + companyName + "» подтвердила вашу бронь услуги «" + serviceName + "»."
-			case "rejected":
-				title = "Бронирование отклонено"
-				message = "Компания «" + companyName + "» отклонила вашу бронь услуги «" + serviceName + "»."
-			case "completed":
-				title = "Бронирование выполнено"
-				message = "Компания «" + companyName + "» выполнила услугу «" + serviceName + "»."
-			}
-			h.db.Create(&models.Notification{
-				UserID:  *booking.UserID,
-				Title:   title,
-				Message: message,
-			})
+			h.db.Create(companyStatusNotifications(*booking.UserID, body.Status, changed))
 		}
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// companyStatusNotifications собирает для заказчика по одному уведомлению на
+// каждую компанию, изменившую статус своей части брони.
+func companyStatusNotifications(customerID int64, status string, changed []models.BookingService) *[]models.Notification {
+	services := map[int64][]string{}
+	names := map[int64]string{}
+	var order []int64
+	for _, bs := range changed {
+		c := bs.CompanyService.Company
+		if _, seen := names[c.CompanyID]; !seen {
+			order = append(order, c.CompanyID)
+			names[c.CompanyID] = c.Name
+		}
+		services[c.CompanyID] = append(services[c.CompanyID], "«"+bs.CompanyService.Service.Title+"»")
+	}
+
+	notifs := make([]models.Notification, 0, len(order))
+	for _, companyID := range order {
+		companyName, serviceName := names[companyID], strings.Join(services[companyID], ", ")
+
+		title, message := "", ""
+		switch status {
+		case models.BookingStatusApproved:
+			title = "Бронирование подтверждено"
+			message = "Компания «" + companyName + "» подтвердила вашу бронь услуги " + serviceName + "."
+		case models.BookingStatusRejected:
+			title = "Бронирование отклонено"
+			message = "Компания «" + companyName + "» отклонила вашу бронь услуги " + serviceName + "."
+		case models.BookingStatusInProgress:
+			title = "Работы начаты"
+			message = "Компания «" + companyName + "» приступила к выполнению услуги " + serviceName + "."
+		case models.BookingStatusCompleted:
+			title = "Бронирование выполнено"
+			message = "Компания «" + companyName + "» выполнила услугу " + serviceName + "."
+		}
+		notifs = append(notifs, models.Notification{
+			UserID:  customerID,
+			Title:   title,
+			Message: message,
+		})
+	}
+	return &notifs
+}
+
 func (h *BookingHandler) CancelMy(w http.ResponseWriter, r *http.Request) {
-	userID, _, ok := authmw.GetUserFromContext(r)
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "user not authenticated", http.StatusUnauthorized)
 		return
@@ -290,34 +419,50 @@ func (h *BookingHandler) CancelMy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if booking.UserID == nil || *booking.UserID != userID {
+	if !h.policy.IsBookingCustomer(subject, booking) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
 
-	if booking.Status != "requested" && booking.Status != "approved" {
-		http.Error(w, "cannot cancel booking with status: "+booking.Status, http.StatusBadRequest)
-		return
+	var body struct {
+		Reason *string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	if err := h.repo.UpdateStatus(id, "cancelled"); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Отмена брони, ждущей согласования, снимает и предварительный выбор
+	// предложения по заявке.
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.repo.WithTx(tx).UpdateStatus(id, models.BookingStatusCancelled, models.BookingActorCustomer, subject.UserID, body.Reason); err != nil {
+			return err
+		}
+		return reopenPendingAward(tx, id)
+	})
+	if err != nil {
+		writeBookingStatusError(w, err)
 		return
 	}
 
-	var ownerIDs []int64
-	h.db.Model(&models.BookingService{}).
-		Joins("JOIN company_service ON company_service.company_service_id = booking_service.company_service_id").
-		Joins("JOIN company ON company.company_id = company_service.company_id").
-		Where("booking_service.booking_id = ?", id).
-		Distinct("company.user_id").
-		Pluck("company.user_id", &ownerIDs)
+	// Брони, не прошедшей внутреннее согласование, исполнители не видели.
+	var companyIDs []int64
+	if booking.Status != models.BookingStatusPendingApproval {
+		h.db.Model(&models.BookingService{}).
+			Joins("JOIN company_service ON company_service.company_service_id = booking_service.company_service_id").
+			Where("booking_service.booking_id = ?", id).
+			Distinct("company_service.company_id").
+			Pluck("company_service.company_id", &companyIDs)
+	}
+	ownerIDs := companyRecipients(h.db, companyIDs...)
 
 	var userName string
 	if booking.User != nil {
 		userName = booking.User.Name
 	} else {
-		h.db.Model(&models.User{}).Where("user_id = ?", userID).Pluck("name", &userName)
+		h.db.Model(&models.User{}).Where("user_id = ?", subject.UserID).Pluck("name", &userName)
 	}
 	if len(ownerIDs) > 0 {
 		var bs models.BookingService
@@ -341,7 +486,7 @@ func (h *BookingHandler) CancelMy(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *BookingHandler) DeleteMy(w http.ResponseWriter, r *http.Request) {
-	userID, _, ok := authmw.GetUserFromContext(r)
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "user not authenticated", http.StatusUnauthorized)
 		return
@@ -359,7 +504,7 @@ func (h *BookingHandler) DeleteMy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if booking.UserID == nil || *booking.UserID != userID {
+	if !h.policy.IsBookingCustomer(subject, booking) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -371,3 +516,193 @@ func (h *BookingHandler) DeleteMy(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+type BookingStatusEventResponse struct {
+	models.BookingStatusEvent
+	ActorName   *string `json:"actor_name"`
+	CompanyName *string `json:"company_name"`
+}
+
+// GetHistory отдаёт хронологию смены статусов брони. Доступ — по
+// policy.CanViewBooking: заказчик, исполнитель строки, администратор, аудитор.
+func (h *BookingHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	booking, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return
+	}
+
+	allowed, err := h.policy.CanViewBooking(subject, booking)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	events, err := h.repo.GetStatusHistory(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]BookingStatusEventResponse, 0, len(events))
+	for _, e := range events {
+		item := BookingStatusEventResponse{BookingStatusEvent: e}
+		if e.Actor != nil {
+			item.ActorName = &e.Actor.Name
+		}
+		if e.Company != nil {
+			item.CompanyName = &e.Company.Name
+		}
+		resp = append(resp, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// bookingFromRequest проверяет заголовок брони и собирает модель. Заказчиком
+// всегда становится текущий пользователь; администратор броней может указать user_id.
+// При ошибке ответ уже записан в w.
+func bookingFromRequest(w http.ResponseWriter, input *BookingCreateRequest, userID int64, role string) (*models.Booking, bool) {
+	if models.RoleHasPermission(role, models.PermBookingsManageAll) {
+		if input.UserID == nil {
+			input.UserID = &userID
+		}
+	} else {
+		input.UserID = &userID
+	}
+
+	if input.Status == "" {
+		input.Status = models.BookingStatusRequested
+	}
+	if !models.IsValidBookingStatus(input.Status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return nil, false
+	}
+	if input.Status != models.BookingStatusRequested {
+		writeBookingStatusError(w, &models.BookingTransitionError{To: input.Status, Actor: bookingActorForRole(role)})
+		return nil, false
+	}
+
+	if err := input.Validate(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	booking := models.Booking{
+		UserID:           input.UserID,
+		Description:      input.Description,
+		Status:           input.Status,
+		ScheduledStart:   utcPtr(input.ScheduledStart),
+		ScheduledEnd:     utcPtr(input.ScheduledEnd),
+		SiteAddress:      input.SiteAddress,
+		SiteField:        input.SiteField,
+		SitePad:          input.SitePad,
+		SiteWell:         input.SiteWell,
+		SiteLatitude:     input.SiteLatitude,
+		SiteLongitude:    input.SiteLongitude,
+		SiteContactName:  input.SiteContactName,
+		SiteContactPhone: input.SiteContactPhone,
+	}
+
+	return &booking, true
+}
+
+// parseBookingFilter читает from/to из query. Принимаются RFC3339 и YYYY-MM-DD;
+// дата без времени в to включает весь день.
+func parseBookingFilter(r *http.Request) (repository.BookingFilter, error) {
+	var filter repository.BookingFilter
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		from, err := parseDateParam(fromStr)
+		if err != nil {
+			return filter, errors.New("invalid from date format. Use YYYY-MM-DD or RFC3339")
+		}
+		filter.From = &from
+	}
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		to, err := parseDateParam(toStr)
+		if err != nil {
+			return filter, errors.New("invalid to date format. Use YYYY-MM-DD or RFC3339")
+		}
+		if len(toStr) == len("2006-01-02") {
+			to = to.Add(24 * time.Hour)
+		}
+		filter.To = &to
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, errors.New("to must be after from")
+	}
+
+	return filter, nil
+}
+
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func sameTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func bookingActorForRole(role string) models.BookingActor {
+	if models.RoleHasPermission(role, models.PermBookingsManageAll) {
+		return models.BookingActorAdmin
+	}
+	return models.BookingActorCustomer
+}
+
+// writeBookingStatusError отдаёт 409 с описанием недопустимого перехода,
+// 404 для несуществующей брони и 500 для остальных ошибок.
+func writeBookingStatusError(w http.ResponseWriter, err error) {
+	var te *models.BookingTransitionError
+	switch {
+	case errors.As(err, &te):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "invalid_status_transition",
+			"message": te.Error(),
+			"from":    te.From,
+			"to":      te.To,
+			"actor":   te.Actor,
+			"allowed": models.NextBookingStatuses(te.From, te.Actor),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "booking not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+const maxCheckoutItems = 50
+
+// Checkout создаёт бронь вместе со всеми строками услуг в одной транзакции.
+// Если хотя бы одна строка не проходит проверку, не сохраняется ничего.
+// Каждый сотрудник компании получает одно сводное уведомление. Бронь
+// сотрудника организации заказчика сверх его полномочий остаётся в
+// pending_internal_approval, и уведомления получают согласующие.
+func (h *BookingHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	var input BookingCheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, role, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if len(input.Items) == 0 {
+		http.Error(w, "items must not be empty", http.StatusBadRequest)
+		return
+	}
+	if len(input.Items) > maxCheckoutItems {
+		http.Error(w, fmt.Sprintf("too many items: max %d", maxCheckoutItems), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]int64, 0, len(input.Items))
+	for i, item := range input.Items {
+		if item.CompanyServiceID <= 0 {
+			http.Error(w, fmt.Sprintf("items[%d].company_service_id is required", i), http.StatusBadRequest)
+			return
+		}
+		if item.Quantity != nil && *item.Quantity <= 0 {
+			http.Error(w, fmt.Sprintf("items[%d].quantity must be positive", i), http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, item.CompanyServiceID)
+	}
+
+	booking, ok := bookingFromRequest(w, &input.BookingCreateRequest, userID, role)
+	if !ok {
+		return
+	}
+
+	found, err := h.companyServiceRepo.GetByIDs(ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	services := make(map[int64]models.CompanyService, len(found))
+	for _, cs := range found {
+		services[cs.CompanyServiceID] = cs
+	}
+	var missing []string
+	for _, id := range ids {
+		if _, ok := services[id]; !ok {
+			missing = append(missing, strconv.FormatInt(id, 10))
+		}
+	}
+	if len(missing) > 0 {
+		http.Error(w, "company services not found: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+	var unverified []string
+	for _, id := range ids {
+		if cs := services[id]; !cs.Company.Verified() {
+			unverified = append(unverified, strconv.FormatInt(id, 10))
+		}
+	}
+	if len(unverified) > 0 {
+		http.Error(w, errCompanyNotVerified.Error()+": company services "+strings.Join(unverified, ", "), http.StatusConflict)
+		return
+	}
+
+	member, err := h.orgRepo.MembershipOf(*booking.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if member != nil {
+		booking.OrgID = &member.OrgID
+	}
+
+	waitlisted := false
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		repo := h.repo.WithTx(tx)
+
+		if err := repo.Create(booking); err != nil {
+			return err
+		}
+
+		lines := make([]models.BookingService, 0, len(input.Items))
+		for _, item := range input.Items {
+			qty := 1
+			if item.Quantity != nil {
+				qty = *item.Quantity
+			}
+			cs := services[item.CompanyServiceID]
+			unitPrice, currency := models.PriceSnapshot(&cs)
+			lines = append(lines, models.BookingService{
+				BookingID:        booking.BookingID,
+				CompanyServiceID: item.CompanyServiceID,
+				Notes:            item.Notes,
+				Quantity:         &qty,
+				Status:           models.BookingStatusRequested,
+				UnitPrice:        unitPrice,
+				Currency:         currency,
+			})
+		}
+		if err := repo.AddServices(lines); err != nil {
+			return err
+		}
+		if err := repo.RecalculateTotals(booking.BookingID); err != nil {
+			return err
+		}
+
+		// Бронь сотрудника организации сверх его полномочий ждёт внутреннего
+		// согласования; мощность проверяется при выпуске к исполнителям.
+		if member != nil {
+			if err := tx.First(booking, booking.BookingID).Error; err != nil {
+				return err
+			}
+			if member.Org.NeedsApproval(member, booking.Total) {
+				if err := repo.HoldForApproval(booking.BookingID, userID); err != nil {
+					return err
+				}
+				var requester string
+				tx.Model(&models.User{}).Where("user_id = ?", *booking.UserID).Pluck("name", &requester)
+				notifs := approvalRequestNotifications(h.orgRepo.WithTx(tx), booking, false, *booking.UserID, holdMessage(booking, requester))
+				if len(notifs) == 0 {
+					return nil
+				}
+				return tx.Create(&notifs).Error
+			}
+		}
+
+		// В лист ожидания уходят только строки услуг, которым не хватает
+		// мощности; остальные остаются заявками.
+		conflicted, err := capacityConflicts(tx, h.companyServiceRepo, booking.BookingID)
+		var conflict *repository.AvailabilityConflictError
+		switch {
+		case errors.As(err, &conflict) && input.Waitlist:
+			reason := "недостаточно свободной мощности на выбранные даты"
+			if err := repo.UpdateLinesStatus(booking.BookingID, conflicted, models.BookingStatusWaitlisted, models.BookingActorCustomer, userID, &reason); err != nil {
+				return err
+			}
+			for i := range lines {
+				for _, id := range conflicted {
+					if lines[i].BookingServiceID == id {
+						lines[i].Status = models.BookingStatusWaitlisted
+					}
+				}
+			}
+			waitlisted = true
+		case err != nil:
+			return err
+		}
+
+		return tx.Create(checkoutNotifications(tx, booking, lines, services)).Error
+	})
+	if err != nil {
+		var conflict *repository.AvailabilityConflictError
+		var transition *models.BookingTransitionError
+		switch {
+		case errors.As(err, &conflict):
+			writeAvailabilityError(w, err)
+		case errors.As(err, &transition):
+			writeBookingStatusError(w, err)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	created, err := h.repo.GetWithServices(booking.BookingID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if waitlisted {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(created)
+}
+
+// capacityConflicts проверяет мощность отдельно по каждой услуге брони и
+// возвращает строки тех услуг, которым её не хватает, вместе с первым
+// конфликтом. Прочие ошибки возвращаются без строк.
+func capacityConflicts(tx *gorm.DB, csRepo *repository.CompanyServiceRepo, bookingID int64) ([]int64, error) {
+	var b models.Booking
+	if err := tx.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+		return nil, err
+	}
+
+	byService := map[int64][]models.BookingService{}
+	var order []int64
+	for _, bs := range b.BookingServices {
+		if _, seen := byService[bs.CompanyServiceID]; !seen {
+			order = append(order, bs.CompanyServiceID)
+		}
+		byService[bs.CompanyServiceID] = append(byService[bs.CompanyServiceID], bs)
+	}
+
+	var (
+		lineIDs []int64
+		first   error
+	)
+	for _, csID := range order {
+		err := csRepo.CheckLinesAvailability(tx, &b, byService[csID])
+		var conflict *repository.AvailabilityConflictError
+		switch {
+		case errors.As(err, &conflict):
+			for _, bs := range byService[csID] {
+				lineIDs = append(lineIDs, bs.BookingServiceID)
+			}
+			if first == nil {
+				first = err
+			}
+		case err != nil:
+			return nil, err
+		}
+	}
+	return lineIDs, first
+}
+
+// checkoutNotifications собирает по одному уведомлению на каждого сотрудника
+// компаний, чьи услуги попали в бронь. Строки в листе ожидания помечаются.
+func checkoutNotifications(
+	tx *gorm.DB,
+	booking *models.Booking,
+	lines []models.BookingService,
+	services map[int64]models.CompanyService,
+) *[]models.Notification {
+	userName := "Пользователь"
+	if booking.UserID != nil {
+		var name string
+		if tx.Model(&models.User{}).Where("user_id = ?", *booking.UserID).Pluck("name", &name).Error == nil && name != "" {
+			userName = name
+		}
+	}
+
+	companyIDs := make([]int64, 0, len(lines))
+	for _, bs := range lines {
+		companyIDs = append(companyIDs, services[bs.CompanyServiceID].CompanyID)
+	}
+	members, _ := repository.CompanyMemberUserIDs(tx, companyIDs)
+
+	summary := map[int64][]string{}
+	waiting := map[int64]int{}
+	for _, bs := range lines {
+		cs := services[bs.CompanyServiceID]
+		line := "«" + cs.Service.Title + "» (" + cs.Company.Name + ")"
+		if bs.Quantity != nil && *bs.Quantity > 1 {
+			line += " ×" + strconv.Itoa(*bs.Quantity)
+		}
+		waitlisted := bs.Status == models.BookingStatusWaitlisted
+		if waitlisted {
+			line += " — в листе ожидания"
+		}
+		for _, memberID := range members[cs.CompanyID] {
+			summary[memberID] = append(summary[memberID], line)
+			if waitlisted {
+				waiting[memberID]++
+			}
+		}
+	}
+
+	owners := make([]int64, 0, len(summary))
+	for ownerID := range summary {
+		owners = append(owners, ownerID)
+	}
+	sort.Slice(owners, func(i, j int) bool { return owners[i] < owners[j] })
+
+	notifs := make([]models.Notification, 0, len(owners))
+	for _, ownerID := range owners {
+		title := "Новое бронирование"
+		if waiting[ownerID] == len(summary[ownerID]) {
+			title = "Новое бронирование в листе ожидания"
+		}
+		notifs = append(notifs, models.Notification{
+			UserID:  ownerID,
+			Title:   title,
+			Message: userName + " оформил бронь №" + strconv.FormatInt(booking.BookingID, 10) + ": " + strings.Join(summary[ownerID], ", ") + ".",
+		})
+	}
+	return &notifs
+}
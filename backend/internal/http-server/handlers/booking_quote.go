@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+type BookingQuoteHandler struct {
+	repo        *repository.QuoteRepo
+	bookingRepo *repository.BookingRepo
+	db          *gorm.DB
+	policy      *policy.Policy
+	orgRepo     *repository.CustomerOrgRepo
+}
+
+func NewBookingQuoteHandler(
+	repo *repository.QuoteRepo,
+	bookingRepo *repository.BookingRepo,
+	db *gorm.DB,
+	policy *policy.Policy,
+	orgRepo *repository.CustomerOrgRepo,
+) *BookingQuoteHandler {
+	return &BookingQuoteHandler{repo: repo, bookingRepo: bookingRepo, db: db, policy: policy, orgRepo: orgRepo}
+}
+
+type QuoteLineRequest struct {
+	BookingServiceID int64   `json:"booking_service_id"`
+	UnitPrice        float64 `json:"unit_price"`
+}
+
+type QuoteCreateRequest struct {
+	// CompanyID обязателен, только если у владельца несколько компаний в брони.
+	CompanyID  *int64             `json:"company_id,omitempty"`
+	ValidUntil time.Time          `json:"valid_until"`
+	Terms      *string            `json:"terms,omitempty"`
+	Currency   string             `json:"currency,omitempty"`
+	Lines      []QuoteLineRequest `json:"lines"`
+}
+
+type QuoteResponse struct {
+	models.BookingQuote
+	CompanyName string `json:"company_name"`
+}
+
+// Create — компания предлагает цены по своим строкам брони. Каждый вызов
+// создаёт новую версию предложения, предыдущая ожидающая версия заменяется.
+func (h *BookingQuoteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
+	userID := subject.UserID
+
+	bookingID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var input QuoteCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !input.ValidUntil.After(time.Now()) {
+		http.Error(w, "valid_until must be in the future", http.StatusBadRequest)
+		return
+	}
+	if input.Currency == "" {
+		input.Currency = models.DefaultCurrency
+	}
+	if len(input.Lines) == 0 {
+		http.Error(w, "lines must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	booking, err := h.bookingRepo.GetByID(bookingID)
+	if err != nil {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return
+	}
+
+	companyIDs, err := h.policy.OperatedCompanyIDs(subject)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	owned, err := h.bookingRepo.GetCompanyLines(bookingID, companyIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(owned) == 0 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	byCompany, err := linesByCompany(h.db, owned)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var companyID int64
+	switch {
+	case input.CompanyID != nil:
+		if _, ok := byCompany[*input.CompanyID]; !ok {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		companyID = *input.CompanyID
+	case len(byCompany) == 1:
+		for id := range byCompany {
+			companyID = id
+		}
+	default:
+		http.Error(w, "company_id is required: booking contains services of several of your companies", http.StatusBadRequest)
+		return
+	}
+
+	// Предложение должно покрывать ровно те строки компании, что ждут решения.
+	open := map[int64]models.BookingService{}
+	for _, bs := range byCompany[companyID] {
+		if bs.Status == models.BookingStatusRequested {
+			open[bs.BookingServiceID] = bs
+		}
+	}
+	if len(open) == 0 {
+		http.Error(w, "no requested services of this company to quote", http.StatusConflict)
+		return
+	}
+
+	quote := models.BookingQuote{
+		BookingID:       bookingID,
+		CompanyID:       companyID,
+		Currency:        input.Currency,
+		ValidUntil:      input.ValidUntil.UTC(),
+		Terms:           input.Terms,
+		CreatedByUserID: &userID,
+	}
+	seen := map[int64]bool{}
+	for i, l := range input.Lines {
+		bs, ok := open[l.BookingServiceID]
+		if !ok {
+			http.Error(w, fmt.Sprintf("lines[%d]: booking service %d is not a requested service of this company", i, l.BookingServiceID), http.StatusBadRequest)
+			return
+		}
+		if seen[l.BookingServiceID] {
+			http.Error(w, fmt.Sprintf("lines[%d]: duplicate booking_service_id", i), http.StatusBadRequest)
+			return
+		}
+		if l.UnitPrice < 0 {
+			http.Error(w, fmt.Sprintf("lines[%d].unit_price must not be negative", i), http.StatusBadRequest)
+			return
+		}
+		seen[l.BookingServiceID] = true
+
+		bs.UnitPrice = &l.UnitPrice
+		lineTotal := *bs.ComputeLineTotal()
+		qty := 1
+		if bs.Quantity != nil {
+			qty = *bs.Quantity
+		}
+		quote.Lines = append(quote.Lines, models.BookingQuoteLine{
+			BookingServiceID: l.BookingServiceID,
+			Quantity:         qty,
+			UnitPrice:        l.UnitPrice,
+			LineTotal:        lineTotal,
+		})
+		quote.Subtotal += lineTotal
+	}
+	if len(seen) != len(open) {
+		var missing []string
+		for id := range open {
+			if !seen[id] {
+				missing = append(missing, strconv.FormatInt(id, 10))
+			}
+		}
+		http.Error(w, "quote must price every requested service of the company, missing: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Create(&quote); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := h.repo.GetByID(bookingID, quote.QuoteID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if booking.UserID != nil {
+		title := "Коммерческое предложение"
+		if created.Version > 1 {
+			title = "Обновлённое коммерческое предложение"
+		}
+		h.db.Create(&models.Notification{
+			UserID: *booking.UserID,
+			Title:  title,
+			Message: "Компания «" + created.Company.Name + "» предложила цену по брони №" + strconv.FormatInt(bookingID, 10) +
+				" (версия " + strconv.Itoa(created.Version) + "): " + formatMoney(created.Subtotal) + " " + created.Currency +
+				" без НДС, действует до " + created.ValidUntil.Format("02.01.2006") + ".",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quoteResponse(*created))
+}
+
+// GetByBooking отдаёт все версии предложений. Заказчик, администратор и
+// аудитор видят предложения всех компаний, исполнитель — только своих.
+func (h *BookingQuoteHandler) GetByBooking(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	bookingID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	booking, err := h.bookingRepo.GetByID(bookingID)
+	if err != nil {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return
+	}
+
+	var companyIDs []int64
+	if !h.policy.CanViewWholeBooking(subject, booking) {
+		allowed, err := h.policy.CanViewBooking(subject, booking)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if companyIDs, err = h.policy.OperatedCompanyIDs(subject); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	quotes, err := h.repo.GetByBooking(bookingID, companyIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]QuoteResponse, 0, len(quotes))
+	for _, q := range quotes {
+		resp = append(resp, quoteResponse(q))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Accept — заказчик принимает предложение; строки компании переходят
+// в approved с зафиксированными ценами.
+func (h *BookingQuoteHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, true)
+}
+
+// Reject — заказчик отклоняет предложение; компания может прислать новую версию.
+func (h *BookingQuoteHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, false)
+}
+
+func (h *BookingQuoteHandler) decide(w http.ResponseWriter, r *http.Request, accept bool) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	bookingID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	quoteID, err := strconv.ParseInt(chi.URLParam(r, "quoteId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid quote id", http.StatusBadRequest)
+		return
+	}
+
+	booking, err := h.bookingRepo.GetByID(bookingID)
+	if err != nil {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return
+	}
+	if !h.policy.IsBookingCustomer(subject, booking) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	userID := subject.UserID
+
+	var body struct {
+		Reason *string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if accept && booking.OrgID != nil {
+		exceeds, err := h.quoteExceedsApproval(booking, quoteID)
+		if err != nil {
+			writeQuoteError(w, err)
+			return
+		}
+		if exceeds {
+			http.Error(w, "quote would raise the booking above the internally approved amount", http.StatusConflict)
+			return
+		}
+	}
+
+	var quote *models.BookingQuote
+	if accept {
+		quote, err = h.repo.Accept(bookingID, quoteID, userID, body.Reason)
+	} else {
+		quote, err = h.repo.Reject(bookingID, quoteID, body.Reason)
+	}
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	var userName string
+	h.db.Model(&models.User{}).Where("user_id = ?", userID).Pluck("name", &userName)
+	title, verb := "Предложение принято", "принял"
+	if !accept {
+		title, verb = "Предложение отклонено", "отклонил"
+	}
+	message := "Заказчик " + userName + " " + verb + " ваше предложение по брони №" + strconv.FormatInt(bookingID, 10) +
+		" (версия " + strconv.Itoa(quote.Version) + ")."
+	if body.Reason != nil && *body.Reason != "" {
+		message += " Комментарий: " + *body.Reason
+	}
+	var notifs []models.Notification
+	for _, memberID := range companyRecipients(h.db, quote.CompanyID) {
+		notifs = append(notifs, models.Notification{
+			UserID:  memberID,
+			Title:   title,
+			Message: message,
+		})
+	}
+	if len(notifs) > 0 {
+		h.db.Create(&notifs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quoteResponse(*quote))
+}
+
+// quoteExceedsApproval — принятие предложения подняло бы сумму брони
+// организации выше согласованной.
+func (h *BookingQuoteHandler) quoteExceedsApproval(booking *models.Booking, quoteID int64) (bool, error) {
+	quote, err := h.repo.GetByID(booking.BookingID, quoteID)
+	if err != nil {
+		return false, err
+	}
+	withLines, err := h.bookingRepo.GetWithServices(booking.BookingID)
+	if err != nil {
+		return false, err
+	}
+
+	prices := make(map[int64]float64, len(quote.Lines))
+	for _, ql := range quote.Lines {
+		prices[ql.BookingServiceID] = ql.UnitPrice
+	}
+	lines := withLines.BookingServices
+	for i := range lines {
+		if price, ok := prices[lines[i].BookingServiceID]; ok {
+			price := price
+			lines[i].UnitPrice, lines[i].Currency = &price, quote.Currency
+		}
+	}
+	withLines.ApplyTotals(lines)
+	return exceedsApproval(h.orgRepo, booking, withLines.Total)
+}
+
+func quoteResponse(q models.BookingQuote) QuoteResponse {
+	if q.Lines == nil {
+		q.Lines = []models.BookingQuoteLine{}
+	}
+	return QuoteResponse{BookingQuote: q, CompanyName: q.Company.Name}
+}
+
+// linesByCompany группирует строки брони по компаниям.
+func linesByCompany(db *gorm.DB, lines []models.BookingService) (map[int64][]models.BookingService, error) {
+	ids := make([]int64, 0, len(lines))
+	for _, bs := range lines {
+		ids = append(ids, bs.CompanyServiceID)
+	}
+	var services []models.CompanyService
+	if err := db.Where("company_service_id IN ?", ids).Find(&services).Error; err != nil {
+		return nil, err
+	}
+	companyOf := make(map[int64]int64, len(services))
+	for _, cs := range services {
+		companyOf[cs.CompanyServiceID] = cs.CompanyID
+	}
+
+	result := map[int64][]models.BookingService{}
+	for _, bs := range lines {
+		companyID := companyOf[bs.CompanyServiceID]
+		result[companyID] = append(result[companyID], bs)
+	}
+	return result, nil
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// writeQuoteError отдаёт 409 для предложения, по которому решение уже
+// принято или которое просрочено, и для недопустимых переходов строк.
+func writeQuoteError(w http.ResponseWriter, err error) {
+	var se *repository.QuoteStateError
+	switch {
+	case errors.As(err, &se):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "quote_not_pending",
+			"message":  se.Error(),
+			"quote_id": se.QuoteID,
+			"status":   se.Status,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "quote not found", http.StatusNotFound)
+	default:
+		writeBookingStatusError(w, err)
+	}
+}
@@ -2,28 +2,56 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/http-server/policy"
 	"oil-gas-service-booking/internal/http-server/repository"
 	"oil-gas-service-booking/internal/models"
 )
 
 type BookingServiceHandler struct {
-	repo *repository.BookingServiceRepo
-	db   *gorm.DB
+	repo               *repository.BookingServiceRepo
+	bookingRepo        *repository.BookingRepo
+	companyServiceRepo *repository.CompanyServiceRepo
+	db                 *gorm.DB
+	policy             *policy.Policy
+	orgRepo            *repository.CustomerOrgRepo
 }
 
-func NewBookingServiceHandler(repo *repository.BookingServiceRepo, db *gorm.DB) *BookingServiceHandler {
-	return &BookingServiceHandler{repo: repo, db: db}
+func NewBookingServiceHandler(
+	repo *repository.BookingServiceRepo,
+	bookingRepo *repository.BookingRepo,
+	companyServiceRepo *repository.CompanyServiceRepo,
+	db *gorm.DB,
+	policy *policy.Policy,
+	orgRepo *repository.CustomerOrgRepo,
+) *BookingServiceHandler {
+	return &BookingServiceHandler{
+		repo:               repo,
+		bookingRepo:        bookingRepo,
+		companyServiceRepo: companyServiceRepo,
+		db:                 db,
+		policy:             policy,
+		orgRepo:            orgRepo,
+	}
 }
 
+var (
+	errBookingLinesLocked = errors.New("booking lines can no longer be changed")
+	errExceedsApproval    = errors.New("booking total would exceed the internally approved amount; create a new booking")
+)
+
 type BookingServiceRequest struct {
 	BookingID        int64   `json:"booking_id"`
 	CompanyServiceID int64   `json:"company_service_id"`
 	Notes            *string `json:"notes,omitempty"`
+	Quantity         *int    `json:"quantity,omitempty"`
+	// Waitlist: при нехватке мощности поставить бронь в лист ожидания вместо отказа.
+	Waitlist bool `json:"waitlist,omitempty"`
 }
 
 func (h *BookingServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -41,37 +69,184 @@ func (h *BookingServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "company_service_id is required", http.StatusBadRequest)
 		return
 	}
+	if input.Quantity != nil && *input.Quantity <= 0 {
+		http.Error(w, "quantity must be positive", http.StatusBadRequest)
+		return
+	}
+
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "user not authenticated", http.StatusUnauthorized)
+		return
+	}
 
-	bookingService := models.BookingService{
-		BookingID:        input.BookingID,
-		CompanyServiceID: input.CompanyServiceID,
-		Notes:            input.Notes,
+	booking, err := h.bookingRepo.GetByID(input.BookingID)
+	if err != nil {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return
+	}
+	if !h.policy.CanActAsBookingCustomer(subject, booking) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !models.BookingLinesEditable(booking.Status) {
+		http.Error(w, "cannot add services to booking with status: "+booking.Status, http.StatusConflict)
+		return
 	}
 
-	if err := h.repo.Create(&bookingService); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	companyService, err := h.companyServiceRepo.GetByID(input.CompanyServiceID)
+	if err != nil {
+		http.Error(w, "company service not found", http.StatusNotFound)
 		return
 	}
+	if !companyService.Company.Verified() {
+		http.Error(w, errCompanyNotVerified.Error(), http.StatusConflict)
+		return
+	}
+
+	waitlist := false
+	var bookingService models.BookingService
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		bookings := h.bookingRepo.WithTx(tx)
+		lines := h.repo.WithTx(tx)
 
-	// Уведомляем владельца компании о новом бронировании
-	var cs models.CompanyService
-	if err := h.db.Preload("Company").Preload("Service").
-		First(&cs, "company_service_id = ?", input.CompanyServiceID).Error; err == nil {
-		var booking models.Booking
-		if err := h.db.Preload("User").First(&booking, "booking_id = ?", input.BookingID).Error; err == nil {
-			userName := "Пользователь"
-			if booking.User != nil {
-				userName = booking.User.Name
+		// Бронь и её строки перечитываются в транзакции: проверка мощности и
+		// вставка строки не должны разойтись с параллельным запросом.
+		current, err := bookings.GetByID(input.BookingID)
+		if err != nil {
+			return err
+		}
+		booking = current
+		if !models.BookingLinesEditable(booking.Status) {
+			return errBookingLinesLocked
+		}
+		existing, err := lines.GetByBookingID(input.BookingID)
+		if err != nil {
+			return err
+		}
+
+		if start, end, scheduled := repository.BookingWindow(booking); scheduled {
+			units := 1
+			if input.Quantity != nil {
+				units = *input.Quantity
+			}
+			for _, bs := range existing {
+				if bs.CompanyServiceID != input.CompanyServiceID {
+					continue
+				}
+				if bs.Quantity != nil && *bs.Quantity > 0 {
+					units += *bs.Quantity
+				} else {
+					units++
+				}
 			}
-			h.db.Create(&models.Notification{
-				UserID:  cs.Company.UserID,
-				Title:   "Новое бронирование",
+
+			err := h.companyServiceRepo.CheckAvailability(tx, input.CompanyServiceID, start, end, units, input.BookingID)
+			var conflict *repository.AvailabilityConflictError
+			switch {
+			case errors.As(err, &conflict) && input.Waitlist:
+				waitlist = true
+			case err != nil:
+				return err
+			}
+		}
+
+		unitPrice, currency := models.PriceSnapshot(companyService)
+		bookingService = models.BookingService{
+			BookingID:        input.BookingID,
+			CompanyServiceID: input.CompanyServiceID,
+			Notes:            input.Notes,
+			Quantity:         input.Quantity,
+			Status:           booking.Status,
+			UnitPrice:        unitPrice,
+			Currency:         currency,
+		}
+		bookingService.LineTotal = bookingService.ComputeLineTotal()
+
+		// Бронь организации, уже прошедшая согласование, не может подорожать
+		// сверх согласованной суммы.
+		if booking.OrgID != nil {
+			projected := *booking
+			projected.ApplyTotals(append(existing, bookingService))
+			exceeds, err := exceedsApproval(h.orgRepo.WithTx(tx), booking, projected.Total)
+			if err != nil {
+				return err
+			}
+			if exceeds {
+				return errExceedsApproval
+			}
+		}
+
+		if err := lines.Create(&bookingService); err != nil {
+			return err
+		}
+		if err := bookings.RecalculateTotals(input.BookingID); err != nil {
+			return err
+		}
+
+		// В лист ожидания уходит только новая строка: строки других услуг,
+		// которым мощности хватает, остаются в заявке.
+		if waitlist && booking.Status == models.BookingStatusRequested {
+			reason := "недостаточно свободной мощности на выбранные даты"
+			if err := bookings.UpdateLinesStatus(input.BookingID, []int64{bookingService.BookingServiceID},
+				models.BookingStatusWaitlisted, models.BookingActorCustomer, subject.UserID, &reason); err != nil {
+				return err
+			}
+			bookingService.Status = models.BookingStatusWaitlisted
+		}
+		return nil
+	})
+	if err != nil {
+		var conflict *repository.AvailabilityConflictError
+		var transition *models.BookingTransitionError
+		switch {
+		case errors.Is(err, errBookingLinesLocked):
+			http.Error(w, "cannot add services to booking with status: "+booking.Status, http.StatusConflict)
+		case errors.Is(err, errExceedsApproval):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.As(err, &conflict):
+			writeAvailabilityError(w, err)
+		case errors.As(err, &transition):
+			writeBookingStatusError(w, err)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Уведомляем сотрудников компании о новом бронировании; строки брони,
+	// ждущей внутреннего согласования, исполнитель увидит после выпуска.
+	cs := companyService
+	var withUser models.Booking
+	if booking.Status != models.BookingStatusPendingApproval &&
+		h.db.Preload("User").First(&withUser, "booking_id = ?", input.BookingID).Error == nil {
+		userName := "Пользователь"
+		if withUser.User != nil {
+			userName = withUser.User.Name
+		}
+		title := "Новое бронирование"
+		if waitlist {
+			title = "Новое бронирование в листе ожидания"
+		}
+		var notifs []models.Notification
+		for _, memberID := range companyRecipients(h.db, cs.CompanyID) {
+			notifs = append(notifs, models.Notification{
+				UserID:  memberID,
+				Title:   title,
 				Message: userName + " забронировал услугу «" + cs.Service.Title + "» в компании «" + cs.Company.Name + "».",
 			})
 		}
+		if len(notifs) > 0 {
+			h.db.Create(&notifs)
+		}
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	// 202 — строка добавлена, но бронь ушла в лист ожидания.
+	if waitlist {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
 	json.NewEncoder(w).Encode(bookingService)
 }
 
@@ -4,20 +4,26 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/policy"
 	"oil-gas-service-booking/internal/http-server/repository"
 	"oil-gas-service-booking/internal/models"
 )
 
 type CompanyHandler struct {
-	repo *repository.CompanyRepository
+	repo   *repository.CompanyRepository
+	policy *policy.Policy
+	db     *gorm.DB
 }
 
-func NewCompanyHandler(repo *repository.CompanyRepository) *CompanyHandler {
-	return &CompanyHandler{repo: repo}
+func NewCompanyHandler(repo *repository.CompanyRepository, policy *policy.Policy, db *gorm.DB) *CompanyHandler {
+	return &CompanyHandler{repo: repo, policy: policy, db: db}
 }
 
 func (h *CompanyHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +45,8 @@ func (h *CompanyHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	company.UserID = userID
+	// Новая компания — черновик: заказчикам она станет видна после модерации.
+	setVerification(&company, verificationFields{Status: models.CompanyVerificationDraft})
 
 	if err := h.repo.Create(&company); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -50,12 +58,30 @@ func (h *CompanyHandler) Create(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(company)
 }
 
+// GetAll — проверенные компании. Администраторы и аудиторы видят все и
+// могут отфильтровать их по ?verification_status=.
 func (h *CompanyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	data, err := h.repo.GetAll()
+	subject, _ := policy.FromRequest(r)
+	staff := h.policy.CanViewUnverifiedCompanies(subject)
+	status := models.CompanyVerificationOK
+	if staff {
+		status = r.URL.Query().Get("verification_status")
+		if status != "" && !models.IsValidCompanyVerification(status) {
+			http.Error(w, "invalid verification_status", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := h.repo.GetAll(status)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !staff {
+		for i := range data {
+			hideReview(&data[i])
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(data)
@@ -74,107 +100,216 @@ func (h *CompanyHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(company)
-}
-
-func (h *CompanyHandler) Update(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	// Непроверенная компания для посторонних не существует.
+	subject, _ := policy.FromRequest(r)
+	visible, err := h.policy.CanViewCompany(subject, company)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	userID, _, ok := authmw.GetUserFromContext(r)
-	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	if !visible {
+		http.Error(w, "company not found", http.StatusNotFound)
 		return
 	}
-
-	company, err := h.repo.GetByID(id)
+	insider, err := h.policy.CanViewCompanyMembers(subject, company.CompanyID)
 	if err != nil {
-		http.Error(w, "company not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !insider {
+		hideReview(company)
+	}
 
-	if company.UserID != userID {
-		http.Error(w, "forbidden: not your company", http.StatusForbidden)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(company)
+}
+
+func (h *CompanyHandler) Update(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.ownedCompany(w, r)
+	if !ok {
 		return
 	}
+	identity := moderatedIdentityOf(company)
 
-	if err := json.NewDecoder(r.Body).Decode(company); err != nil {
+	var input CompanyUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	input.apply(company)
 
 	if company.Name == "" {
 		http.Error(w, "name is required", http.StatusBadRequest)
 		return
 	}
 
-	company.UserID = userID
+	// Проверенная или ждущая проверки компания, сменившая название, описание
+	// или адрес, снова уходит модератору. Правки персонала не перепроверяются.
+	subject, _ := policy.FromRequest(r)
+	resubmit := (company.VerificationStatus == models.CompanyVerificationOK || company.VerificationStatus == models.CompanyVerificationPending) &&
+		moderatedIdentityOf(company) != identity && !subject.Can(models.PermCompaniesManageAll)
+	if resubmit {
+		now := time.Now()
+		company.VerificationStatus = models.CompanyVerificationPending
+		company.SubmittedAt = &now
+	}
 
 	if err := h.repo.Update(company); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if resubmit {
+		h.notifyModerators(company, "Компания «"+company.Name+"» изменила профиль и ждёт повторной проверки.")
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(company)
 }
 
+// Delete удаляет компанию; менеджеру это не доступно, только владельцу.
 func (h *CompanyHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	company, ok := h.ownedCompany(w, r)
+	if !ok {
+		return
+	}
+	subject, _ := policy.FromRequest(r)
+	isOwner, err := h.policy.CanManageMembers(subject, company.CompanyID)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isOwner {
+		http.Error(w, "forbidden: only the company owner can delete it", http.StatusForbidden)
+		return
+	}
+
+	if err := h.repo.Delete(company.CompanyID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CompanyHandler) GetMy(w http.ResponseWriter, r *http.Request) {
 	userID, _, ok := authmw.GetUserFromContext(r)
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	company, err := h.repo.GetByID(id)
+	mine, err := h.repo.GetByMember(userID)
 	if err != nil {
-		http.Error(w, "company not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if company.UserID != userID {
-		http.Error(w, "forbidden: not your company", http.StatusForbidden)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(mine)
+}
+
+// GetRouting отдаёт регионы работы компании и категории заявок, от которых
+// владелец отказался.
+func (h *CompanyHandler) GetRouting(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.ownedCompany(w, r)
+	if !ok {
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	routing, err := h.repo.GetRouting(company.CompanyID)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(routing)
 }
 
-func (h *CompanyHandler) GetMy(w http.ResponseWriter, r *http.Request) {
-	userID, _, ok := authmw.GetUserFromContext(r)
+// UpdateRouting заменяет переданные списки; отсутствующее поле не меняется.
+func (h *CompanyHandler) UpdateRouting(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.ownedCompany(w, r)
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	all, err := h.repo.GetAll()
-	if err != nil {
+	var body struct {
+		Regions            []string `json:"regions"`
+		OptedOutCategories []string `json:"opted_out_categories"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	regions := uniqueTrimmed(body.Regions, false)
+	optOuts := uniqueTrimmed(body.OptedOutCategories, true)
+	if err := h.repo.SetRouting(company.CompanyID, regions, optOuts); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var mine []models.Company
-	for _, c := range all {
-		if c.UserID == userID {
-			mine = append(mine, c)
-		}
+	routing, err := h.repo.GetRouting(company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(mine)
+	_ = json.NewEncoder(w).Encode(routing)
+}
+
+// ownedCompany загружает компанию из URL и проверяет по policy, что
+// пользователь может ею управлять.
+func (h *CompanyHandler) ownedCompany(w http.ResponseWriter, r *http.Request) (*models.Company, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	company, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	allowed, err := h.policy.CanManageCompany(subject, company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed {
+		http.Error(w, "forbidden: not your company", http.StatusForbidden)
+		return nil, false
+	}
+	return company, true
+}
+
+// uniqueTrimmed убирает пустые значения и дубликаты; nil остаётся nil.
+func uniqueTrimmed(values []string, lower bool) []string {
+	if values == nil {
+		return nil
+	}
+	result := []string{}
+	seen := map[string]bool{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if lower {
+			v = strings.ToLower(v)
+		}
+		key := strings.ToLower(v)
+		if v == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, v)
+	}
+	return result
 }
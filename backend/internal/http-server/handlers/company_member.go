@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/mailer"
+	"oil-gas-service-booking/internal/models"
+)
+
+// invitationTTL — срок действия приглашения в компанию.
+const invitationTTL = 7 * 24 * time.Hour
+
+// CompanyMemberHandler — сотрудники компании и приглашения по email.
+type CompanyMemberHandler struct {
+	repo        *repository.CompanyMemberRepo
+	companyRepo *repository.CompanyRepository
+	db          *gorm.DB
+	policy      *policy.Policy
+	mailer      mailer.Mailer
+	appURL      string
+}
+
+func NewCompanyMemberHandler(
+	repo *repository.CompanyMemberRepo,
+	companyRepo *repository.CompanyRepository,
+	db *gorm.DB,
+	policy *policy.Policy,
+	mail mailer.Mailer,
+	appURL string,
+) *CompanyMemberHandler {
+	return &CompanyMemberHandler{repo: repo, companyRepo: companyRepo, db: db, policy: policy, mailer: mail, appURL: appURL}
+}
+
+// InvitationResponse — приглашение с вычисленным статусом. Одноразовый токен
+// уходит только в письме приглашённому, в базе хранится его хеш.
+type InvitationResponse struct {
+	models.CompanyInvitation
+	Status string `json:"status"`
+}
+
+func invitationView(inv models.CompanyInvitation) InvitationResponse {
+	return InvitationResponse{CompanyInvitation: inv, Status: inv.Status(time.Now())}
+}
+
+func (h *CompanyMemberHandler) List(w http.ResponseWriter, r *http.Request) {
+	company, subject, ok := h.loadCompany(w, r)
+	if !ok {
+		return
+	}
+	allowed, err := h.policy.CanViewCompanyMembers(subject, company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "forbidden: not a member of this company", http.StatusForbidden)
+		return
+	}
+
+	members, err := h.repo.List(company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateRole меняет роль сотрудника. Основного владельца понизить нельзя.
+func (h *CompanyMemberHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !models.IsValidMemberRole(body.Role) {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.UpdateRole(company.CompanyID, userID, body.Role); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	h.db.Create(&models.Notification{
+		UserID:  userID,
+		Title:   "Роль в компании изменена",
+		Message: "Ваша роль в компании «" + company.Name + "»: " + body.Role + ".",
+	})
+
+	members, err := h.repo.List(company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// Remove исключает сотрудника. Сотрудник может и сам выйти из компании.
+func (h *CompanyMemberHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	company, subject, ok := h.loadCompany(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if userID != subject.UserID {
+		allowed, err := h.policy.CanManageMembers(subject, company.CompanyID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "forbidden: only the company owner can manage members", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := h.repo.Remove(company.CompanyID, userID); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TransferOwnership передаёт компанию другому пользователю. Новый владелец
+// получает роль owner, прежний остаётся менеджером.
+func (h *CompanyMemberHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	company, subject, ok := h.loadCompany(w, r)
+	if !ok {
+		return
+	}
+	if !h.policy.CanTransferOwnership(subject, company) {
+		http.Error(w, "forbidden: only the primary owner or an administrator can transfer the company", http.StatusForbidden)
+		return
+	}
+	var body struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prevOwnerID, err := h.repo.TransferOwnership(company.CompanyID, body.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrAlreadyOwner), errors.Is(err, repository.ErrOwnerInactive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.db.Create(securityEvent(r, models.SecurityEventOwnerChanged, &body.UserID, "", &subject.UserID,
+		"company "+strconv.FormatInt(company.CompanyID, 10)+" from user "+strconv.FormatInt(prevOwnerID, 10)))
+	h.db.Create([]models.Notification{
+		{
+			UserID:  body.UserID,
+			Title:   "Вы владелец компании",
+			Message: "Компания «" + company.Name + "» передана вам.",
+		},
+		{
+			UserID:  prevOwnerID,
+			Title:   "Компания передана",
+			Message: "Компания «" + company.Name + "» передана другому владельцу, вы остаётесь в ней менеджером.",
+		},
+	})
+
+	members, err := h.repo.List(company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// Invite создаёт приглашение и отправляет ссылку с токеном на email. Если
+// пользователь с таким email уже зарегистрирован, он также получает
+// уведомление в приложении. Если письмо не ушло, приглашение отзывается.
+func (h *CompanyMemberHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+	subject, _ := policy.FromRequest(r)
+
+	var body struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+	if _, err := mail.ParseAddress(email); err != nil {
+		http.Error(w, "invalid email", http.StatusBadRequest)
+		return
+	}
+	if body.Role == "" {
+		body.Role = models.MemberRoleViewer
+	}
+	if !models.IsValidMemberRole(body.Role) {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	inv := models.CompanyInvitation{
+		CompanyID:       company.CompanyID,
+		Email:           email,
+		Role:            body.Role,
+		InvitedByUserID: subject.UserID,
+	}
+	token, err := h.repo.CreateInvitation(&inv, invitationTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Приглашение в компанию «" + company.Name + "»",
+		Body: "Здравствуйте!\n\nВас пригласили в компанию «" + company.Name + "» с ролью " + body.Role + ".\n" +
+			"Чтобы принять приглашение, войдите или зарегистрируйтесь с этим адресом и перейдите по ссылке:\n" +
+			h.appURL + "/accept-invitation?token=" + url.QueryEscape(token) + "\n\n" +
+			"Приглашение действует до " + inv.ExpiresAt.Format("02.01.2006 15:04") + ".",
+	}
+	if err := h.mailer.Send(r.Context(), msg); err != nil {
+		log.Printf("company members: не удалось отправить приглашение %d: %v", inv.InvitationID, err)
+		if err := h.repo.RevokeInvitation(company.CompanyID, inv.InvitationID); err != nil {
+			log.Printf("company members: не удалось отозвать приглашение %d: %v", inv.InvitationID, err)
+		}
+		http.Error(w, "failed to send invitation email", http.StatusBadGateway)
+		return
+	}
+
+	var invitee models.User
+	if h.db.Where("LOWER(email) = ?", email).First(&invitee).Error == nil {
+		h.db.Create(&models.Notification{
+			UserID: invitee.UserID,
+			Title:  "Приглашение в компанию",
+			Message: "Вас пригласили в компанию «" + company.Name + "» с ролью " + body.Role +
+				". Приглашение действует до " + inv.ExpiresAt.Format("02.01.2006") + ".",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitationView(inv))
+}
+
+func (h *CompanyMemberHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.repo.ListInvitations(company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]InvitationResponse, 0, len(list))
+	for _, inv := range list {
+		resp = append(resp, invitationView(inv))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *CompanyMemberHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.managedCompany(w, r)
+	if !ok {
+		return
+	}
+	invitationID, err := strconv.ParseInt(chi.URLParam(r, "invitationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid invitation id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.RevokeInvitation(company.CompanyID, invitationID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "pending invitation not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Accept принимает приглашение текущим пользователем. Новая роль в компании
+// действует сразу; если глобальная роль повысилась, прежний access-токен
+// отклоняется, и клиент получает новый через /auth/refresh.
+func (h *CompanyMemberHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, subject.UserID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	inv, err := h.repo.AcceptInvitation(body.Token, &user)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	var notifs []models.Notification
+	for _, memberID := range companyRecipients(h.db, inv.CompanyID) {
+		if memberID == user.UserID {
+			continue
+		}
+		notifs = append(notifs, models.Notification{
+			UserID:  memberID,
+			Title:   "Новый сотрудник",
+			Message: user.Name + " присоединился к компании «" + inv.Company.Name + "» с ролью " + inv.Role + ".",
+		})
+	}
+	if len(notifs) > 0 {
+		h.db.Create(&notifs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"company_id":   inv.CompanyID,
+		"company_name": inv.Company.Name,
+		"role":         inv.Role,
+	})
+}
+
+func (h *CompanyMemberHandler) loadCompany(w http.ResponseWriter, r *http.Request) (*models.Company, policy.Subject, bool) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, subject, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, subject, false
+	}
+	company, err := h.companyRepo.GetByID(id)
+	if err != nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return nil, subject, false
+	}
+	return company, subject, true
+}
+
+// managedCompany загружает компанию и проверяет, что пользователь может
+// управлять её сотрудниками.
+func (h *CompanyMemberHandler) managedCompany(w http.ResponseWriter, r *http.Request) (*models.Company, bool) {
+	company, subject, ok := h.loadCompany(w, r)
+	if !ok {
+		return nil, false
+	}
+	allowed, err := h.policy.CanManageMembers(subject, company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed {
+		http.Error(w, "forbidden: only the company owner can manage members", http.StatusForbidden)
+		return nil, false
+	}
+	return company, true
+}
+
+func writeMemberError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "member not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrPrimaryOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrInvitationInvalid):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, repository.ErrInvitationEmail):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/policy"
 	"oil-gas-service-booking/internal/http-server/repository"
 	"oil-gas-service-booking/internal/models"
 )
@@ -15,25 +19,45 @@ import (
 type CompanyServiceHandler struct {
 	repo        *repository.CompanyServiceRepo
 	companyRepo *repository.CompanyRepository
+	policy      *policy.Policy
 }
 
 func NewCompanyServiceHandler(
 	repo *repository.CompanyServiceRepo,
 	companyRepo *repository.CompanyRepository,
+	policy *policy.Policy,
 ) *CompanyServiceHandler {
 	return &CompanyServiceHandler{
 		repo:        repo,
 		companyRepo: companyRepo,
+		policy:      policy,
 	}
 }
 
 type CompanyServiceCreateRequest struct {
 	CompanyID int64 `json:"company_id"`
 	ServiceID int64 `json:"service_id"`
+	Capacity  *int  `json:"capacity,omitempty"`
 }
 
+// CompanyServiceUpdateRequest — изменяемые поля услуги компании; компания и
+// услуга каталога после создания не меняются.
+type CompanyServiceUpdateRequest struct {
+	Price    *float64 `json:"price,omitempty"`
+	Currency *string  `json:"currency,omitempty"`
+	Capacity *int     `json:"capacity,omitempty"`
+}
+
+type BlackoutCreateRequest struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Reason   *string   `json:"reason,omitempty"`
+}
+
+const maxAvailabilityRange = 366 * 24 * time.Hour
+
 func (h *CompanyServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
-	userID, role, ok := authmw.GetUserFromContext(r)
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
@@ -59,14 +83,29 @@ func (h *CompanyServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "company not found", http.StatusNotFound)
 		return
 	}
-	if company.UserID != userID && role != "admin" {
+	allowed, err := h.policy.CanManageCompany(subject, company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
 		http.Error(w, "forbidden: not your company", http.StatusForbidden)
 		return
 	}
 
+	capacity := 1
+	if input.Capacity != nil {
+		if *input.Capacity <= 0 {
+			http.Error(w, "capacity must be positive", http.StatusBadRequest)
+			return
+		}
+		capacity = *input.Capacity
+	}
+
 	cs := models.CompanyService{
 		CompanyID: input.CompanyID,
 		ServiceID: input.ServiceID,
+		Capacity:  capacity,
 	}
 
 	if err := h.repo.Create(&cs); err != nil {
@@ -111,58 +150,273 @@ func (h *CompanyServiceHandler) GetByServiceID(w http.ResponseWriter, r *http.Re
 }
 
 func (h *CompanyServiceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.ownedCompanyService(w, r)
+	if !ok {
+		return
+	}
+
+	var input CompanyServiceUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if input.Price != nil {
+		if *input.Price < 0 {
+			http.Error(w, "price must not be negative", http.StatusBadRequest)
+			return
+		}
+		cs.Price = input.Price
+	}
+	if input.Currency != nil {
+		if *input.Currency == "" {
+			http.Error(w, "currency must not be empty", http.StatusBadRequest)
+			return
+		}
+		cs.Currency = *input.Currency
+	}
+	if input.Capacity != nil {
+		if *input.Capacity <= 0 {
+			http.Error(w, "capacity must be positive", http.StatusBadRequest)
+			return
+		}
+		cs.Capacity = *input.Capacity
+	}
+
+	if err := h.repo.Update(cs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(cs)
+}
+
+func (h *CompanyServiceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.ownedCompanyService(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Delete(cs.CompanyServiceID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CompanyServiceHandler) GetMy(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := h.repo.GetByMember(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (h *CompanyServiceHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
+	from := time.Now().UTC().Truncate(time.Hour)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		if from, err = parseDateParam(fromStr); err != nil {
+			http.Error(w, "invalid from date format. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+			return
+		}
+	}
+
+	to := from.AddDate(0, 0, 30)
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		if to, err = parseDateParam(toStr); err != nil {
+			http.Error(w, "invalid to date format. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+			return
+		}
+		if len(toStr) == len("2006-01-02") {
+			to = to.Add(24 * time.Hour)
+		}
+	}
+
+	if !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxAvailabilityRange {
+		http.Error(w, "range must not exceed one year", http.StatusBadRequest)
+		return
+	}
+
 	cs, err := h.repo.GetByID(id)
 	if err != nil {
 		http.Error(w, "company service not found", http.StatusNotFound)
 		return
 	}
+	// Непроверенная компания для посторонних не существует, как и её расписание.
+	subject, _ := policy.FromRequest(r)
+	visible, err := h.policy.CanViewCompany(subject, &cs.Company)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "company service not found", http.StatusNotFound)
+		return
+	}
+
+	slots, err := h.repo.GetAvailability(id, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"company_service_id": id,
+		"capacity":           cs.Capacity,
+		"from":               from.UTC(),
+		"to":                 to.UTC(),
+		"slots":              slots,
+	})
+}
+
+func (h *CompanyServiceHandler) GetBlackouts(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.ownedCompanyService(w, r)
+	if !ok {
+		return
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(cs); err != nil {
+	list, err := h.repo.GetBlackouts(cs.CompanyServiceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (h *CompanyServiceHandler) CreateBlackout(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.ownedCompanyService(w, r)
+	if !ok {
+		return
+	}
+
+	var input BlackoutCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if input.StartsAt.IsZero() || input.EndsAt.IsZero() {
+		http.Error(w, "starts_at and ends_at are required", http.StatusBadRequest)
+		return
+	}
+	if !input.EndsAt.After(input.StartsAt) {
+		http.Error(w, "ends_at must be after starts_at", http.StatusBadRequest)
+		return
+	}
 
-	if err := h.repo.Update(cs); err != nil {
+	blackout := models.CompanyServiceBlackout{
+		CompanyServiceID: cs.CompanyServiceID,
+		StartsAt:         input.StartsAt.UTC(),
+		EndsAt:           input.EndsAt.UTC(),
+		Reason:           input.Reason,
+	}
+	if err := h.repo.CreateBlackout(&blackout); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	_ = json.NewEncoder(w).Encode(cs)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(blackout)
 }
 
-func (h *CompanyServiceHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+func (h *CompanyServiceHandler) DeleteBlackout(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.ownedCompanyService(w, r)
+	if !ok {
+		return
+	}
+
+	blackoutID, err := strconv.ParseInt(chi.URLParam(r, "blackoutId"), 10, 64)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		http.Error(w, "invalid blackout id", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	deleted, err := h.repo.DeleteBlackout(cs.CompanyServiceID, blackoutID)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !deleted {
+		http.Error(w, "blackout not found", http.StatusNotFound)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *CompanyServiceHandler) GetMy(w http.ResponseWriter, r *http.Request) {
-	userID, _, ok := authmw.GetUserFromContext(r)
+// ownedCompanyService загружает услугу компании из {id} и проверяет, что она
+// принадлежит компании, которой пользователь может управлять.
+func (h *CompanyServiceHandler) ownedCompanyService(w http.ResponseWriter, r *http.Request) (*models.CompanyService, bool) {
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
+		return nil, false
 	}
 
-	list, err := h.repo.GetByOwner(userID)
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	cs, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "company service not found", http.StatusNotFound)
+		return nil, false
+	}
+	allowed, err := h.policy.CanManageCompany(subject, cs.CompanyID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, false
+	}
+	if !allowed {
+		http.Error(w, "forbidden: not your company", http.StatusForbidden)
+		return nil, false
 	}
 
-	_ = json.NewEncoder(w).Encode(list)
+	return cs, true
+}
+
+// writeAvailabilityError отдаёт 409 при конфликте по мощности или blackout.
+func writeAvailabilityError(w http.ResponseWriter, err error) {
+	var conflict *repository.AvailabilityConflictError
+	switch {
+	case errors.As(err, &conflict):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":              "availability_conflict",
+			"reason":             conflict.Reason,
+			"message":            conflict.Error(),
+			"company_service_id": conflict.CompanyServiceID,
+			"start":              conflict.Start,
+			"end":                conflict.End,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
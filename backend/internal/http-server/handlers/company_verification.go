@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+const maxReviewNotesLen = 2000
+
+// errCompanyNotVerified — бронировать услуги и отвечать на заявки могут только
+// прошедшие модерацию компании.
+var errCompanyNotVerified = errors.New("company is not verified")
+
+// verificationFields — поля модерации, которые не меняются через профиль
+// компании, только через отправку на проверку и решение модератора.
+type verificationFields struct {
+	Status           string
+	ReviewNotes      *string
+	SubmittedAt      *time.Time
+	ReviewedAt       *time.Time
+	ReviewedByUserID *int64
+}
+
+func setVerification(c *models.Company, v verificationFields) {
+	c.VerificationStatus = v.Status
+	c.ReviewNotes = v.ReviewNotes
+	c.SubmittedAt = v.SubmittedAt
+	c.ReviewedAt = v.ReviewedAt
+	c.ReviewedByUserID = v.ReviewedByUserID
+}
+
+// moderatedIdentity — поля профиля, которые проверяет модератор.
+type moderatedIdentity struct {
+	Name, Description, Address string
+}
+
+func moderatedIdentityOf(c *models.Company) moderatedIdentity {
+	return moderatedIdentity{
+		Name:        strings.TrimSpace(c.Name),
+		Description: strings.TrimSpace(derefString(c.Description)),
+		Address:     strings.TrimSpace(derefString(c.Address)),
+	}
+}
+
+// hideReview убирает из ответа комментарий и автора проверки: их видят только
+// сотрудники компании и персонал.
+func hideReview(c *models.Company) {
+	c.ReviewNotes = nil
+	c.ReviewedByUserID = nil
+}
+
+// SubmitForReview отправляет черновик или отклонённую компанию на проверку и
+// уведомляет администраторов.
+func (h *CompanyHandler) SubmitForReview(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.ownedCompany(w, r)
+	if !ok {
+		return
+	}
+	if err := h.repo.SubmitForReview(company); err != nil {
+		writeVerificationError(w, err)
+		return
+	}
+
+	h.notifyModerators(company, "Компания «"+company.Name+"» отправлена на модерацию.")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(company)
+}
+
+// ModerationQueue — очередь модерации; ?status= (по умолчанию pending_review)
+// позволяет посмотреть отклонённые или черновики.
+func (h *CompanyHandler) ModerationQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.CompanyVerificationPending
+	}
+	if !models.IsValidCompanyVerification(status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	companies, err := h.repo.ModerationQueue(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(companies)
+}
+
+// Review — решение модератора: verified или rejected. Для отказа нужен
+// комментарий, который увидит владелец. Владельцы компании получают
+// уведомление о решении.
+func (h *CompanyHandler) Review(w http.ResponseWriter, r *http.Request) {
+	reviewerID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Decision string `json:"decision"`
+		Notes    string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Decision != models.CompanyVerificationOK && body.Decision != models.CompanyVerificationRejected {
+		http.Error(w, "decision must be verified or rejected", http.StatusBadRequest)
+		return
+	}
+	notes := strings.TrimSpace(body.Notes)
+	if body.Decision == models.CompanyVerificationRejected && notes == "" {
+		http.Error(w, "notes are required when rejecting", http.StatusBadRequest)
+		return
+	}
+	if len([]rune(notes)) > maxReviewNotesLen {
+		http.Error(w, "notes are too long", http.StatusBadRequest)
+		return
+	}
+
+	company, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+
+	var notesPtr *string
+	if notes != "" {
+		notesPtr = &notes
+	}
+	if err := h.repo.Review(company, reviewerID, body.Decision, notesPtr); err != nil {
+		writeVerificationError(w, err)
+		return
+	}
+	h.notifyOwners(company)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(company)
+}
+
+func (h *CompanyHandler) notifyModerators(company *models.Company, message string) {
+	moderators, err := h.repo.ModeratorIDs()
+	if err != nil || len(moderators) == 0 {
+		return
+	}
+	notifs := make([]models.Notification, 0, len(moderators))
+	for _, id := range moderators {
+		notifs = append(notifs, models.Notification{
+			UserID:     id,
+			Title:      "Компания ждёт проверки",
+			Message:    message,
+			ActionType: models.NotificationActionCompanyReview,
+			ActionData: strconv.FormatInt(company.CompanyID, 10),
+		})
+	}
+	h.db.Create(&notifs)
+}
+
+func (h *CompanyHandler) notifyOwners(company *models.Company) {
+	owners, err := h.repo.OwnerIDs(company.CompanyID)
+	if err != nil || len(owners) == 0 {
+		return
+	}
+	title := "Компания прошла проверку"
+	message := "Компания «" + company.Name + "» проверена и теперь видна заказчикам."
+	if company.VerificationStatus == models.CompanyVerificationRejected {
+		title = "Компания не прошла проверку"
+		message = "Компания «" + company.Name + "» отклонена модератором. Исправьте профиль и отправьте " +
+			"его на проверку повторно. Комментарий модератора: " + derefString(company.ReviewNotes)
+	} else if company.ReviewNotes != nil {
+		message += " Комментарий модератора: " + *company.ReviewNotes
+	}
+	notifs := make([]models.Notification, 0, len(owners))
+	for _, id := range owners {
+		notifs = append(notifs, models.Notification{
+			UserID:     id,
+			Title:      title,
+			Message:    message,
+			ActionType: models.NotificationActionCompanyVerification,
+			ActionData: strconv.FormatInt(company.CompanyID, 10),
+		})
+	}
+	h.db.Create(&notifs)
+}
+
+func writeVerificationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrVerificationState) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,749 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/mailer"
+	"oil-gas-service-booking/internal/models"
+)
+
+// CustomerOrgHandler — организации заказчиков и внутреннее согласование броней.
+type CustomerOrgHandler struct {
+	repo               *repository.CustomerOrgRepo
+	bookingRepo        *repository.BookingRepo
+	companyServiceRepo *repository.CompanyServiceRepo
+	db                 *gorm.DB
+	policy             *policy.Policy
+	mailer             mailer.Mailer
+	appURL             string
+}
+
+func NewCustomerOrgHandler(
+	repo *repository.CustomerOrgRepo,
+	bookingRepo *repository.BookingRepo,
+	companyServiceRepo *repository.CompanyServiceRepo,
+	db *gorm.DB,
+	policy *policy.Policy,
+	mail mailer.Mailer,
+	appURL string,
+) *CustomerOrgHandler {
+	return &CustomerOrgHandler{
+		repo:               repo,
+		bookingRepo:        bookingRepo,
+		companyServiceRepo: companyServiceRepo,
+		db:                 db,
+		policy:             policy,
+		mailer:             mail,
+		appURL:             appURL,
+	}
+}
+
+type CustomerOrgRequest struct {
+	Name             string   `json:"name"`
+	AutoApproveLimit *float64 `json:"auto_approve_limit"`
+}
+
+type CustomerOrgMemberRequest struct {
+	Email         string   `json:"email"`
+	Role          string   `json:"role"`
+	ApprovalLimit *float64 `json:"approval_limit"`
+}
+
+// CustomerOrgResponse — организация глазами сотрудника.
+type CustomerOrgResponse struct {
+	models.CustomerOrg
+	MyRole          string                             `json:"my_role"`
+	MyApprovalLimit *float64                           `json:"my_approval_limit"`
+	Members         []repository.CustomerOrgMemberView `json:"members"`
+}
+
+func (h *CustomerOrgHandler) Create(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input CustomerOrgRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if input.AutoApproveLimit != nil && *input.AutoApproveLimit < 0 {
+		http.Error(w, "auto_approve_limit must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	org := models.CustomerOrg{Name: input.Name, CreatedByUserID: subject.UserID}
+	if input.AutoApproveLimit != nil {
+		org.AutoApproveLimit = *input.AutoApproveLimit
+	}
+	if err := h.repo.Create(&org); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+
+	h.writeOrg(w, org.OrgID, subject.UserID, http.StatusCreated)
+}
+
+func (h *CustomerOrgHandler) GetMy(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	member, err := h.repo.MembershipOf(subject.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if member == nil {
+		http.Error(w, "you are not a member of a customer organization", http.StatusNotFound)
+		return
+	}
+
+	h.writeOrg(w, member.OrgID, subject.UserID, http.StatusOK)
+}
+
+func (h *CustomerOrgHandler) Update(w http.ResponseWriter, r *http.Request) {
+	member, ok := h.orgMember(w, r, true)
+	if !ok {
+		return
+	}
+
+	var input CustomerOrgRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	org := member.Org
+	if name := strings.TrimSpace(input.Name); name != "" {
+		org.Name = name
+	}
+	if input.AutoApproveLimit != nil {
+		if *input.AutoApproveLimit < 0 {
+			http.Error(w, "auto_approve_limit must not be negative", http.StatusBadRequest)
+			return
+		}
+		org.AutoApproveLimit = *input.AutoApproveLimit
+	}
+	if err := h.repo.Update(&org); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeOrg(w, org.OrgID, member.UserID, http.StatusOK)
+}
+
+// CustomerOrgInvitationResponse — приглашение в организацию с вычисленным
+// состоянием.
+type CustomerOrgInvitationResponse struct {
+	models.CustomerOrgInvitation
+	Status string `json:"status"`
+}
+
+// Invite приглашает сотрудника по email. В организацию пользователь попадает,
+// только приняв приглашение; ответ не зависит от того, зарегистрирован ли
+// адрес.
+func (h *CustomerOrgHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	admin, ok := h.orgMember(w, r, true)
+	if !ok {
+		return
+	}
+
+	var input CustomerOrgMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	if _, err := mail.ParseAddress(email); err != nil {
+		http.Error(w, "invalid email", http.StatusBadRequest)
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.OrgRoleEngineer
+	}
+	if !validOrgMemberInput(w, input) {
+		return
+	}
+
+	inv := models.CustomerOrgInvitation{
+		OrgID:           admin.OrgID,
+		Email:           email,
+		Role:            input.Role,
+		ApprovalLimit:   input.ApprovalLimit,
+		InvitedByUserID: admin.UserID,
+	}
+	token, err := h.repo.CreateInvitation(&inv, invitationTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Приглашение в организацию «" + admin.Org.Name + "»",
+		Body: "Здравствуйте!\n\nВас пригласили в организацию заказчика «" + admin.Org.Name + "» с ролью " + inv.Role + ".\n" +
+			"Брони сотрудников проходят внутреннее согласование по правилам организации.\n" +
+			"Чтобы принять приглашение, войдите или зарегистрируйтесь с этим адресом и перейдите по ссылке:\n" +
+			h.appURL + "/accept-org-invitation?token=" + url.QueryEscape(token) + "\n\n" +
+			"Приглашение действует до " + inv.ExpiresAt.Format("02.01.2006 15:04") + ".",
+	}
+	if err := h.mailer.Send(r.Context(), msg); err != nil {
+		log.Printf("customer orgs: не удалось отправить приглашение %d: %v", inv.InvitationID, err)
+		if err := h.repo.RevokeInvitation(inv.OrgID, inv.InvitationID); err != nil {
+			log.Printf("customer orgs: не удалось отозвать приглашение %d: %v", inv.InvitationID, err)
+		}
+		http.Error(w, "failed to send invitation email", http.StatusBadGateway)
+		return
+	}
+
+	var invitee models.User
+	if h.db.Where("LOWER(email) = ?", email).First(&invitee).Error == nil {
+		h.db.Create(&models.Notification{
+			UserID: invitee.UserID,
+			Title:  "Приглашение в организацию",
+			Message: "Вас пригласили в организацию «" + admin.Org.Name + "» с ролью " + inv.Role +
+				". Приглашение действует до " + inv.ExpiresAt.Format("02.01.2006") + ".",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CustomerOrgInvitationResponse{CustomerOrgInvitation: inv, Status: inv.Status(time.Now())})
+}
+
+// AcceptInvitation принимает приглашение в организацию текущим пользователем.
+func (h *CustomerOrgHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, subject.UserID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	inv, err := h.repo.AcceptInvitation(body.Token, &user)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+
+	var notifs []models.Notification
+	var adminIDs []int64
+	h.db.Model(&models.CustomerOrgMember{}).
+		Where("org_id = ? AND role = ? AND user_id <> ?", inv.OrgID, models.OrgRoleAdmin, user.UserID).
+		Pluck("user_id", &adminIDs)
+	for _, id := range adminIDs {
+		notifs = append(notifs, models.Notification{
+			UserID:  id,
+			Title:   "Новый сотрудник",
+			Message: user.Name + " присоединился к организации «" + inv.Org.Name + "» с ролью " + inv.Role + ".",
+		})
+	}
+	if len(notifs) > 0 {
+		h.db.Create(&notifs)
+	}
+
+	h.writeOrg(w, inv.OrgID, user.UserID, http.StatusOK)
+}
+
+func (h *CustomerOrgHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	admin, ok := h.orgMember(w, r, true)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var input CustomerOrgMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validOrgMemberInput(w, input) {
+		return
+	}
+
+	if err := h.repo.UpdateMember(admin.OrgID, userID, input.Role, input.ApprovalLimit); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+
+	h.writeOrg(w, admin.OrgID, admin.UserID, http.StatusOK)
+}
+
+// RemoveMember исключает сотрудника; сотрудник может и сам выйти из организации.
+func (h *CustomerOrgHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := h.orgMember(w, r, false)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	if userID != member.UserID && member.Role != models.OrgRoleAdmin {
+		http.Error(w, "forbidden: only organization admins can manage members", http.StatusForbidden)
+		return
+	}
+
+	if err := h.repo.RemoveMember(member.OrgID, userID); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PendingApprovals — брони организации, ждущие согласования. Каждая помечена,
+// может ли текущий сотрудник согласовать её окончательно.
+func (h *CustomerOrgHandler) PendingApprovals(w http.ResponseWriter, r *http.Request) {
+	member, ok := h.orgMember(w, r, false)
+	if !ok {
+		return
+	}
+	if !member.IsApprover() {
+		http.Error(w, "forbidden: only managers and admins approve bookings", http.StatusForbidden)
+		return
+	}
+
+	bookings, err := h.repo.PendingBookings(member.OrgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type pendingBooking struct {
+		models.Booking
+		CanApprove bool `json:"can_approve"`
+	}
+	resp := make([]pendingBooking, 0, len(bookings))
+	for _, b := range bookings {
+		own := b.UserID != nil && *b.UserID == member.UserID
+		resp = append(resp, pendingBooking{Booking: b, CanApprove: !own && member.CanApprove(b.Total)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetBookingApprovals отдаёт цепочку согласования брони: заказчику,
+// согласующим его организации и тем, кто видит все брони.
+func (h *CustomerOrgHandler) GetBookingApprovals(w http.ResponseWriter, r *http.Request) {
+	booking, member, ok := h.orgBooking(w, r)
+	if !ok {
+		return
+	}
+	subject, _ := policy.FromRequest(r)
+	if !h.policy.CanViewWholeBooking(subject, booking) && (member == nil || !member.IsApprover()) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	approvals, err := h.repo.Approvals(booking.BookingID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// Decide — решение согласующего по брони в pending_internal_approval.
+// Одобрение в пределах лимита выпускает бронь к исполнителям (в статус
+// requested, или approved для брони по выбранному предложению — тогда выбор
+// по заявке становится окончательным); если лимита не хватает, шаг
+// записывается как escalated и бронь ждёт согласующего с бо́льшим лимитом.
+// Отказ отменяет бронь и снова открывает заявку, по которой она создана. Автор брони может выпустить её
+// сам, только если согласование ему не требуется.
+func (h *CustomerOrgHandler) Decide(w http.ResponseWriter, r *http.Request) {
+	booking, member, ok := h.orgBooking(w, r)
+	if !ok {
+		return
+	}
+	if member == nil {
+		http.Error(w, "forbidden: not a member of the booking's organization", http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		Decision string  `json:"decision"`
+		Comment  *string `json:"comment"`
+		// Waitlist: при нехватке мощности выпустить бронь в лист ожидания.
+		Waitlist bool `json:"waitlist"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Decision != "approve" && body.Decision != "reject" {
+		http.Error(w, "decision must be approve or reject", http.StatusBadRequest)
+		return
+	}
+
+	if booking.Status != models.BookingStatusPendingApproval {
+		http.Error(w, "booking is not awaiting internal approval", http.StatusConflict)
+		return
+	}
+
+	own := booking.UserID != nil && *booking.UserID == member.UserID
+	approval := models.BookingApproval{
+		BookingID:      booking.BookingID,
+		OrgID:          member.OrgID,
+		ApproverUserID: member.UserID,
+		Total:          booking.Total,
+		Comment:        body.Comment,
+	}
+
+	if body.Decision == "reject" {
+		if !member.IsApprover() && !own {
+			http.Error(w, "forbidden: only managers and admins approve bookings", http.StatusForbidden)
+			return
+		}
+		approval.Decision = models.ApprovalDecisionRejected
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := h.repo.WithTx(tx).RecordDecision(&approval, models.BookingStatusCancelled, body.Comment); err != nil {
+				return err
+			}
+			return reopenPendingAward(tx, booking.BookingID)
+		})
+		if err != nil {
+			writeBookingStatusError(w, err)
+			return
+		}
+		if !own && booking.UserID != nil {
+			message := "Бронь №" + strconv.FormatInt(booking.BookingID, 10) + " не прошла внутреннее согласование и отменена."
+			if body.Comment != nil && *body.Comment != "" {
+				message += " Комментарий: " + *body.Comment
+			}
+			h.db.Create(&models.Notification{UserID: *booking.UserID, Title: "Бронь не согласована", Message: message})
+		}
+		h.writeBooking(w, booking.BookingID)
+		return
+	}
+
+	switch {
+	case own && member.Org.NeedsApproval(member, booking.Total):
+		http.Error(w, "forbidden: you cannot approve your own booking above your limit", http.StatusForbidden)
+		return
+	case !own && !member.IsApprover():
+		http.Error(w, "forbidden: only managers and admins approve bookings", http.StatusForbidden)
+		return
+	case !own && !member.CanApprove(booking.Total):
+		approval.Decision = models.ApprovalDecisionEscalated
+		if err := h.repo.RecordDecision(&approval, "", nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.notifyApprovers(booking, member.UserID, "Бронь №"+strconv.FormatInt(booking.BookingID, 10)+
+			" одобрена в пределах лимита и передана вам на согласование.")
+		h.writeBooking(w, booking.BookingID)
+		return
+	}
+
+	status := models.BookingStatusRequested
+	var awarded int64
+	if h.db.Model(&models.ServiceRequest{}).Where("awarded_booking_id = ?", booking.BookingID).Count(&awarded); awarded > 0 {
+		status = models.BookingStatusApproved
+	}
+	approval.Decision = models.ApprovalDecisionApproved
+	var (
+		awardedRequest *models.ServiceRequest
+		winner         *models.ServiceRequestResponse
+	)
+	// Мощность проверяется в одной транзакции с выпуском брони. В лист
+	// ожидания уходят только строки услуг, которым её не хватает. Бронь по
+	// выбранному предложению сразу подтверждается, поэтому проверяется и она,
+	// но в лист ожидания не уходит.
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		conflicted, err := capacityConflicts(tx, h.companyServiceRepo, booking.BookingID)
+		var conflict *repository.AvailabilityConflictError
+		waitlist := body.Waitlist && status == models.BookingStatusRequested
+		if err != nil && !(errors.As(err, &conflict) && waitlist) {
+			return err
+		}
+
+		if err := h.repo.WithTx(tx).RecordDecision(&approval, status, body.Comment); err != nil {
+			return err
+		}
+		if len(conflicted) > 0 {
+			reason := "недостаточно свободной мощности на выбранные даты"
+			if err := h.bookingRepo.WithTx(tx).UpdateLinesStatus(booking.BookingID, conflicted, models.BookingStatusWaitlisted, models.BookingActorCustomer, member.UserID, &reason); err != nil {
+				return err
+			}
+		}
+		awardedRequest, winner, err = finalizePendingAward(tx, booking.BookingID)
+		return err
+	})
+	if err != nil {
+		var conflict *repository.AvailabilityConflictError
+		if errors.As(err, &conflict) {
+			writeAvailabilityError(w, err)
+			return
+		}
+		writeBookingStatusError(w, err)
+		return
+	}
+
+	released, err := h.bookingRepo.GetWithServices(booking.BookingID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	services := make(map[int64]models.CompanyService, len(released.BookingServices))
+	for _, bs := range released.BookingServices {
+		services[bs.CompanyServiceID] = bs.CompanyService
+	}
+	var notifs []models.Notification
+	if awardedRequest != nil {
+		// Бронь по заявке: исполнители узнают о выборе только сейчас.
+		notifs = awardNotifications(h.db, awardedRequest, winner, booking.BookingID)
+	} else {
+		notifs = *checkoutNotifications(h.db, released, released.BookingServices, services)
+	}
+	if !own && booking.UserID != nil {
+		notifs = append(notifs, models.Notification{
+			UserID:  *booking.UserID,
+			Title:   "Бронь согласована",
+			Message: "Бронь №" + strconv.FormatInt(booking.BookingID, 10) + " прошла внутреннее согласование и передана исполнителям.",
+		})
+	}
+	if len(notifs) > 0 {
+		h.db.Create(&notifs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(released)
+}
+
+// notifyApprovers сообщает согласующим, которым хватает лимита, об
+// эскалированной брони. Автор брони и exceptUserID уведомление не получают.
+func (h *CustomerOrgHandler) notifyApprovers(booking *models.Booking, exceptUserID int64, message string) {
+	notifs := approvalRequestNotifications(h.repo, booking, true, exceptUserID, message)
+	if len(notifs) > 0 {
+		h.db.Create(&notifs)
+	}
+}
+
+// approvalRequestNotifications — запросы на согласование брони. При первой
+// постановке в ожидание (withinLimit=false) уведомляются все менеджеры:
+// цепочку может начать и менеджер с меньшим лимитом.
+func approvalRequestNotifications(repo *repository.CustomerOrgRepo, booking *models.Booking, withinLimit bool, exceptUserID int64, message string) []models.Notification {
+	if booking.OrgID == nil {
+		return nil
+	}
+	approvers, err := repo.Approvers(*booking.OrgID, booking.Total, withinLimit, exceptUserID)
+	if err != nil {
+		return nil
+	}
+	notifs := make([]models.Notification, 0, len(approvers))
+	for _, id := range approvers {
+		if booking.UserID != nil && id == *booking.UserID {
+			continue
+		}
+		notifs = append(notifs, models.Notification{
+			UserID:  id,
+			Title:   "Бронь ждёт согласования",
+			Message: message,
+		})
+	}
+	return notifs
+}
+
+// holdMessage — текст запроса на согласование брони.
+func holdMessage(booking *models.Booking, requester string) string {
+	message := requester + " оформил бронь №" + strconv.FormatInt(booking.BookingID, 10)
+	if booking.Total != nil {
+		message += " на сумму " + strconv.FormatFloat(*booking.Total, 'f', 2, 64) + " " + booking.Currency
+	}
+	return message + ". Требуется внутреннее согласование."
+}
+
+// exceedsApproval сообщает, что уже выпущенная бронь организации после
+// изменения будет стоить newTotal — больше, чем было согласовано, — и автор
+// не может согласовать такую сумму сам. Такое изменение требует новой брони.
+func exceedsApproval(repo *repository.CustomerOrgRepo, booking *models.Booking, newTotal *float64) (bool, error) {
+	if booking.OrgID == nil || booking.UserID == nil || booking.Status == models.BookingStatusPendingApproval {
+		return false, nil
+	}
+	member, err := repo.MembershipOf(*booking.UserID)
+	if err != nil || member == nil || member.OrgID != *booking.OrgID {
+		return false, err
+	}
+	if !member.Org.NeedsApproval(member, newTotal) {
+		return false, nil
+	}
+	approved, err := repo.ApprovedTotal(booking.BookingID)
+	if err != nil {
+		return false, err
+	}
+	return approved == nil || newTotal == nil || *newTotal > *approved, nil
+}
+
+// orgMember загружает членство текущего пользователя в организации из URL.
+// adminOnly — действие доступно только администраторам организации.
+func (h *CustomerOrgHandler) orgMember(w http.ResponseWriter, r *http.Request, adminOnly bool) (*models.CustomerOrgMember, bool) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	orgID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	member, err := h.repo.MembershipOf(subject.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if member == nil || member.OrgID != orgID {
+		http.Error(w, "forbidden: not a member of this organization", http.StatusForbidden)
+		return nil, false
+	}
+	if adminOnly && member.Role != models.OrgRoleAdmin {
+		http.Error(w, "forbidden: only organization admins can do this", http.StatusForbidden)
+		return nil, false
+	}
+	return member, true
+}
+
+// orgBooking загружает бронь из URL и членство текущего пользователя в её
+// организации (nil, если он в ней не состоит).
+func (h *CustomerOrgHandler) orgBooking(w http.ResponseWriter, r *http.Request) (*models.Booking, *models.CustomerOrgMember, bool) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	booking, err := h.bookingRepo.GetByID(id)
+	if err != nil {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if booking.OrgID == nil {
+		return booking, nil, true
+	}
+
+	member, err := h.repo.MembershipOf(subject.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if member == nil || member.OrgID != *booking.OrgID {
+		member = nil
+	}
+	return booking, member, true
+}
+
+func (h *CustomerOrgHandler) writeOrg(w http.ResponseWriter, orgID, userID int64, status int) {
+	org, err := h.repo.GetByID(orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	members, err := h.repo.Members(orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := CustomerOrgResponse{CustomerOrg: *org, Members: members}
+	for _, m := range members {
+		if m.UserID == userID {
+			resp.MyRole, resp.MyApprovalLimit = m.Role, m.ApprovalLimit
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *CustomerOrgHandler) writeBooking(w http.ResponseWriter, bookingID int64) {
+	booking, err := h.bookingRepo.GetWithServices(bookingID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(booking)
+}
+
+func validOrgMemberInput(w http.ResponseWriter, input CustomerOrgMemberRequest) bool {
+	if !models.IsValidOrgRole(input.Role) {
+		http.Error(w, "role must be admin, manager or engineer", http.StatusBadRequest)
+		return false
+	}
+	if input.ApprovalLimit != nil && *input.ApprovalLimit < 0 {
+		http.Error(w, "approval_limit must not be negative", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeOrgError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "member not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvitationInvalid):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, repository.ErrInvitationEmail):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repository.ErrAlreadyInOrg), errors.Is(err, repository.ErrLastOrgAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
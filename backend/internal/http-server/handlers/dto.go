@@ -1,15 +1,213 @@
 package handlers
 
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"oil-gas-service-booking/internal/models"
+)
+
 type ServiceCreateRequest struct {
-	Title string `json:"title" example:"Диагностика оборудования"`
+	Title    string  `json:"title" example:"Диагностика оборудования"`
+	Category *string `json:"category,omitempty" example:"drilling"`
 }
 
 type CompanyCreateRequest struct {
 	Name string `json:"name" example:"Газпром сервис"`
 }
 
-type BookingCreateRequest struct {
-	UserID      *int64  `json:"user_id,omitempty"`
+// CompanyUpdateRequest — поля профиля компании, которые правит владелец или
+// менеджер. Не переданное поле не меняется.
+type CompanyUpdateRequest struct {
+	Name        *string `json:"name,omitempty" example:"Газпром сервис"`
 	Description *string `json:"description,omitempty"`
-	Status      string  `json:"status,omitempty" example:"requested"`
+	Address     *string `json:"address,omitempty"`
+}
+
+func (in *CompanyUpdateRequest) apply(c *models.Company) {
+	if in.Name != nil {
+		c.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Description != nil {
+		c.Description = in.Description
+	}
+	if in.Address != nil {
+		c.Address = in.Address
+	}
+}
+
+type BookingCreateRequest struct {
+	UserID           *int64     `json:"user_id,omitempty"`
+	Description      *string    `json:"description,omitempty"`
+	Status           string     `json:"status,omitempty" example:"requested"`
+	ScheduledStart   *time.Time `json:"scheduled_start,omitempty" example:"2026-11-01T08:00:00+05:00"`
+	ScheduledEnd     *time.Time `json:"scheduled_end,omitempty" example:"2026-11-05T20:00:00+05:00"`
+	SiteAddress      *string    `json:"site_address,omitempty" example:"ХМАО, Сургутский р-н"`
+	SiteField        *string    `json:"site_field,omitempty" example:"Фёдоровское"`
+	SitePad          *string    `json:"site_pad,omitempty" example:"Куст 18"`
+	SiteWell         *string    `json:"site_well,omitempty" example:"437"`
+	SiteLatitude     *float64   `json:"site_latitude,omitempty" example:"61.7436"`
+	SiteLongitude    *float64   `json:"site_longitude,omitempty" example:"73.5916"`
+	SiteContactName  *string    `json:"site_contact_name,omitempty" example:"Иванов И.И."`
+	SiteContactPhone *string    `json:"site_contact_phone,omitempty" example:"+7 900 000-00-00"`
+}
+
+func (in *BookingCreateRequest) Validate(now time.Time) error {
+	if in.ScheduledEnd != nil && in.ScheduledStart == nil {
+		return errors.New("scheduled_start is required when scheduled_end is set")
+	}
+	if in.ScheduledStart != nil {
+		if in.ScheduledStart.Before(now) {
+			return errors.New("scheduled_start must not be in the past")
+		}
+		if in.ScheduledEnd != nil && !in.ScheduledEnd.After(*in.ScheduledStart) {
+			return errors.New("scheduled_end must be after scheduled_start")
+		}
+	}
+
+	return validateSiteCoordinates(in.SiteLatitude, in.SiteLongitude)
+}
+
+func validateSiteCoordinates(lat, lon *float64) error {
+	if (lat == nil) != (lon == nil) {
+		return errors.New("site_latitude and site_longitude must be set together")
+	}
+	if lat != nil && (*lat < -90 || *lat > 90) {
+		return errors.New("site_latitude must be between -90 and 90")
+	}
+	if lon != nil && (*lon < -180 || *lon > 180) {
+		return errors.New("site_longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+type BookingCheckoutItem struct {
+	CompanyServiceID int64   `json:"company_service_id" example:"12"`
+	Quantity         *int    `json:"quantity,omitempty" example:"1"`
+	Notes            *string `json:"notes,omitempty"`
+}
+
+type BookingCheckoutRequest struct {
+	BookingCreateRequest
+	Items []BookingCheckoutItem `json:"items"`
+	// Waitlist: при нехватке мощности создать бронь в листе ожидания вместо отказа.
+	Waitlist bool `json:"waitlist,omitempty"`
+}
+
+// BookingUpdateRequest — поля шапки брони, которые администратор может
+// править. Имена полей совпадают с моделью: клиент шлёт их так же, как
+// получает бронь. Суммы, валюта, заказчик и организация не редактируются.
+type BookingUpdateRequest struct {
+	Description      *string
+	Status           string
+	ScheduledStart   *time.Time
+	ScheduledEnd     *time.Time
+	SiteAddress      *string
+	SiteField        *string
+	SitePad          *string
+	SiteWell         *string
+	SiteLatitude     *float64
+	SiteLongitude    *float64
+	SiteContactName  *string
+	SiteContactPhone *string
+	Reason           *string `json:"reason"`
+}
+
+// bookingUpdateRequestOf заполняет запрос текущими значениями брони, чтобы
+// поля, не переданные клиентом, не менялись.
+func bookingUpdateRequestOf(b *models.Booking) BookingUpdateRequest {
+	return BookingUpdateRequest{
+		Description:      b.Description,
+		Status:           b.Status,
+		ScheduledStart:   b.ScheduledStart,
+		ScheduledEnd:     b.ScheduledEnd,
+		SiteAddress:      b.SiteAddress,
+		SiteField:        b.SiteField,
+		SitePad:          b.SitePad,
+		SiteWell:         b.SiteWell,
+		SiteLatitude:     b.SiteLatitude,
+		SiteLongitude:    b.SiteLongitude,
+		SiteContactName:  b.SiteContactName,
+		SiteContactPhone: b.SiteContactPhone,
+	}
+}
+
+func (in *BookingUpdateRequest) Validate() error {
+	if in.ScheduledEnd != nil && in.ScheduledStart == nil {
+		return errors.New("ScheduledStart is required when ScheduledEnd is set")
+	}
+	if in.ScheduledStart != nil && in.ScheduledEnd != nil && !in.ScheduledEnd.After(*in.ScheduledStart) {
+		return errors.New("ScheduledEnd must be after ScheduledStart")
+	}
+	return validateSiteCoordinates(in.SiteLatitude, in.SiteLongitude)
+}
+
+func (in *BookingUpdateRequest) apply(b *models.Booking) {
+	b.Description = in.Description
+	b.Status = in.Status
+	b.ScheduledStart = utcPtr(in.ScheduledStart)
+	b.ScheduledEnd = utcPtr(in.ScheduledEnd)
+	b.SiteAddress = in.SiteAddress
+	b.SiteField = in.SiteField
+	b.SitePad = in.SitePad
+	b.SiteWell = in.SiteWell
+	b.SiteLatitude = in.SiteLatitude
+	b.SiteLongitude = in.SiteLongitude
+	b.SiteContactName = in.SiteContactName
+	b.SiteContactPhone = in.SiteContactPhone
+}
+
+// ServiceRequestCreateRequest — запрос предложений (RFQ): что нужно, в каком
+// объёме, где и до какого срока компании могут присылать предложения.
+type ServiceRequestCreateRequest struct {
+	ServiceName   string     `json:"service_name"`
+	Category      *string    `json:"category,omitempty"`
+	Region        *string    `json:"region,omitempty"`
+	Comment       *string    `json:"comment,omitempty"`
+	Scope         *string    `json:"scope,omitempty"`
+	Quantity      *int       `json:"quantity,omitempty" example:"1"`
+	SiteAddress   *string    `json:"site_address,omitempty"`
+	SiteField     *string    `json:"site_field,omitempty"`
+	SitePad       *string    `json:"site_pad,omitempty"`
+	SiteWell      *string    `json:"site_well,omitempty"`
+	SiteLatitude  *float64   `json:"site_latitude,omitempty"`
+	SiteLongitude *float64   `json:"site_longitude,omitempty"`
+	Deadline      *time.Time `json:"deadline,omitempty"`
+}
+
+func (in *ServiceRequestCreateRequest) Validate(now time.Time) error {
+	if in.ServiceName == "" {
+		return errors.New("service_name is required")
+	}
+	if in.Quantity != nil && *in.Quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if in.Deadline != nil && !in.Deadline.After(now) {
+		return errors.New("deadline must be in the future")
+	}
+	return validateSiteCoordinates(in.SiteLatitude, in.SiteLongitude)
+}
+
+// ServiceRequestBidRequest — отклик компании на заявку. Цена указывается за
+// единицу; без цены отклик остаётся информационным и не может быть выбран.
+type ServiceRequestBidRequest struct {
+	CompanyID    int64    `json:"company_id"`
+	Price        *float64 `json:"price,omitempty"`
+	Currency     string   `json:"currency,omitempty"`
+	LeadTimeDays *int     `json:"lead_time_days,omitempty"`
+	Comment      *string  `json:"comment,omitempty"`
+}
+
+func (in *ServiceRequestBidRequest) Validate() error {
+	if in.CompanyID == 0 {
+		return errors.New("company_id is required")
+	}
+	if in.Price != nil && *in.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	if in.LeadTimeDays != nil && *in.LeadTimeDays < 0 {
+		return errors.New("lead_time_days must not be negative")
+	}
+	return nil
 }
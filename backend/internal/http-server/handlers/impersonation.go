@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+const maxImpersonationReason = 500
+
+// ImpersonationHandler — вход администратора от имени пользователя для
+// разбора обращений. Токен имперсонации только для чтения, а каждый запрос
+// с ним журналируется (см. middleware.ImpersonationAuditor).
+type ImpersonationHandler struct {
+	repo     *repository.ImpersonationRepo
+	users    *repository.UserRepo
+	tokens   *repository.TokenRepo
+	security *repository.SecurityRepo
+	ttl      time.Duration
+}
+
+func NewImpersonationHandler(
+	repo *repository.ImpersonationRepo,
+	users *repository.UserRepo,
+	tokens *repository.TokenRepo,
+	security *repository.SecurityRepo,
+	ttl time.Duration,
+) *ImpersonationHandler {
+	return &ImpersonationHandler{repo: repo, users: users, tokens: tokens, security: security, ttl: ttl}
+}
+
+type ImpersonationResponse struct {
+	Session   models.ImpersonationSession `json:"session"`
+	Token     string                      `json:"token"`
+	ExpiresIn int                         `json:"expires_in"`
+}
+
+// Start выдаёт токен от имени пользователя. Причина обязательна; других
+// администраторов и неактивных пользователей имперсонировать нельзя.
+func (h *ImpersonationHandler) Start(w http.ResponseWriter, r *http.Request) {
+	adminID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if userID == adminID {
+		http.Error(w, "you cannot impersonate yourself", http.StatusForbidden)
+		return
+	}
+	var in struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" || len([]rune(in.Reason)) > maxImpersonationReason {
+		http.Error(w, "reason is required (up to 500 characters)", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.users.GetByID(userID)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if !user.Active() {
+		http.Error(w, "user is suspended or deactivated", http.StatusConflict)
+		return
+	}
+	if models.RoleHasPermission(user.Role, models.PermUsersImpersonate) {
+		http.Error(w, "administrators cannot be impersonated", http.StatusForbidden)
+		return
+	}
+
+	token, jti, err := authmw.GenerateImpersonationToken(user.UserID, user.Role, adminID, h.ttl)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	session := models.ImpersonationSession{
+		AdminUserID: adminID,
+		UserID:      user.UserID,
+		Reason:      in.Reason,
+		TokenID:     jti,
+		ExpiresAt:   time.Now().UTC().Add(h.ttl),
+	}
+	if err := h.repo.Create(&session); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logEvent(r, models.SecurityEventImpersonation, user.UserID, adminID, in.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ImpersonationResponse{
+		Session:   session,
+		Token:     token,
+		ExpiresIn: int(h.ttl.Seconds()),
+	})
+}
+
+// End досрочно завершает сеанс и отзывает его токен.
+func (h *ImpersonationHandler) End(w http.ResponseWriter, r *http.Request) {
+	adminID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	session, ok := h.loadSession(w, r)
+	if !ok {
+		return
+	}
+	if err := h.tokens.RevokeAccess(session.TokenID, session.UserID, session.ExpiresAt); err != nil {
+		http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.repo.End(session.SessionID); err != nil {
+		if errors.Is(err, repository.ErrImpersonationEnded) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logEvent(r, models.SecurityEventImpersonationEnd, session.UserID, adminID,
+		"session "+strconv.FormatInt(session.SessionID, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List — сеансы имперсонации; ?admin_id=, ?user_id=, ?active=true.
+func (h *ImpersonationHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filter repository.ImpersonationFilter
+	for name, dst := range map[string]**int64{"admin_id": &filter.AdminUserID, "user_id": &filter.UserID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = &id
+		}
+	}
+	filter.ActiveOnly = q.Get("active") == "true"
+
+	list, err := h.repo.List(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// Requests — журнал запросов сеанса.
+func (h *ImpersonationHandler) Requests(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.loadSession(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.repo.Requests(session.SessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *ImpersonationHandler) loadSession(w http.ResponseWriter, r *http.Request) (*models.ImpersonationSession, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+	session, err := h.repo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "impersonation session not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return session, true
+}
+
+func (h *ImpersonationHandler) logEvent(r *http.Request, eventType string, userID, adminID int64, detail string) {
+	if err := h.security.LogEvent(securityEvent(r, eventType, &userID, "", &adminID, detail)); err != nil {
+		log.Printf("impersonation: не удалось записать событие %s: %v", eventType, err)
+	}
+}
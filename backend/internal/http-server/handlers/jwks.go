@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+)
+
+// JWKSHandler публикует открытые ключи подписи, чтобы другие сервисы могли
+// проверять наши токены без общего секрета.
+type JWKSHandler struct{}
+
+func NewJWKSHandler() *JWKSHandler {
+	return &JWKSHandler{}
+}
+
+func (h *JWKSHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": authmw.PublicJWKS(),
+	})
+}
@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/mailer"
+	"oil-gas-service-booking/internal/models"
+	"oil-gas-service-booking/internal/totp"
+)
+
+const (
+	// mfaChallengeTTL — сколько ждём код второго фактора после верного пароля.
+	mfaChallengeTTL = 5 * time.Minute
+	// totpIssuer — название сервиса в приложении-аутентификаторе.
+	totpIssuer = "OilGas Service Booking"
+)
+
+// MFAChallengeResponse — ответ на верный пароль, когда нужен второй фактор.
+// EnrollmentRequired: 2FA обязательна для роли, но ещё не подключена —
+// сначала POST /auth/login/2fa/setup, затем код из приложения.
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int64  `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+func (h *AuthHandler) startMFA(w http.ResponseWriter, r *http.Request, user *models.User, enroll bool) {
+	raw, err := h.mfa.CreateChallenge(user.UserID, mfaChallengeTTL)
+	if err != nil {
+		http.Error(w, "failed to start two-factor login", http.StatusInternalServerError)
+		return
+	}
+	h.logSecurity(r, models.SecurityEventMFAChallenged, &user.UserID, derefString(user.Email), nil, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAChallengeResponse{
+		MFARequired:        true,
+		EnrollmentRequired: enroll,
+		MFAToken:           raw,
+		ExpiresIn:          int64(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// LoginMFASetup выдаёт секрет для обязательной 2FA прямо на шаге входа,
+// когда у пользователя её ещё нет.
+func (h *AuthHandler) LoginMFASetup(w http.ResponseWriter, r *http.Request) {
+	var in MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	challenge, user, ok := h.loadChallenge(w, in.MFAToken)
+	if !ok {
+		return
+	}
+	if !models.RoleRequiresMFA(user.Role) {
+		http.Error(w, "set up two-factor authentication from your profile", http.StatusConflict)
+		return
+	}
+
+	secret, err := h.mfa.BeginSetup(challenge.UserID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	writeMFASetup(w, user, secret)
+}
+
+// LoginMFA — второй шаг входа: код из приложения или резервный код. JWT
+// выдаётся только здесь. Если 2FA подключалась на этом же входе, в ответе
+// есть резервные коды.
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var in MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.Code == "" && in.RecoveryCode == "" {
+		http.Error(w, "code or recovery_code required", http.StatusBadRequest)
+		return
+	}
+	challenge, user, ok := h.loadChallenge(w, in.MFAToken)
+	if !ok {
+		return
+	}
+	email := derefString(user.Email)
+
+	block, err := h.security.CheckLogin(clientIP(r), email)
+	if err != nil {
+		http.Error(w, "failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if block != nil {
+		h.logSecurity(r, models.SecurityEventLoginThrottled, &user.UserID, email, nil, block.Scope)
+		writeLoginBlock(w, block)
+		return
+	}
+
+	enabled, err := h.mfa.Enabled(user.UserID)
+	if err != nil {
+		http.Error(w, "failed to check two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	var recoveryCodes []string
+	switch {
+	case !enabled && in.Code != "":
+		recoveryCodes, err = h.mfa.Confirm(user.UserID, in.Code)
+	case !enabled:
+		err = repository.ErrMFANotEnrolled
+	case in.RecoveryCode != "":
+		err = h.mfa.UseRecoveryCode(user.UserID, in.RecoveryCode)
+	default:
+		err = h.mfa.VerifyCode(user.UserID, in.Code)
+	}
+	if errors.Is(err, repository.ErrMFAInvalidCode) {
+		h.mfa.FailChallenge(challenge.ChallengeID)
+		if _, err := h.security.RecordLoginFailure(clientIP(r), email); err != nil {
+			log.Printf("auth: не удалось учесть неудачный вход: %v", err)
+		}
+		h.logSecurity(r, models.SecurityEventMFAFailed, &user.UserID, email, nil, "")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	if err := h.mfa.CompleteChallenge(challenge.ChallengeID); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	switch {
+	case recoveryCodes != nil:
+		h.logSecurity(r, models.SecurityEventMFAEnabled, &user.UserID, email, nil, "at login")
+	case in.RecoveryCode != "":
+		h.logSecurity(r, models.SecurityEventRecoveryUsed, &user.UserID, email, nil, "")
+	}
+	h.logSecurity(r, models.SecurityEventLoginSucceeded, &user.UserID, email, nil, "2fa")
+
+	response, err := h.issueTokens(r, user, "", 0)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// MFAStatus — состояние второго фактора текущего пользователя.
+func (h *AuthHandler) MFAStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := h.mfa.Get(user.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	left, err := h.mfa.RecoveryCodesLeft(user.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"enabled":             t != nil && t.ConfirmedAt != nil,
+		"required":            models.RoleRequiresMFA(user.Role),
+		"recovery_codes_left": left,
+	}
+	if t != nil && t.ConfirmedAt != nil {
+		resp["confirmed_at"] = t.ConfirmedAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// MFASetup начинает подключение 2FA: секрет и otpauth-ссылка для QR-кода.
+func (h *AuthHandler) MFASetup(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := h.mfa.BeginSetup(user.UserID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	writeMFASetup(w, user, secret)
+}
+
+// MFAConfirm завершает подключение первым кодом из приложения и выдаёт
+// резервные коды. Показываются они только в этом ответе.
+func (h *AuthHandler) MFAConfirm(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var in struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.mfa.Confirm(user.UserID, in.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	h.logSecurity(r, models.SecurityEventMFAEnabled, &user.UserID, derefString(user.Email), nil, "")
+	h.notifyMFAChange(r.Context(), user, "Двухфакторная аутентификация включена",
+		"Для входа в вашу учётную запись теперь нужен код из приложения-аутентификатора.")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// MFADisable отключает 2FA по паролю и текущему (или резервному) коду.
+// Для ролей с обязательной 2FA отключение запрещено.
+func (h *AuthHandler) MFADisable(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var in struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if models.RoleRequiresMFA(user.Role) {
+		http.Error(w, "two-factor authentication is mandatory for your role", http.StatusForbidden)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.Password)); err != nil {
+		http.Error(w, "invalid password", http.StatusForbidden)
+		return
+	}
+	if !h.verifySecondFactor(w, r, user, in.Code, in.RecoveryCode) {
+		return
+	}
+
+	if err := h.mfa.Disable(user.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logSecurity(r, models.SecurityEventMFADisabled, &user.UserID, derefString(user.Email), nil, "")
+	h.notifyMFAChange(r.Context(), user, "Двухфакторная аутентификация отключена",
+		"Для входа в вашу учётную запись снова достаточно пароля.")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MFARecoveryCodes выдаёт новый набор резервных кодов; прежние перестают
+// действовать.
+func (h *AuthHandler) MFARecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var in struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.verifySecondFactor(w, r, user, in.Code, "") {
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(user.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// ResetUserMFA — сброс 2FA пользователя администратором, например при потере
+// телефона. Сессии пользователя завершаются; действие попадает в журнал
+// безопасности вместе с администратором и причиной.
+func (h *AuthHandler) ResetUserMFA(w http.ResponseWriter, r *http.Request) {
+	actorID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if id == actorID {
+		http.Error(w, "another admin must reset your two-factor authentication", http.StatusForbidden)
+		return
+	}
+	var in struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	enabled, err := h.mfa.Enabled(user.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !enabled {
+		http.Error(w, "two-factor authentication is not enabled for this user", http.StatusConflict)
+		return
+	}
+
+	if err := h.mfa.Disable(user.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.tokens.RevokeAllForUser(user.UserID); err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	h.logSecurity(r, models.SecurityEventMFAReset, &user.UserID, derefString(user.Email), &actorID, in.Reason)
+
+	h.db.Create(&models.Notification{
+		UserID:  user.UserID,
+		Title:   "Двухфакторная аутентификация сброшена",
+		Message: "Администратор сбросил вашу двухфакторную аутентификацию. Войдите заново и подключите её снова.",
+	})
+	h.notifyMFAChange(r.Context(), &user, "Двухфакторная аутентификация сброшена",
+		"Администратор сбросил двухфакторную аутентификацию вашей учётной записи, все сеансы завершены. "+
+			"Если вы об этом не просили, свяжитесь с поддержкой.")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySecondFactor проверяет код подключённой 2FA или резервный код перед
+// изменением её настроек.
+func (h *AuthHandler) verifySecondFactor(w http.ResponseWriter, r *http.Request, user *models.User, code, recoveryCode string) bool {
+	var err error
+	switch {
+	case recoveryCode != "":
+		err = h.mfa.UseRecoveryCode(user.UserID, recoveryCode)
+	case code != "":
+		err = h.mfa.VerifyCode(user.UserID, code)
+	default:
+		http.Error(w, "code or recovery_code required", http.StatusBadRequest)
+		return false
+	}
+	if errors.Is(err, repository.ErrMFAInvalidCode) {
+		h.logSecurity(r, models.SecurityEventMFAFailed, &user.UserID, derefString(user.Email), nil, "settings")
+	}
+	if err != nil {
+		writeMFAError(w, err)
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) loadChallenge(w http.ResponseWriter, raw string) (*models.MFAChallenge, *models.User, bool) {
+	if raw == "" {
+		http.Error(w, "mfa_token required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	challenge, err := h.mfa.Challenge(raw)
+	if err != nil {
+		writeMFAError(w, err)
+		return nil, nil, false
+	}
+	var user models.User
+	if err := h.db.First(&user, challenge.UserID).Error; err != nil || !user.Active() {
+		writeMFAError(w, repository.ErrMFAChallengeInvalid)
+		return nil, nil, false
+	}
+	return challenge, &user, true
+}
+
+func (h *AuthHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return nil, false
+	}
+	return &user, true
+}
+
+func (h *AuthHandler) notifyMFAChange(ctx context.Context, user *models.User, subject, text string) {
+	if user.Email == nil {
+		return
+	}
+	h.send(ctx, mailer.Message{
+		To:      *user.Email,
+		Subject: subject,
+		Body:    "Здравствуйте, " + user.Name + "!\n\n" + text,
+	})
+}
+
+func writeMFASetup(w http.ResponseWriter, user *models.User, secret string) {
+	account := derefString(user.Email)
+	if account == "" {
+		account = user.Name
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, account, secret),
+	})
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrMFAInvalidCode):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, repository.ErrMFAChallengeInvalid):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, repository.ErrMFAAlreadyEnabled), errors.Is(err, repository.ErrMFANotEnrolled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+const (
+	notificationsDefaultLimit = 20
+	notificationsMaxLimit     = 100
+)
+
+// NotificationHandler работает только с уведомлениями текущего пользователя:
+// все запросы строятся от policy.Notifications.
+type NotificationHandler struct {
+	policy *policy.Policy
+}
+
+func NewNotificationHandler(policy *policy.Policy) *NotificationHandler {
+	return &NotificationHandler{policy: policy}
+}
+
+type NotificationPage struct {
+	Items      []models.Notification `json:"items"`
+	NextCursor *uint                 `json:"next_cursor"`
+}
+
+type NotificationMarkReadRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// GetMy возвращает уведомления текущего пользователя от новых к старым.
+// Пагинация курсорная: cursor — notification_id последнего полученного элемента.
+func (h *NotificationHandler) GetMy(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+
+	limit := notificationsDefaultLimit
+	if limitStr := q.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > notificationsMaxLimit {
+			l = notificationsMaxLimit
+		}
+		limit = l
+	}
+
+	query := h.policy.Notifications(subject)
+
+	if cursorStr := q.Get("cursor"); cursorStr != "" {
+		cursor, err := strconv.ParseUint(cursorStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("notification_id < ?", cursor)
+	}
+
+	if isReadStr := q.Get("is_read"); isReadStr != "" {
+		isRead, err := strconv.ParseBool(isReadStr)
+		if err != nil {
+			http.Error(w, "is_read must be true or false", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("is_read = ?", isRead)
+	}
+
+	if actionType, present := q["action_type"]; present {
+		if !models.NotificationActionTypes[actionType[0]] {
+			http.Error(w, "unknown action_type", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("action_type = ?", actionType[0])
+	}
+
+	if requestIDStr := q.Get("request_id"); requestIDStr != "" {
+		requestID, err := strconv.ParseInt(requestIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid request_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("request_id = ?", requestID)
+	}
+
+	var list []models.Notification
+	if err := query.Order("notification_id DESC").Limit(limit + 1).Find(&list).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := NotificationPage{Items: list}
+	if len(list) > limit {
+		page.Items = list[:limit]
+		next := page.Items[limit-1].NotificationID
+		page.NextCursor = &next
+	}
+	if page.Items == nil {
+		page.Items = []models.Notification{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var count int64
+	if err := h.policy.Notifications(subject).
+		Where("is_read = ?", false).
+		Count(&count).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"count": count})
+}
+
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	res := h.policy.Notifications(subject).
+		Where("notification_id = ?", id).
+		Update("is_read", true)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "notification not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead помечает прочитанными все уведомления пользователя, а если в теле
+// передан список ids — только перечисленные. Чужие id молча игнорируются.
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body NotificationMarkReadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := h.policy.Notifications(subject).Where("is_read = ?", false)
+	if body.IDs != nil {
+		if len(body.IDs) == 0 {
+			http.Error(w, "ids must not be empty", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("notification_id IN ?", body.IDs)
+	}
+
+	res := query.Update("is_read", true)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": res.RowsAffected})
+}
+
+func (h *NotificationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	res := h.policy.Notifications(subject).Where("notification_id = ?", id).Delete(&models.Notification{})
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "notification not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// companyRecipients — сотрудники компаний companyIDs без повторов, по
+// возрастанию user_id. Уведомления компании получают все её сотрудники;
+// при ошибке чтения уведомление просто не рассылается.
+func companyRecipients(db *gorm.DB, companyIDs ...int64) []int64 {
+	members, err := repository.CompanyMemberUserIDs(db, companyIDs)
+	if err != nil {
+		return nil
+	}
+	seen := map[int64]bool{}
+	var users []int64
+	for _, ids := range members {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				users = append(users, id)
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+	return users
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+// PrivacyHandler — выгрузка персональных данных и запросы на их удаление
+// (152-ФЗ). Само удаление выполняет jobs.Eraser после ErasureGrace.
+type PrivacyHandler struct {
+	repo         *repository.PrivacyRepo
+	users        *repository.UserRepo
+	security     *repository.SecurityRepo
+	uploadsDir   string
+	erasureGrace time.Duration
+}
+
+func NewPrivacyHandler(
+	repo *repository.PrivacyRepo,
+	users *repository.UserRepo,
+	security *repository.SecurityRepo,
+	uploadsDir string,
+	erasureGrace time.Duration,
+) *PrivacyHandler {
+	return &PrivacyHandler{repo: repo, users: users, security: security, uploadsDir: uploadsDir, erasureGrace: erasureGrace}
+}
+
+// Export отдаёт данные пользователя: ?format=json — один JSON, по умолчанию
+// ZIP с data.json и файлом аватара.
+func (h *PrivacyHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "json" {
+		http.Error(w, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.repo.Export(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logEvent(r, models.SecurityEventDataExported, userID, format)
+
+	name := "personal-data-" + strconv.FormatInt(userID, 10) + "-" + data.ExportedAt.Format("20060102")
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	zw := zip.NewWriter(w)
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "data.json", Method: zip.Deflate, Modified: data.ExportedAt})
+	if err != nil {
+		log.Printf("privacy: выгрузка %d: %v", userID, err)
+		return
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		log.Printf("privacy: выгрузка %d: %v", userID, err)
+		return
+	}
+	if file := h.avatarFile(data.Profile.AvatarURL); file != "" {
+		if err := addFileToZip(zw, file, "avatar"+filepath.Ext(file), data.ExportedAt); err != nil {
+			log.Printf("privacy: аватар %d: %v", userID, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("privacy: выгрузка %d: %v", userID, err)
+	}
+}
+
+// avatarFile переводит /uploads/avatars/... в путь на диске; ссылки вне
+// uploads/avatars не выгружаются.
+func (h *PrivacyHandler) avatarFile(url *string) string {
+	if url == nil || !strings.HasPrefix(*url, "/uploads/avatars/") {
+		return ""
+	}
+	rel := path.Clean(strings.TrimPrefix(*url, "/uploads/"))
+	if !strings.HasPrefix(rel, "avatars/") {
+		return ""
+	}
+	return filepath.Join(h.uploadsDir, filepath.FromSlash(rel))
+}
+
+func addFileToZip(zw *zip.Writer, file, name string, modified time.Time) error {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+// ErasureStatus — ожидающий запрос на удаление или 404.
+func (h *PrivacyHandler) ErasureStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	req, err := h.repo.PendingErasure(userID)
+	if err != nil {
+		writeErasureError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// RequestErasure ставит удаление данных в очередь. Нужен пароль; до
+// scheduled_for запрос можно отменить.
+func (h *PrivacyHandler) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var in struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := h.users.GetByID(userID)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.Password)); err != nil {
+		http.Error(w, "invalid password", http.StatusForbidden)
+		return
+	}
+	companies, err := h.users.PrimaryOwnedCompanies(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(companies) > 0 {
+		http.Error(w, repository.ErrOwnsCompanies.Error(), http.StatusConflict)
+		return
+	}
+	if err := h.users.CheckNotSoleOrgAdmin(userID); err != nil {
+		if errors.Is(err, repository.ErrSoleOrgAdmin) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	req, err := h.repo.RequestErasure(userID, h.erasureGrace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logEvent(r, models.SecurityEventErasureRequest, userID, "scheduled for "+req.ScheduledFor.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(req)
+}
+
+func (h *PrivacyHandler) CancelErasure(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.repo.CancelErasure(userID); err != nil {
+		writeErasureError(w, err)
+		return
+	}
+	h.logEvent(r, models.SecurityEventErasureCancel, userID, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ErasureRequests — очередь удаления для администратора; ?status= фильтрует.
+func (h *PrivacyHandler) ErasureRequests(w http.ResponseWriter, r *http.Request) {
+	list, err := h.repo.ErasureRequests(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *PrivacyHandler) logEvent(r *http.Request, eventType string, userID int64, detail string) {
+	if err := h.security.LogEvent(securityEvent(r, eventType, &userID, "", nil, detail)); err != nil {
+		log.Printf("privacy: не удалось записать событие %s: %v", eventType, err)
+	}
+}
+
+func writeErasureError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrNoErasureRequest) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
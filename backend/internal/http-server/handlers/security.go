@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+// SecurityHandler — блокировки входа и журнал безопасности для администраторов.
+type SecurityHandler struct {
+	repo *repository.SecurityRepo
+}
+
+func NewSecurityHandler(repo *repository.SecurityRepo) *SecurityHandler {
+	return &SecurityHandler{repo: repo}
+}
+
+func (h *SecurityHandler) LockedAccounts(w http.ResponseWriter, r *http.Request) {
+	list, err := h.repo.LockedAccounts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []repository.LockedAccountView{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// Unlock снимает блокировку учётной записи и записывает это в журнал.
+func (h *SecurityHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	counter, err := h.repo.Unlock(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "lock not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var userID *int64
+	var user models.User
+	if h.repo.UserByEmail(counter.Key, &user) == nil {
+		userID = &user.UserID
+	}
+	h.repo.LogEvent(securityEvent(r, models.SecurityEventAccountUnlocked, userID, counter.Key, &subject.UserID, ""))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Events — журнал безопасности. Фильтры: type, user_id, email, ip, since,
+// until (RFC 3339) и limit.
+func (h *SecurityHandler) Events(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := repository.SecurityEventFilter{
+		Type:  q.Get("type"),
+		Email: q.Get("email"),
+		IP:    q.Get("ip"),
+	}
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = &id
+	}
+	for name, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid "+name+": expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.repo.Events(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
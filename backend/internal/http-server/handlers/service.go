@@ -49,7 +49,8 @@ func (h *ServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	service := models.Service{
-		Title: input.Title,
+		Title:    input.Title,
+		Category: input.Category,
 	}
 
 	if err := h.repo.Create(&service); err != nil {
@@ -149,7 +150,7 @@ func (h *ServiceHandler) GetMy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	companies, err := h.companyRepo.GetAll()
+	companies, err := h.companyRepo.GetByMember(userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -157,9 +158,7 @@ func (h *ServiceHandler) GetMy(w http.ResponseWriter, r *http.Request) {
 
 	myCompanyIDs := map[int64]struct{}{}
 	for _, c := range companies {
-		if c.UserID == userID {
-			myCompanyIDs[c.CompanyID] = struct{}{}
-		}
+		myCompanyIDs[c.CompanyID] = struct{}{}
 	}
 
 	if len(myCompanyIDs) == 0 {
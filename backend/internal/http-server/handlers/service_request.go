@@ -2,24 +2,53 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/policy"
+	"oil-gas-service-booking/internal/http-server/repository"
 	"oil-gas-service-booking/internal/models"
 )
 
 type ServiceRequestHandler struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	bookingRepo        *repository.BookingRepo
+	matchingRepo       *repository.MatchingRepo
+	policy             *policy.Policy
+	orgRepo            *repository.CustomerOrgRepo
+	companyServiceRepo *repository.CompanyServiceRepo
 }
 
-func NewServiceRequestHandler(db *gorm.DB) *ServiceRequestHandler {
-	return &ServiceRequestHandler{db: db}
+func NewServiceRequestHandler(
+	db *gorm.DB,
+	bookingRepo *repository.BookingRepo,
+	matchingRepo *repository.MatchingRepo,
+	policy *policy.Policy,
+	orgRepo *repository.CustomerOrgRepo,
+	companyServiceRepo *repository.CompanyServiceRepo,
+) *ServiceRequestHandler {
+	return &ServiceRequestHandler{
+		db:                 db,
+		bookingRepo:        bookingRepo,
+		matchingRepo:       matchingRepo,
+		policy:             policy,
+		orgRepo:            orgRepo,
+		companyServiceRepo: companyServiceRepo,
+	}
 }
 
+const (
+	defaultMatchLimit = 5
+	maxMatchLimit     = 50
+)
+
 func (h *ServiceRequestHandler) Create(w http.ResponseWriter, r *http.Request) {
 	userID, _, ok := authmw.GetUserFromContext(r)
 	if !ok {
@@ -27,24 +56,37 @@ func (h *ServiceRequestHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		ServiceName string  `json:"service_name"`
-		Comment     *string `json:"comment"`
-	}
+	var body ServiceRequestCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if body.ServiceName == "" {
-		http.Error(w, "service_name is required", http.StatusBadRequest)
+	if err := body.Validate(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	quantity := 1
+	if body.Quantity != nil {
+		quantity = *body.Quantity
+	}
+
 	req := models.ServiceRequest{
-		UserID:      userID,
-		ServiceName: body.ServiceName,
-		Comment:     body.Comment,
-		Status:      "pending",
+		UserID:        userID,
+		ServiceName:   body.ServiceName,
+		Category:      body.Category,
+		Region:        body.Region,
+		Comment:       body.Comment,
+		Scope:         body.Scope,
+		Quantity:      quantity,
+		SiteAddress:   body.SiteAddress,
+		SiteField:     body.SiteField,
+		SitePad:       body.SitePad,
+		SiteWell:      body.SiteWell,
+		SiteLatitude:  body.SiteLatitude,
+		SiteLongitude: body.SiteLongitude,
+		Deadline:      utcPtr(body.Deadline),
+		Status:        models.ServiceRequestStatusPending,
 	}
 	if err := h.db.Create(&req).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -81,53 +123,138 @@ func (h *ServiceRequestHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(requests)
 }
 
-func (h *ServiceRequestHandler) NotifyCompanies(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+// PreviewMatches показывает администратору, каким компаниям уйдёт заявка
+// при рассылке с тем же limit, и оценку каждой из них.
+func (h *ServiceRequestHandler) PreviewMatches(w http.ResponseWriter, r *http.Request) {
+	req, limit, ok := h.matchingInput(w, r)
+	if !ok {
+		return
+	}
+
+	matches, err := h.matchingRepo.MatchCompanies(req)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	category, err := h.matchingRepo.RequestCategory(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var req models.ServiceRequest
-	if err := h.db.First(&req, "request_id = ?", id).Error; err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"category":     category,
+		"limit":        limit,
+		"candidates":   len(matches),
+		"would_notify": selectMatches(matches, limit),
+	})
+}
+
+// NotifyCompanies рассылает заявку top-N подходящим компаниям, которым она
+// ещё не отправлялась. Уведомление получают все сотрудники выбранных компаний;
+// сотрудник нескольких компаний получает одно уведомление.
+func (h *ServiceRequestHandler) NotifyCompanies(w http.ResponseWriter, r *http.Request) {
+	req, limit, ok := h.matchingInput(w, r)
+	if !ok {
 		return
 	}
+	id := req.RequestID
 
-	var ownerIDs []int64
-	if err := h.db.Model(&models.Company{}).Distinct("user_id").Pluck("user_id", &ownerIDs).Error; err != nil {
+	matches, err := h.matchingRepo.MatchCompanies(req)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	selected := selectMatches(matches, limit)
 
-	if len(ownerIDs) == 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]int{"notified": 0})
+	if err := h.matchingRepo.RecordInvitations(id, selected); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	notifications := make([]models.Notification, 0, len(ownerIDs))
-	for _, uid := range ownerIDs {
-		notifications = append(notifications, models.Notification{
-			UserID:     uid,
-			Title:      "Новый запрос услуги",
-			Message:    "Пользователи запрашивают услугу: «" + req.ServiceName + "». Рассмотрите возможность добавления её в ваш каталог.",
-			ActionType: "add_service",
-			RequestID:  &id,
-		})
+	companyIDs := make([]int64, 0, len(selected))
+	for _, m := range selected {
+		companyIDs = append(companyIDs, m.CompanyID)
 	}
-
-	if err := h.db.Create(&notifications).Error; err != nil {
+	members, err := repository.CompanyMemberUserIDs(h.db, companyIDs)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	notified := map[int64]bool{}
+	notifications := make([]models.Notification, 0, len(selected))
+	for _, m := range selected {
+		for _, userID := range members[m.CompanyID] {
+			if notified[userID] {
+				continue
+			}
+			notified[userID] = true
+			notifications = append(notifications, models.Notification{
+				UserID:     userID,
+				Title:      "Новый запрос услуги",
+				Message:    "Заказчик запрашивает услугу «" + req.ServiceName + "». Пришлите своё предложение с ценой и сроком выполнения.",
+				ActionType: models.NotificationActionSubmitBid,
+				RequestID:  &id,
+			})
+		}
+	}
+
+	if len(notifications) > 0 {
+		if err := h.db.Create(&notifications).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"notified": len(notifications)})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notified":  len(notifications),
+		"companies": selected,
+	})
+}
+
+func (h *ServiceRequestHandler) matchingInput(w http.ResponseWriter, r *http.Request) (*models.ServiceRequest, int, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	limit := defaultMatchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxMatchLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxMatchLimit), http.StatusBadRequest)
+			return nil, 0, false
+		}
+	}
+
+	var req models.ServiceRequest
+	if err := h.db.First(&req, "request_id = ?", id).Error; err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, 0, false
+	}
+	return &req, limit, true
+}
+
+// selectMatches берёт первые limit компаний, которым заявка ещё не рассылалась.
+func selectMatches(matches []repository.CompanyMatch, limit int) []repository.CompanyMatch {
+	selected := []repository.CompanyMatch{}
+	for _, m := range matches {
+		if len(selected) == limit {
+			break
+		}
+		if !m.AlreadyInvited {
+			selected = append(selected, m)
+		}
+	}
+	return selected
 }
 
 func (h *ServiceRequestHandler) Respond(w http.ResponseWriter, r *http.Request) {
-	userID, _, ok := authmw.GetUserFromContext(r)
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
@@ -139,74 +266,132 @@ func (h *ServiceRequestHandler) Respond(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var body struct {
-		CompanyID int64 `json:"company_id"`
+	var body ServiceRequestBidRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.CompanyID == 0 {
-		http.Error(w, "company_id is required", http.StatusBadRequest)
+	if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if body.Currency == "" {
+		body.Currency = models.DefaultCurrency
+	}
 
+	allowed, err := h.policy.CanOperateCompany(subject, body.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	var company models.Company
-	if err := h.db.First(&company, "company_id = ? AND user_id = ?", body.CompanyID, userID).Error; err != nil {
+	if !allowed || h.db.First(&company, "company_id = ?", body.CompanyID).Error != nil {
 		http.Error(w, "company not found", http.StatusForbidden)
 		return
 	}
+	if !company.Verified() {
+		http.Error(w, errCompanyNotVerified.Error(), http.StatusForbidden)
+		return
+	}
 
 	var req models.ServiceRequest
 	if err := h.db.First(&req, "request_id = ?", requestID).Error; err != nil {
 		http.Error(w, "request not found", http.StatusNotFound)
 		return
 	}
+	if !models.ServiceRequestOpen(req.Status) {
+		http.Error(w, "service request is closed: "+req.Status, http.StatusConflict)
+		return
+	}
+	if req.Deadline != nil && time.Now().After(*req.Deadline) {
+		http.Error(w, "bidding deadline has passed", http.StatusConflict)
+		return
+	}
 
-	var service models.Service
-	if err := h.db.Where("title = ?", req.ServiceName).First(&service).Error; err != nil {
-		service = models.Service{Title: req.ServiceName}
-		if err := h.db.Create(&service).Error; err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	// Отклик не публикует услугу в каталоге: позиция каталога появится,
+	// только если предложение выберут. Если у компании она уже есть,
+	// предложение ссылается на неё.
+	companyServiceID := companyCatalogEntry(h.db, body.CompanyID, req.ServiceName)
+
+	bidText := ""
+	if body.Price != nil {
+		bidText = " Цена: " + formatMoney(*body.Price) + " " + body.Currency + " за единицу"
+		if body.LeadTimeDays != nil {
+			bidText += ", срок " + strconv.Itoa(*body.LeadTimeDays) + " дн."
+		} else {
+			bidText += "."
 		}
 	}
 
-	var existing models.CompanyService
-	if err := h.db.Where("company_id = ? AND service_id = ?", body.CompanyID, service.ServiceID).First(&existing).Error; err != nil {
-		cs := models.CompanyService{CompanyID: body.CompanyID, ServiceID: service.ServiceID}
-		if err := h.db.Create(&cs).Error; err != nil {
+	var bid models.ServiceRequestResponse
+	if h.db.Where("request_id = ? AND company_id = ?", requestID, body.CompanyID).First(&bid).Error == nil {
+		// Повторный отклик обновляет предложение компании.
+		bid.CompanyServiceID = companyServiceID
+		bid.Price = body.Price
+		bid.Currency = body.Currency
+		bid.LeadTimeDays = body.LeadTimeDays
+		bid.Comment = body.Comment
+		if err := h.db.Save(&bid).Error; err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+
+		h.db.Create(&models.Notification{
+			UserID:    req.UserID,
+			Title:     "Предложение обновлено",
+			Message:   "Компания «" + company.Name + "» обновила предложение по заявке «" + req.ServiceName + "»." + bidText,
+			RequestID: &requestID,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bid)
+		return
 	}
 
-	var existingResp models.ServiceRequestResponse
-	if h.db.Where("request_id = ? AND company_id = ?", requestID, body.CompanyID).First(&existingResp).Error != nil {
-		h.db.Create(&models.ServiceRequestResponse{RequestID: requestID, CompanyID: body.CompanyID})
+	bid = models.ServiceRequestResponse{
+		RequestID:        requestID,
+		CompanyID:        body.CompanyID,
+		CompanyServiceID: companyServiceID,
+		Price:            body.Price,
+		Currency:         body.Currency,
+		LeadTimeDays:     body.LeadTimeDays,
+		Comment:          body.Comment,
+		Status:           models.BidStatusSubmitted,
+	}
+	if err := h.db.Create(&bid).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		h.db.Model(&models.ServiceRequest{}).Where("request_id = ? AND status = 'pending'", requestID).Update("status", "reviewed")
+	h.db.Model(&models.ServiceRequest{}).
+		Where("request_id = ? AND status = ?", requestID, models.ServiceRequestStatusPending).
+		Update("status", models.ServiceRequestStatusReviewed)
 
-		h.db.Create(&models.Notification{
-			UserID:     req.UserID,
-			Title:      "Услуга добавлена",
-			Message:    "Компания «" + company.Name + "» добавила услугу «" + req.ServiceName + "» в свой каталог. Теперь вы можете её забронировать.",
-			ActionType: "service_added",
-			ActionData: req.ServiceName,
-		})
+	h.db.Create(&models.Notification{
+		UserID:     req.UserID,
+		Title:      "Новое предложение",
+		Message:    "Компания «" + company.Name + "» прислала предложение по заявке «" + req.ServiceName + "»." + bidText,
+		ActionType: models.NotificationActionBidReceived,
+		RequestID:  &requestID,
+	})
 
-		var adminIDs []int64
-		h.db.Model(&models.User{}).Where("role = 'admin'").Pluck("user_id", &adminIDs)
-		if len(adminIDs) > 0 {
-			notifs := make([]models.Notification, 0, len(adminIDs))
-			for _, aid := range adminIDs {
-				notifs = append(notifs, models.Notification{
-					UserID:  aid,
-					Title:   "Компания откликнулась на заявку",
-					Message: "Компания «" + company.Name + "» добавила услугу «" + req.ServiceName + "» в свой каталог.",
-				})
-			}
-			h.db.Create(&notifs)
+	var adminIDs []int64
+	h.db.Model(&models.User{}).Where("role = 'admin'").Pluck("user_id", &adminIDs)
+	if len(adminIDs) > 0 {
+		notifs := make([]models.Notification, 0, len(adminIDs))
+		for _, aid := range adminIDs {
+			notifs = append(notifs, models.Notification{
+				UserID:  aid,
+				Title:   "Компания откликнулась на заявку",
+				Message: "Компания «" + company.Name + "» прислала предложение по заявке «" + req.ServiceName + "»." + bidText,
+			})
 		}
+		h.db.Create(&notifs)
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bid)
 }
 
 func (h *ServiceRequestHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
@@ -228,10 +413,458 @@ func (h *ServiceRequestHandler) UpdateStatus(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := h.db.Model(&models.ServiceRequest{}).Where("request_id = ?", id).Update("status", body.Status).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Заявку, по которой уже выбран исполнитель, вернуть в работу нельзя.
+	res := h.db.Model(&models.ServiceRequest{}).
+		Where("request_id = ? AND status NOT IN ?", id, []string{models.ServiceRequestStatusAwarded, models.ServiceRequestStatusAwardPending}).
+		Update("status", body.Status)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "service request not found or already awarded", http.StatusConflict)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+var errServiceRequestClosed = errors.New("service request is no longer open for award")
+
+// awardNotifications сообщает компаниям о выборе: победителю — о созданной
+// брони, остальным участникам — что выбрано другое предложение.
+// companyCatalogEntry возвращает позицию каталога компании для услуги с
+// названием title; nil — такой позиции нет.
+func companyCatalogEntry(db *gorm.DB, companyID int64, title string) *int64 {
+	var ids []int64
+	db.Model(&models.CompanyService{}).
+		Joins("JOIN service ON service.service_id = company_service.service_id").
+		Where("company_service.company_id = ? AND service.title = ?", companyID, title).
+		Limit(1).
+		Pluck("company_service.company_service_id", &ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	return &ids[0]
+}
+
+// awardCompanyService возвращает позицию каталога, по которой оформляется
+// выбранное предложение. Если у компании её нет, позиция создаётся по цене
+// предложения с мощностью на объём заявки и привязывается к нему.
+func awardCompanyService(tx *gorm.DB, req *models.ServiceRequest, winner *models.ServiceRequestResponse) (int64, error) {
+	if winner.CompanyServiceID != nil {
+		return *winner.CompanyServiceID, nil
+	}
+	if id := companyCatalogEntry(tx, winner.CompanyID, req.ServiceName); id != nil {
+		winner.CompanyServiceID = id
+	} else {
+		var service models.Service
+		if err := tx.Where("title = ?", req.ServiceName).First(&service).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, err
+			}
+			service = models.Service{Title: req.ServiceName, Category: req.Category}
+			if err := tx.Create(&service).Error; err != nil {
+				return 0, err
+			}
+		}
+		cs := models.CompanyService{
+			CompanyID: winner.CompanyID,
+			ServiceID: service.ServiceID,
+			Price:     winner.Price,
+			Currency:  winner.Currency,
+			Capacity:  max(req.Quantity, 1),
+		}
+		if err := tx.Create(&cs).Error; err != nil {
+			return 0, err
+		}
+		winner.CompanyServiceID = &cs.CompanyServiceID
+	}
+	if err := tx.Model(&models.ServiceRequestResponse{}).
+		Where("response_id = ?", winner.ResponseID).
+		Update("company_service_id", *winner.CompanyServiceID).Error; err != nil {
+		return 0, err
+	}
+	return *winner.CompanyServiceID, nil
+}
+
+func awardNotifications(db *gorm.DB, req *models.ServiceRequest, winner *models.ServiceRequestResponse, bookingID int64) []models.Notification {
+	requestID := req.RequestID
+	var losers []models.ServiceRequestResponse
+	db.Preload("Company").Where("request_id = ? AND response_id <> ?", requestID, winner.ResponseID).Find(&losers)
+
+	notifs := make([]models.Notification, 0, len(losers)+1)
+	for _, userID := range companyRecipients(db, winner.CompanyID) {
+		notifs = append(notifs, models.Notification{
+			UserID:    userID,
+			Title:     "Ваше предложение выбрано",
+			Message:   "Заказчик выбрал предложение компании «" + winner.Company.Name + "» по заявке «" + req.ServiceName + "». Создана бронь №" + strconv.FormatInt(bookingID, 10) + ".",
+			RequestID: &requestID,
+		})
+	}
+	for _, l := range losers {
+		for _, userID := range companyRecipients(db, l.CompanyID) {
+			notifs = append(notifs, models.Notification{
+				UserID:    userID,
+				Title:     "Выбрано другое предложение",
+				Message:   "По заявке «" + req.ServiceName + "» заказчик выбрал предложение другой компании. Спасибо за участие, «" + l.Company.Name + "».",
+				RequestID: &requestID,
+			})
+		}
+	}
+	return notifs
+}
+
+// finalizePendingAward завершает выбор предложения, бронь по которому прошла
+// внутреннее согласование: заявка закрывается, остальные предложения
+// проигрывают. Для брони не по заявке возвращает nil.
+func finalizePendingAward(tx *gorm.DB, bookingID int64) (*models.ServiceRequest, *models.ServiceRequestResponse, error) {
+	var req models.ServiceRequest
+	err := tx.Where("awarded_booking_id = ? AND status = ?", bookingID, models.ServiceRequestStatusAwardPending).First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var winner models.ServiceRequestResponse
+	if err := tx.Preload("Company").
+		First(&winner, "request_id = ? AND status = ?", req.RequestID, models.BidStatusAwardPending).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Model(&models.ServiceRequest{}).
+		Where("request_id = ?", req.RequestID).
+		Update("status", models.ServiceRequestStatusAwarded).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Model(&models.ServiceRequestResponse{}).
+		Where("response_id = ?", winner.ResponseID).
+		Update("status", models.BidStatusAwarded).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Model(&models.ServiceRequestResponse{}).
+		Where("request_id = ? AND response_id <> ?", req.RequestID, winner.ResponseID).
+		Update("status", models.BidStatusLost).Error; err != nil {
+		return nil, nil, err
+	}
+	req.Status = models.ServiceRequestStatusAwarded
+	return &req, &winner, nil
+}
+
+// reopenPendingAward снова открывает заявку, бронь по которой не прошла
+// внутреннее согласование или отменена до него: выбор снимается, все
+// предложения остаются в силе.
+func reopenPendingAward(tx *gorm.DB, bookingID int64) error {
+	var req models.ServiceRequest
+	err := tx.Where("awarded_booking_id = ? AND status = ?", bookingID, models.ServiceRequestStatusAwardPending).First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.ServiceRequest{}).
+		Where("request_id = ?", req.RequestID).
+		Updates(map[string]interface{}{
+			"status":             models.ServiceRequestStatusReviewed,
+			"awarded_booking_id": nil,
+		}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.ServiceRequestResponse{}).
+		Where("request_id = ? AND status = ?", req.RequestID, models.BidStatusAwardPending).
+		Update("status", models.BidStatusSubmitted).Error
+}
+
+// GetMy отдаёт заявки текущего пользователя вместе с откликами компаний.
+func (h *ServiceRequestHandler) GetMy(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var requests []models.ServiceRequest
+	if err := h.db.Where("user_id = ?", userID).
+		Preload("Responses.Company").
+		Order("created_at desc").
+		Find(&requests).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+type ServiceRequestBid struct {
+	models.ServiceRequestResponse
+	CompanyName string `json:"company_name"`
+	// Total — цена за весь объём заявки без НДС.
+	Total *float64 `json:"total"`
+}
+
+// GetBids отдаёт предложения по заявке для сравнения: сначала с ценой по
+// возрастанию суммы, при равной сумме — с меньшим сроком, без цены — в конце.
+func (h *ServiceRequestHandler) GetBids(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ServiceRequest
+	if err := h.db.Preload("Responses.Company").First(&req, "request_id = ?", requestID).Error; err != nil {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	}
+	if !h.policy.CanViewServiceRequest(subject, &req) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	bids := make([]ServiceRequestBid, 0, len(req.Responses))
+	for _, resp := range req.Responses {
+		bid := ServiceRequestBid{ServiceRequestResponse: resp, CompanyName: resp.Company.Name}
+		if resp.Price != nil {
+			total := *resp.Price * float64(req.Quantity)
+			bid.Total = &total
+		}
+		bids = append(bids, bid)
+	}
+	sort.SliceStable(bids, func(i, j int) bool {
+		a, b := bids[i], bids[j]
+		if (a.Total == nil) != (b.Total == nil) {
+			return a.Total != nil
+		}
+		if a.Total != nil && *a.Total != *b.Total {
+			return *a.Total < *b.Total
+		}
+		if (a.LeadTimeDays == nil) != (b.LeadTimeDays == nil) {
+			return a.LeadTimeDays != nil
+		}
+		return a.LeadTimeDays != nil && *a.LeadTimeDays < *b.LeadTimeDays
+	})
+
+	req.Responses = nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"request": req,
+		"bids":    bids,
+	})
+}
+
+// Award — заказчик выбирает предложение. Заявка превращается в бронь по цене
+// предложения, бронь сразу подтверждена; остальные участники узнают, что
+// выбрано другое предложение. Позиция каталога, которой у исполнителя ещё
+// нет, создаётся только сейчас. Если бронь ждёт внутреннего согласования,
+// выбор остаётся предварительным до решения согласующего.
+func (h *ServiceRequestHandler) Award(w http.ResponseWriter, r *http.Request) {
+	subject, ok := policy.FromRequest(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, role := subject.UserID, subject.Role
+
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		ResponseID     int64      `json:"response_id"`
+		ScheduledStart *time.Time `json:"scheduled_start,omitempty"`
+		ScheduledEnd   *time.Time `json:"scheduled_end,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.ResponseID <= 0 {
+		http.Error(w, "response_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ServiceRequest
+	if err := h.db.First(&req, "request_id = ?", requestID).Error; err != nil {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	}
+	if !h.policy.OwnsServiceRequest(subject, &req) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !models.ServiceRequestOpen(req.Status) {
+		http.Error(w, errServiceRequestClosed.Error(), http.StatusConflict)
+		return
+	}
+
+	var winner models.ServiceRequestResponse
+	if err := h.db.Preload("Company").
+		First(&winner, "response_id = ? AND request_id = ?", body.ResponseID, requestID).Error; err != nil {
+		http.Error(w, "bid not found", http.StatusNotFound)
+		return
+	}
+	if winner.Price == nil {
+		http.Error(w, "bid has no price and cannot be awarded", http.StatusConflict)
+		return
+	}
+	// Компанию могли отклонить уже после того, как она сделала предложение.
+	if !winner.Company.Verified() {
+		http.Error(w, errCompanyNotVerified.Error(), http.StatusConflict)
+		return
+	}
+
+	description := req.ServiceName
+	if req.Scope != nil && *req.Scope != "" {
+		description += ": " + *req.Scope
+	}
+	input := BookingCreateRequest{
+		Description:    &description,
+		ScheduledStart: body.ScheduledStart,
+		ScheduledEnd:   body.ScheduledEnd,
+		SiteAddress:    req.SiteAddress,
+		SiteField:      req.SiteField,
+		SitePad:        req.SitePad,
+		SiteWell:       req.SiteWell,
+		SiteLatitude:   req.SiteLatitude,
+		SiteLongitude:  req.SiteLongitude,
+	}
+	booking, ok := bookingFromRequest(w, &input, userID, role)
+	if !ok {
+		return
+	}
+	member, err := h.orgRepo.MembershipOf(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if member != nil {
+		booking.OrgID = &member.OrgID
+	}
+
+	held := false
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		repo := h.bookingRepo.WithTx(tx)
+
+		companyServiceID, err := awardCompanyService(tx, &req, &winner)
+		if err != nil {
+			return err
+		}
+		if err := repo.Create(booking); err != nil {
+			return err
+		}
+		quantity := req.Quantity
+		if err := repo.AddServices([]models.BookingService{{
+			BookingID:        booking.BookingID,
+			CompanyServiceID: companyServiceID,
+			Quantity:         &quantity,
+			Status:           models.BookingStatusRequested,
+			UnitPrice:        winner.Price,
+			Currency:         winner.Currency,
+		}}); err != nil {
+			return err
+		}
+		if err := repo.RecalculateTotals(booking.BookingID); err != nil {
+			return err
+		}
+		// Сверх полномочий сотрудника организации бронь ждёт согласования и
+		// после него сразу становится подтверждённой.
+		if member != nil {
+			if err := tx.First(booking, booking.BookingID).Error; err != nil {
+				return err
+			}
+			held = member.Org.NeedsApproval(member, booking.Total)
+		}
+		if held {
+			if err := repo.HoldForApproval(booking.BookingID, userID); err != nil {
+				return err
+			}
+		} else {
+			// Бронь подтверждается сразу, поэтому мощность исполнителя
+			// проверяется здесь же, в одной транзакции с подтверждением.
+			if err := h.companyServiceRepo.CheckBookingAvailability(tx, booking.BookingID); err != nil {
+				return err
+			}
+			reason := "выбрано предложение по заявке №" + strconv.FormatInt(requestID, 10)
+			if err := repo.UpdateStatus(booking.BookingID, models.BookingStatusApproved, models.BookingActorCustomer, userID, &reason); err != nil {
+				return err
+			}
+		}
+
+		// Пока бронь ждёт согласования, выбор предварительный: остальные
+		// предложения остаются в силе, исполнители ничего не узнают.
+		requestStatus, bidStatus := models.ServiceRequestStatusAwarded, models.BidStatusAwarded
+		if held {
+			requestStatus, bidStatus = models.ServiceRequestStatusAwardPending, models.BidStatusAwardPending
+		}
+		res := tx.Model(&models.ServiceRequest{}).
+			Where("request_id = ? AND status IN ?", requestID, []string{models.ServiceRequestStatusPending, models.ServiceRequestStatusReviewed}).
+			Updates(map[string]interface{}{
+				"status":             requestStatus,
+				"awarded_booking_id": booking.BookingID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errServiceRequestClosed
+		}
+
+		if err := tx.Model(&models.ServiceRequestResponse{}).
+			Where("response_id = ?", winner.ResponseID).
+			Update("status", bidStatus).Error; err != nil {
+			return err
+		}
+		if held {
+			return nil
+		}
+		return tx.Model(&models.ServiceRequestResponse{}).
+			Where("request_id = ? AND response_id <> ?", requestID, winner.ResponseID).
+			Update("status", models.BidStatusLost).Error
+	})
+	if err != nil {
+		var conflict *repository.AvailabilityConflictError
+		switch {
+		case errors.Is(err, errServiceRequestClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.As(err, &conflict):
+			writeAvailabilityError(w, err)
+		default:
+			writeBookingStatusError(w, err)
+		}
+		return
+	}
+
+	var notifs []models.Notification
+	if held {
+		var requester string
+		h.db.Model(&models.User{}).Where("user_id = ?", userID).Pluck("name", &requester)
+		notifs = approvalRequestNotifications(h.orgRepo, booking, false, userID, holdMessage(booking, requester))
+	} else {
+		notifs = awardNotifications(h.db, &req, &winner, booking.BookingID)
+	}
+	if len(notifs) > 0 {
+		h.db.Create(&notifs)
+	}
+
+	created, err := h.bookingRepo.GetWithServices(booking.BookingID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/models"
+)
+
+// SessionHandler — сеансы (входы) пользователя: где он вошёл и завершение
+// отдельных сеансов или всех сразу.
+type SessionHandler struct {
+	tokens   *repository.TokenRepo
+	security *repository.SecurityRepo
+}
+
+func NewSessionHandler(tokens *repository.TokenRepo, security *repository.SecurityRepo) *SessionHandler {
+	return &SessionHandler{tokens: tokens, security: security}
+}
+
+// SessionResponse — сеанс; Current отмечает сеанс, из которого сделан запрос.
+type SessionResponse struct {
+	models.UserSession
+	Current bool `json:"current"`
+}
+
+// List — действующие сеансы текущего пользователя.
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.writeSessions(w, r, userID, true)
+}
+
+// Revoke завершает свой сеанс, например на потерянном устройстве.
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.revoke(w, r, userID, "id", nil)
+}
+
+// RevokeAll — «выйти везде». С ?keep_current=true текущий сеанс остаётся.
+func (h *SessionHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var keep int64
+	if r.URL.Query().Get("keep_current") == "true" {
+		keep, _ = authmw.GetSessionFromContext(r)
+	}
+	h.revokeAll(w, r, userID, keep, nil)
+}
+
+// ListForUser — сеансы любого пользователя для администратора; ?all=true
+// добавляет завершённые и истёкшие.
+func (h *SessionHandler) ListForUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	h.writeSessions(w, r, userID, r.URL.Query().Get("all") != "true")
+}
+
+func (h *SessionHandler) RevokeForUser(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	h.revoke(w, r, userID, "sessionId", &actorID)
+}
+
+func (h *SessionHandler) RevokeAllForUser(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	h.revokeAll(w, r, userID, 0, &actorID)
+}
+
+func (h *SessionHandler) writeSessions(w http.ResponseWriter, r *http.Request, userID int64, activeOnly bool) {
+	list, err := h.tokens.Sessions(userID, activeOnly)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current, _ := authmw.GetSessionFromContext(r)
+	out := make([]SessionResponse, 0, len(list))
+	for _, s := range list {
+		out = append(out, SessionResponse{UserSession: s, Current: s.SessionID == current})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// revoke завершает сеанс пользователя userID из параметра param. Чужой сеанс
+// не отличается от несуществующего.
+func (h *SessionHandler) revoke(w http.ResponseWriter, r *http.Request, userID int64, param string, actorID *int64) {
+	id, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+	session, err := h.tokens.GetSession(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && session.UserID != userID) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	revoked, err := h.tokens.RevokeSession(session)
+	if err != nil {
+		http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		h.logEvent(r, models.SecurityEventSessionRevoked, userID, actorID, "session "+strconv.FormatInt(id, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SessionHandler) revokeAll(w http.ResponseWriter, r *http.Request, userID, keep int64, actorID *int64) {
+	count, err := h.tokens.RevokeOtherSessions(userID, keep)
+	if err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	h.logEvent(r, models.SecurityEventSessionsRevoked, userID, actorID, strconv.FormatInt(count, 10)+" sessions")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": count})
+}
+
+func (h *SessionHandler) adminTarget(w http.ResponseWriter, r *http.Request) (actorID, userID int64, ok bool) {
+	actorID, _, ok = authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return actorID, userID, true
+}
+
+func (h *SessionHandler) logEvent(r *http.Request, eventType string, userID int64, actorID *int64, detail string) {
+	if err := h.security.LogEvent(securityEvent(r, eventType, &userID, "", actorID, detail)); err != nil {
+		log.Printf("sessions: не удалось записать событие %s: %v", eventType, err)
+	}
+}
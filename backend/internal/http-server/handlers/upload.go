@@ -12,16 +12,18 @@ import (
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/http-server/policy"
 	"oil-gas-service-booking/internal/models"
 )
 
 type UploadHandler struct {
 	db         *gorm.DB
 	uploadsDir string
+	policy     *policy.Policy
 }
 
-func NewUploadHandler(db *gorm.DB, uploadsDir string) *UploadHandler {
-	return &UploadHandler{db: db, uploadsDir: uploadsDir}
+func NewUploadHandler(db *gorm.DB, uploadsDir string, policy *policy.Policy) *UploadHandler {
+	return &UploadHandler{db: db, uploadsDir: uploadsDir, policy: policy}
 }
 
 func (h *UploadHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
@@ -47,7 +49,7 @@ func (h *UploadHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UploadHandler) UploadCompanyLogo(w http.ResponseWriter, r *http.Request) {
-	userID, role, ok := authmw.GetUserFromContext(r)
+	subject, ok := policy.FromRequest(r)
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
@@ -64,7 +66,12 @@ func (h *UploadHandler) UploadCompanyLogo(w http.ResponseWriter, r *http.Request
 		http.Error(w, "company not found", http.StatusNotFound)
 		return
 	}
-	if company.UserID != userID && role != "admin" {
+	allowed, err := h.policy.CanManageCompany(subject, company.CompanyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
 		http.Error(w, "forbidden: not your company", http.StatusForbidden)
 		return
 	}
@@ -1,20 +1,33 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
 	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/mailer"
+	"oil-gas-service-booking/internal/models"
+	"oil-gas-service-booking/internal/storage"
 )
 
 type UserHandler struct {
-	repo *repository.UserRepo
+	repo       *repository.UserRepo
+	security   *repository.SecurityRepo
+	mailer     mailer.Mailer
+	uploadsDir string
 }
 
-func NewUserHandler(repo *repository.UserRepo) *UserHandler {
-	return &UserHandler{repo: repo}
+func NewUserHandler(repo *repository.UserRepo, security *repository.SecurityRepo, mail mailer.Mailer, uploadsDir string) *UserHandler {
+	return &UserHandler{repo: repo, security: security, mailer: mail, uploadsDir: uploadsDir}
 }
 
 func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
@@ -27,13 +40,198 @@ func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(users)
 }
 
+// UpdateRole меняет глобальную роль пользователя. Свою роль администратор
+// не меняет — иначе можно случайно лишиться доступа.
+func (h *UserHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	actorID, id, ok := h.target(w, r, "change your own role")
+	if !ok {
+		return
+	}
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !models.IsValidRole(body.Role) {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.repo.GetByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if user.Role == body.Role {
+		json.NewEncoder(w).Encode(user)
+		return
+	}
+	if err := h.repo.ChangeRole(id, body.Role); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	h.logEvent(r, models.SecurityEventRoleChanged, user, actorID, user.Role+" -> "+body.Role)
+	h.notify(r.Context(), user, "Роль изменена",
+		"Администратор изменил вашу роль: "+body.Role+". Войдите заново, чтобы продолжить работу.")
+
+	user, err = h.repo.GetByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+// Suspend приостанавливает учётную запись: вход, обновление токенов и
+// запросы с уже выданными токенами и API-ключами отклоняются.
+func (h *UserHandler) Suspend(w http.ResponseWriter, r *http.Request) {
+	actorID, id, ok := h.target(w, r, "suspend yourself")
+	if !ok {
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+
+	user, err := h.repo.GetByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := h.repo.Suspend(id, body.Reason); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	h.logEvent(r, models.SecurityEventSuspended, user, actorID, body.Reason)
+	text := "Администратор приостановил вашу учётную запись, все сеансы завершены."
+	if body.Reason != "" {
+		text += "\n\nПричина: " + body.Reason
+	}
+	h.notify(r.Context(), user, "Учётная запись приостановлена", text)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	actorID, id, ok := h.target(w, r, "reactivate yourself")
+	if !ok {
+		return
+	}
+	user, err := h.repo.GetByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if user.SuspendedAt == nil {
+		http.Error(w, "user is not suspended", http.StatusConflict)
+		return
+	}
+	if err := h.repo.Reactivate(id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	h.logEvent(r, models.SecurityEventReactivated, user, actorID, "")
+	h.notify(r.Context(), user, "Учётная запись восстановлена",
+		"Администратор восстановил вашу учётную запись, вы снова можете войти.")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete удаляет учётную запись с обезличиванием (см. UserRepo.Deactivate).
+// Компании пользователя сначала нужно передать другому владельцу, а в его
+// организации заказчика — назначить другого администратора.
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	actorID, id, ok := h.target(w, r, "deactivate yourself")
+	if !ok {
+		return
+	}
 
-	if err := h.repo.Delete(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	before, err := h.repo.Deactivate(id)
+	if errors.Is(err, repository.ErrOwnsCompanies) {
+		companies, listErr := h.repo.PrimaryOwnedCompanies(id)
+		if listErr != nil {
+			http.Error(w, listErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		ids := make([]string, 0, len(companies))
+		for _, c := range companies {
+			ids = append(ids, strconv.FormatInt(c.CompanyID, 10))
+		}
+		http.Error(w, err.Error()+": companies "+strings.Join(ids, ", "), http.StatusConflict)
 		return
 	}
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	// Ссылка на аватар уже стёрта, но сам файл отдаётся по предсказуемому пути.
+	if err := storage.RemoveAvatars(h.uploadsDir, id); err != nil {
+		log.Printf("users: не удалось удалить аватар %d: %v", id, err)
+	}
+	h.logEvent(r, models.SecurityEventDeactivated, before, actorID, "")
+	h.notify(r.Context(), before, "Учётная запись удалена",
+		"Ваша учётная запись удалена администратором. История броней сохранена без ваших личных данных.")
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// target разбирает id пользователя из URL и не даёт администратору
+// применить действие к себе.
+func (h *UserHandler) target(w http.ResponseWriter, r *http.Request, selfAction string) (actorID, id int64, ok bool) {
+	actorID, _, ok = authmw.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	if id == actorID {
+		http.Error(w, "you cannot "+selfAction, http.StatusForbidden)
+		return 0, 0, false
+	}
+	return actorID, id, true
+}
+
+func (h *UserHandler) logEvent(r *http.Request, eventType string, user *models.User, actorID int64, detail string) {
+	if err := h.security.LogEvent(securityEvent(r, eventType, &user.UserID, derefString(user.Email), &actorID, detail)); err != nil {
+		log.Printf("users: не удалось записать событие %s: %v", eventType, err)
+	}
+}
+
+// notify сообщает пользователю письмом: приостановленный или удалённый
+// пользователь уведомления в приложении уже не увидит.
+func (h *UserHandler) notify(ctx context.Context, user *models.User, subject, text string) {
+	if user.Email == nil {
+		return
+	}
+	msg := mailer.Message{To: *user.Email, Subject: subject, Body: "Здравствуйте, " + user.Name + "!\n\n" + text}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("users: не удалось отправить письмо «%s»: %v", subject, err)
+	}
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrLastAdmin),
+		errors.Is(err, repository.ErrOwnsCompanies),
+		errors.Is(err, repository.ErrSoleOrgAdmin),
+		errors.Is(err, repository.ErrUserDeactivated):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
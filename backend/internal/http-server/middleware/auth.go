@@ -2,61 +2,234 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"oil-gas-service-booking/internal/models"
 )
 
 type ctxKey string
 
 const (
-	ctxUserIDKey ctxKey = "user_id"
-	ctxRoleKey   ctxKey = "role"
+	ctxUserIDKey    ctxKey = "user_id"
+	ctxRoleKey      ctxKey = "role"
+	ctxTokenIDKey   ctxKey = "token_id"
+	ctxTokenExpKey  ctxKey = "token_exp"
+	ctxAPIKeyKey    ctxKey = "api_key"
+	ctxImpersonator ctxKey = "impersonator_id"
+	ctxSessionKey   ctxKey = "session_id"
 )
 
+const defaultTokenTTL = 15 * time.Minute
+
 type Claims struct {
 	UserID int64  `json:"user_id"`
 	Role   string `json:"role"`
+	// ImpersonatorID — администратор, вошедший от имени UserID; 0 для
+	// обычного входа.
+	ImpersonatorID int64 `json:"impersonator_id,omitempty"`
+	// SessionID — сеанс (вход), в котором выдан токен; 0 у токенов имперсонации.
+	SessionID int64 `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-var jwtSecret []byte
+// TokenStore — серверное состояние, которое middleware сверяет с токеном:
+// отзыв access-токенов, актуальная роль пользователя и его статус.
+type TokenStore interface {
+	IsAccessTokenRevoked(jti string) (bool, error)
+	// CurrentRole возвращает роль пользователя; found=false, если его больше
+	// нет или учётная запись удалена, suspended=true — если она приостановлена.
+	CurrentRole(userID int64) (role string, suspended, found bool, err error)
+	// TouchSession сообщает, действует ли сеанс, и отмечает его активность.
+	TouchSession(sessionID int64, ip string) (active bool, err error)
+}
 
-func SetJWTSecret(secret string) {
-	jwtSecret = []byte(secret)
+// APIKeyStore проверяет API-ключи. Для недействительного ключа возвращается
+// nil без ошибки; role — текущая роль владельца ключа.
+type APIKeyStore interface {
+	AuthenticateAPIKey(raw, ip string) (key *models.APIKey, role string, err error)
 }
 
-func GenerateToken(userID int64, role string) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		},
+// ImpersonationAuditor журналирует запросы под имперсонацией: запись
+// создаётся до обработчика (без неё запрос не выполняется), статус ответа
+// дописывается после.
+type ImpersonationAuditor interface {
+	StartImpersonatedRequest(entry *models.ImpersonationRequest) error
+	FinishImpersonatedRequest(requestID int64, status int) error
+}
+
+var (
+	accessTokenTTL       = defaultTokenTTL
+	tokenStore           TokenStore
+	apiKeyStore          APIKeyStore
+	impersonationAuditor ImpersonationAuditor
+)
+
+func SetAccessTokenTTL(ttl time.Duration) {
+	if ttl > 0 {
+		accessTokenTTL = ttl
+	}
+}
+
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+func SetTokenStore(store TokenStore) {
+	tokenStore = store
+}
+
+func SetAPIKeyStore(store APIKeyStore) {
+	apiKeyStore = store
+}
+
+func SetImpersonationAuditor(auditor ImpersonationAuditor) {
+	impersonationAuditor = auditor
+}
+
+// GenerateToken выдаёт access-токен сеанса sessionID.
+func GenerateToken(userID int64, role string, sessionID int64) (string, error) {
+	signed, _, err := signToken(Claims{UserID: userID, Role: role, SessionID: sessionID}, accessTokenTTL)
+	return signed, err
+}
+
+// GenerateImpersonationToken — GenerateToken для входа администратора adminID
+// от имени userID. Токен живёт ttl и не продлевается; возвращается и его jti,
+// чтобы сеанс можно было завершить досрочно.
+func GenerateImpersonationToken(userID int64, role string, adminID int64, ttl time.Duration) (signed, jti string, err error) {
+	return signToken(Claims{UserID: userID, Role: role, ImpersonatorID: adminID}, ttl)
+}
+
+func signToken(claims Claims, ttl time.Duration) (string, string, error) {
+	key, err := activeSigningKey()
+	if err != nil {
+		return "", "", err
+	}
+	jti, err := newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.KID
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return signed, jti, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 func validateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
 	if err != nil || !token.Valid {
 		return nil, err
 	}
 	return claims, nil
 }
 
-func BasicAuthMiddleware(adminOnly bool) func(http.Handler) http.Handler {
+// checkTokenState сверяет токен с сервером: не отозван ли он или его сеанс,
+// существует ли пользователь, не приостановлен ли он и совпадает ли его роль
+// с ролью в токене.
+func checkTokenState(claims *Claims, ip string) (status int, msg string) {
+	if tokenStore == nil {
+		return 0, ""
+	}
+	if claims.ID == "" {
+		return http.StatusUnauthorized, "Invalid token"
+	}
+	revoked, err := tokenStore.IsAccessTokenRevoked(claims.ID)
+	if err != nil {
+		log.Printf("auth: проверка отзыва токена: %v", err)
+		return http.StatusInternalServerError, "failed to verify token"
+	}
+	if revoked {
+		return http.StatusUnauthorized, "Token revoked"
+	}
+	role, suspended, found, err := tokenStore.CurrentRole(claims.UserID)
+	if err != nil {
+		log.Printf("auth: проверка пользователя %d: %v", claims.UserID, err)
+		return http.StatusInternalServerError, "failed to verify token"
+	}
+	if !found {
+		return http.StatusUnauthorized, "User no longer exists"
+	}
+	if suspended {
+		return http.StatusForbidden, "Account suspended"
+	}
+	if role != claims.Role {
+		return http.StatusUnauthorized, "Role changed, please sign in again"
+	}
+	if claims.SessionID != 0 {
+		active, err := tokenStore.TouchSession(claims.SessionID, ip)
+		if err != nil {
+			log.Printf("auth: проверка сеанса %d: %v", claims.SessionID, err)
+			return http.StatusInternalServerError, "failed to verify token"
+		}
+		if !active {
+			return http.StatusUnauthorized, "Session revoked"
+		}
+	}
+	if claims.ImpersonatorID != 0 {
+		// Имперсонация действует, пока администратор сам сохраняет это право.
+		role, suspended, found, err := tokenStore.CurrentRole(claims.ImpersonatorID)
+		if err != nil {
+			log.Printf("auth: проверка администратора %d: %v", claims.ImpersonatorID, err)
+			return http.StatusInternalServerError, "failed to verify token"
+		}
+		if !found || suspended || !models.RoleHasPermission(role, models.PermUsersImpersonate) {
+			return http.StatusUnauthorized, "Impersonation is no longer allowed"
+		}
+	}
+	return 0, ""
+}
+
+// BasicAuthMiddleware проверяет токен или API-ключ и право perm у роли
+// пользователя (для ключа — ещё и у его областей).
+func BasicAuthMiddleware(perm models.Permission) func(http.Handler) http.Handler {
+	return authMiddleware(perm, true)
+}
+
+// SessionAuthMiddleware — как BasicAuthMiddleware, но только для входа по
+// токену: учётными данными, сотрудниками и самими ключами через API-ключ
+// управлять нельзя. Под имперсонацией эти маршруты тоже закрыты.
+func SessionAuthMiddleware(perm models.Permission) func(http.Handler) http.Handler {
+	return authMiddleware(perm, false)
+}
+
+func authMiddleware(perm models.Permission, allowAPIKeys bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if raw, ok := apiKeyFromRequest(r); ok {
+				if !allowAPIKeys {
+					http.Error(w, "API keys are not accepted here, sign in instead", http.StatusForbidden)
+					return
+				}
+				serveWithAPIKey(w, r, next, perm, raw)
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 				http.Error(w, "Bearer token required", http.StatusUnauthorized)
@@ -70,19 +243,146 @@ func BasicAuthMiddleware(adminOnly bool) func(http.Handler) http.Handler {
 				return
 			}
 
-			if adminOnly && claims.Role != "admin" {
-				http.Error(w, "Admin access required", http.StatusForbidden)
+			if status, msg := checkTokenState(claims, remoteIP(r)); status != 0 {
+				http.Error(w, msg, status)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), ctxUserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, ctxRoleKey, claims.Role)
+			ctx = context.WithValue(ctx, ctxTokenIDKey, claims.ID)
+			ctx = context.WithValue(ctx, ctxSessionKey, claims.SessionID)
+			if claims.ExpiresAt != nil {
+				ctx = context.WithValue(ctx, ctxTokenExpKey, claims.ExpiresAt.Time)
+			}
 
+			if claims.ImpersonatorID != 0 {
+				ctx = context.WithValue(ctx, ctxImpersonator, claims.ImpersonatorID)
+				serveImpersonated(w, r.WithContext(ctx), next, perm, claims, !allowAPIKeys)
+				return
+			}
+			if !models.RoleHasPermission(claims.Role, perm) {
+				http.Error(w, "Permission required: "+string(perm), http.StatusForbidden)
+				return
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// apiKeyFromRequest достаёт API-ключ из X-API-Key или из Bearer с префиксом
+// ключа.
+func apiKeyFromRequest(r *http.Request) (string, bool) {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key, true
+	}
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(bearer, models.APIKeyPrefix) {
+		return bearer, true
+	}
+	return "", false
+}
+
+// serveImpersonated выполняет запрос под имперсонацией: только чтение и без
+// маршрутов управления учётной записью. Каждый запрос, в том числе
+// отклонённый, попадает в журнал.
+func serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, perm models.Permission, claims *Claims, sessionRoute bool) {
+	if impersonationAuditor == nil {
+		http.Error(w, "Impersonation is not available", http.StatusForbidden)
+		return
+	}
+	entry := &models.ImpersonationRequest{
+		AdminUserID: claims.ImpersonatorID,
+		UserID:      claims.UserID,
+		TokenID:     claims.ID,
+		Method:      r.Method,
+		Path:        r.URL.RequestURI(),
+		IP:          remoteIP(r),
+	}
+	var reject string
+	switch {
+	case !models.RoleHasPermission(claims.Role, perm):
+		reject = "Permission required: " + string(perm)
+	case sessionRoute:
+		reject = "Not available while impersonating"
+	case !safeMethod(r.Method):
+		reject = "Impersonation is read-only"
+	}
+	entry.Blocked = reject != ""
+	if err := impersonationAuditor.StartImpersonatedRequest(entry); err != nil {
+		log.Printf("auth: журнал имперсонации: %v", err)
+		http.Error(w, "failed to record impersonated request", http.StatusInternalServerError)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if reject != "" {
+		http.Error(rec, reject, http.StatusForbidden)
+	} else {
+		next.ServeHTTP(rec, r)
+	}
+	if err := impersonationAuditor.FinishImpersonatedRequest(entry.RequestID, rec.status); err != nil {
+		log.Printf("auth: журнал имперсонации: %v", err)
+	}
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// statusRecorder запоминает код ответа для журнала.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status, s.wroteHeader = code, true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, perm models.Permission, raw string) {
+	if apiKeyStore == nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	key, role, err := apiKeyStore.AuthenticateAPIKey(raw, remoteIP(r))
+	if err != nil {
+		log.Printf("auth: проверка API-ключа: %v", err)
+		http.Error(w, "failed to verify API key", http.StatusInternalServerError)
+		return
+	}
+	if key == nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	if !models.RoleHasPermission(role, perm) || !models.APIKeyScopePermissions(key.ScopeList())[perm] {
+		http.Error(w, "Permission required: "+string(perm), http.StatusForbidden)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ctxUserIDKey, key.UserID)
+	ctx = context.WithValue(ctx, ctxRoleKey, role)
+	ctx = context.WithValue(ctx, ctxAPIKeyKey, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func GetUserFromContext(r *http.Request) (userID int64, role string, ok bool) {
 	uid, ok1 := r.Context().Value(ctxUserIDKey).(int64)
 	rl, ok2 := r.Context().Value(ctxRoleKey).(string)
@@ -91,3 +391,34 @@ func GetUserFromContext(r *http.Request) (userID int64, role string, ok bool) {
 	}
 	return uid, rl, true
 }
+
+// GetTokenFromContext возвращает jti и срок действия access-токена запроса.
+func GetTokenFromContext(r *http.Request) (jti string, expiresAt time.Time, ok bool) {
+	jti, ok1 := r.Context().Value(ctxTokenIDKey).(string)
+	exp, ok2 := r.Context().Value(ctxTokenExpKey).(time.Time)
+	if !ok1 || !ok2 || jti == "" {
+		return "", time.Time{}, false
+	}
+	return jti, exp, true
+}
+
+// GetAPIKeyFromContext возвращает API-ключ, которым подписан запрос; ok=false
+// для входа по токену.
+func GetAPIKeyFromContext(r *http.Request) (*models.APIKey, bool) {
+	key, ok := r.Context().Value(ctxAPIKeyKey).(*models.APIKey)
+	return key, ok && key != nil
+}
+
+// GetImpersonatorFromContext возвращает администратора, вошедшего от имени
+// пользователя запроса; ok=false для обычного входа.
+func GetImpersonatorFromContext(r *http.Request) (adminID int64, ok bool) {
+	adminID, ok = r.Context().Value(ctxImpersonator).(int64)
+	return adminID, ok && adminID != 0
+}
+
+// GetSessionFromContext возвращает сеанс, в котором выдан access-токен
+// запроса; ok=false для API-ключей, имперсонации и токенов без сеанса.
+func GetSessionFromContext(r *http.Request) (sessionID int64, ok bool) {
+	sessionID, ok = r.Context().Value(ctxSessionKey).(int64)
+	return sessionID, ok && sessionID != 0
+}
@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// HMACKeyID — kid общего секрета HS256. Токены, выпущенные до появления kid,
+// проверяются этим ключом.
+const HMACKeyID = "hs256"
+
+// SigningKey — ключ подписи токенов. Ключ без PrivateKey только проверяет
+// подпись: так старый ключ доживает в наборе после ротации, пока не истекут
+// выпущенные им токены.
+type SigningKey struct {
+	KID        string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+func (k SigningKey) asymmetric() bool {
+	switch k.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		return true
+	}
+	return false
+}
+
+type keySet struct {
+	keys   map[string]SigningKey
+	active SigningKey
+}
+
+var signingKeys *keySet
+
+func NewHMACKey(kid string, secret []byte) SigningKey {
+	return SigningKey{KID: kid, Method: jwt.SigningMethodHS256, PrivateKey: secret, PublicKey: secret}
+}
+
+// LoadSigningKey читает PEM-ключи RS256 или EdDSA. Достаточно одного из
+// файлов: по закрытому ключу открытый вычисляется, один открытый ключ
+// годится только для проверки.
+func LoadSigningKey(kid, alg, privateKeyFile, publicKeyFile string) (SigningKey, error) {
+	key := SigningKey{KID: kid}
+	if kid == "" {
+		return key, fmt.Errorf("signing key: kid is required")
+	}
+	if privateKeyFile == "" && publicKeyFile == "" {
+		return key, fmt.Errorf("signing key %s: private_key_file or public_key_file is required", kid)
+	}
+
+	var privPEM, pubPEM []byte
+	var err error
+	if privateKeyFile != "" {
+		if privPEM, err = os.ReadFile(privateKeyFile); err != nil {
+			return key, fmt.Errorf("signing key %s: %w", kid, err)
+		}
+	}
+	if publicKeyFile != "" {
+		if pubPEM, err = os.ReadFile(publicKeyFile); err != nil {
+			return key, fmt.Errorf("signing key %s: %w", kid, err)
+		}
+	}
+
+	switch alg {
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if privPEM != nil {
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(privPEM)
+			if err != nil {
+				return key, fmt.Errorf("signing key %s: %w", kid, err)
+			}
+			key.PrivateKey, key.PublicKey = priv, &priv.PublicKey
+		} else {
+			pub, err := jwt.ParseRSAPublicKeyFromPEM(pubPEM)
+			if err != nil {
+				return key, fmt.Errorf("signing key %s: %w", kid, err)
+			}
+			key.PublicKey = pub
+		}
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if privPEM != nil {
+			priv, err := jwt.ParseEdPrivateKeyFromPEM(privPEM)
+			if err != nil {
+				return key, fmt.Errorf("signing key %s: %w", kid, err)
+			}
+			key.PrivateKey, key.PublicKey = priv, priv.(ed25519.PrivateKey).Public()
+		} else {
+			pub, err := jwt.ParseEdPublicKeyFromPEM(pubPEM)
+			if err != nil {
+				return key, fmt.Errorf("signing key %s: %w", kid, err)
+			}
+			key.PublicKey = pub
+		}
+	default:
+		return key, fmt.Errorf("signing key %s: unsupported algorithm %q (RS256, EdDSA)", kid, alg)
+	}
+	return key, nil
+}
+
+// SetSigningKeys задаёт набор ключей. Новые токены подписываются ключом
+// activeKID; если он не указан — первым асимметричным ключом с закрытой
+// частью, а при их отсутствии — HMAC-ключом.
+func SetSigningKeys(keys []SigningKey, activeKID string) error {
+	set := &keySet{keys: make(map[string]SigningKey, len(keys))}
+	for _, k := range keys {
+		if _, dup := set.keys[k.KID]; dup {
+			return fmt.Errorf("duplicate signing key kid %q", k.KID)
+		}
+		set.keys[k.KID] = k
+	}
+
+	if activeKID == "" {
+		for _, k := range keys {
+			if k.asymmetric() && k.PrivateKey != nil {
+				activeKID = k.KID
+				break
+			}
+		}
+	}
+	if activeKID == "" {
+		if _, ok := set.keys[HMACKeyID]; ok {
+			activeKID = HMACKeyID
+		}
+	}
+	active, ok := set.keys[activeKID]
+	if !ok {
+		return fmt.Errorf("active signing key %q is not configured", activeKID)
+	}
+	if active.PrivateKey == nil {
+		return fmt.Errorf("active signing key %q has no private key", activeKID)
+	}
+	set.active = active
+
+	signingKeys = set
+	return nil
+}
+
+func activeSigningKey() (SigningKey, error) {
+	if signingKeys == nil {
+		return SigningKey{}, fmt.Errorf("signing keys are not configured")
+	}
+	return signingKeys.active, nil
+}
+
+// verificationKey подбирает ключ по kid из заголовка токена и проверяет,
+// что алгоритм токена совпадает с алгоритмом ключа.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if signingKeys == nil {
+		return nil, fmt.Errorf("signing keys are not configured")
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = HMACKeyID
+	}
+	key, ok := signingKeys.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.PublicKey, nil
+}
+
+// JWK — открытый ключ в формате RFC 7517.
+type JWK struct {
+	KID string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicJWKS возвращает открытые части асимметричных ключей, включая
+// выведенные из ротации. HMAC-секрет не публикуется.
+func PublicJWKS() []JWK {
+	jwks := []JWK{}
+	if signingKeys == nil {
+		return jwks
+	}
+	for _, k := range signingKeys.keys {
+		if !k.asymmetric() {
+			continue
+		}
+		jwk := JWK{KID: k.KID, Alg: k.Method.Alg(), Use: "sig"}
+		switch pub := k.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].KID < jwks[j].KID })
+	return jwks
+}
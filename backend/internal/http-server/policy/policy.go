@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"net/http"
+
+	"gorm.io/gorm"
+
+	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/models"
+)
+
+// Policy — единое место проверок доступа к конкретным объектам: компаниям,
+// броням, заявкам и уведомлениям. Право на сам маршрут по роли проверяет
+// middleware, здесь — отношение пользователя к объекту.
+type Policy struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Policy {
+	return &Policy{db: db}
+}
+
+// Subject — пользователь запроса. Для запроса по API-ключу Scopes — права
+// областей ключа, а CompanyID — компания, которой ограничен ключ компании.
+type Subject struct {
+	UserID    int64
+	Role      string
+	Scopes    map[models.Permission]bool
+	CompanyID *int64
+}
+
+func (s Subject) Can(perm models.Permission) bool {
+	if s.Scopes != nil && !s.Scopes[perm] {
+		return false
+	}
+	return models.RoleHasPermission(s.Role, perm)
+}
+
+func FromRequest(r *http.Request) (Subject, bool) {
+	userID, role, ok := authmw.GetUserFromContext(r)
+	s := Subject{UserID: userID, Role: role}
+	if key, isKey := authmw.GetAPIKeyFromContext(r); isKey {
+		s.Scopes = models.APIKeyScopePermissions(key.ScopeList())
+		s.CompanyID = key.CompanyID
+	}
+	return s, ok
+}
+
+// CanManageCompany — изменение профиля компании, её услуг и расписания:
+// владелец или менеджер компании.
+func (p *Policy) CanManageCompany(s Subject, companyID int64) (bool, error) {
+	if s.Can(models.PermCompaniesManageAll) {
+		return true, nil
+	}
+	if !s.Can(models.PermCompaniesManage) {
+		return false, nil
+	}
+	return p.hasMemberRole(s, companyID, models.MemberManageRoles)
+}
+
+// CanManageMembers — приглашение и исключение сотрудников, смена их ролей:
+// только владелец компании.
+func (p *Policy) CanManageMembers(s Subject, companyID int64) (bool, error) {
+	if s.Can(models.PermCompaniesManageAll) {
+		return true, nil
+	}
+	return p.hasMemberRole(s, companyID, []string{models.MemberRoleOwner})
+}
+
+// CanTransferOwnership — передать компанию может её основной владелец или
+// администратор.
+func (p *Policy) CanTransferOwnership(s Subject, company *models.Company) bool {
+	return s.Can(models.PermCompaniesManageAll) || company.UserID == s.UserID
+}
+
+// CanViewCompanyMembers — список сотрудников видят все сотрудники компании.
+func (p *Policy) CanViewCompanyMembers(s Subject, companyID int64) (bool, error) {
+	if s.Can(models.PermCompaniesManageAll) || s.Can(models.PermUsersRead) {
+		return true, nil
+	}
+	return p.hasMemberRole(s, companyID, models.MemberViewRoles)
+}
+
+// CanViewUnverifiedCompanies — администраторы и аудиторы видят компании в
+// любом статусе модерации.
+func (p *Policy) CanViewUnverifiedCompanies(s Subject) bool {
+	return s.Can(models.PermCompaniesManageAll) || s.Can(models.PermUsersRead)
+}
+
+// CanViewCompany — проверенную компанию видят все, непроверенную — её
+// сотрудники и персонал.
+func (p *Policy) CanViewCompany(s Subject, company *models.Company) (bool, error) {
+	if company.Verified() || p.CanViewUnverifiedCompanies(s) {
+		return true, nil
+	}
+	return p.hasMemberRole(s, company.CompanyID, models.MemberViewRoles)
+}
+
+// OperatedCompanyIDs — компании, от имени которых пользователь работает с
+// бронями и заявками (owner, manager, dispatcher).
+func (p *Policy) OperatedCompanyIDs(s Subject) ([]int64, error) {
+	if !s.Can(models.PermCompaniesOperate) {
+		return []int64{}, nil
+	}
+	return p.memberCompanyIDs(s, models.MemberOperateRoles)
+}
+
+// CanOperateCompany — может ли пользователь отвечать от имени компании.
+func (p *Policy) CanOperateCompany(s Subject, companyID int64) (bool, error) {
+	if s.Can(models.PermCompaniesManageAll) {
+		return true, nil
+	}
+	if !s.Can(models.PermCompaniesOperate) {
+		return false, nil
+	}
+	return p.hasMemberRole(s, companyID, models.MemberOperateRoles)
+}
+
+func (p *Policy) memberCompanyIDs(s Subject, roles []string) ([]int64, error) {
+	ids := []int64{}
+	q := p.db.Model(&models.CompanyMember{}).Where("user_id = ? AND role IN ?", s.UserID, roles)
+	if s.CompanyID != nil {
+		q = q.Where("company_id = ?", *s.CompanyID)
+	}
+	err := q.Pluck("company_id", &ids).Error
+	return ids, err
+}
+
+func (p *Policy) hasMemberRole(s Subject, companyID int64, roles []string) (bool, error) {
+	if s.CompanyID != nil && *s.CompanyID != companyID {
+		return false, nil
+	}
+	var count int64
+	err := p.db.Model(&models.CompanyMember{}).
+		Where("company_id = ? AND user_id = ? AND role IN ?", companyID, s.UserID, roles).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// IsBookingCustomer — пользователь заказчик брони. Решения заказчика
+// (отмена, принятие предложения) за него никто не принимает.
+func (p *Policy) IsBookingCustomer(s Subject, b *models.Booking) bool {
+	return b.UserID != nil && *b.UserID == s.UserID
+}
+
+// CanActAsBookingCustomer — заказчик брони или администратор броней.
+func (p *Policy) CanActAsBookingCustomer(s Subject, b *models.Booking) bool {
+	return p.IsBookingCustomer(s, b) || s.Can(models.PermBookingsManageAll)
+}
+
+// CanViewWholeBooking — видит бронь целиком, включая строки всех компаний.
+func (p *Policy) CanViewWholeBooking(s Subject, b *models.Booking) bool {
+	return p.IsBookingCustomer(s, b) || s.Can(models.PermBookingsReadAll)
+}
+
+// CanViewBooking — видит бронь целиком или как сотрудник компании-исполнителя
+// хотя бы одной строки, уже прошедшей внутреннее согласование заказчика.
+func (p *Policy) CanViewBooking(s Subject, b *models.Booking) (bool, error) {
+	if p.CanViewWholeBooking(s, b) {
+		return true, nil
+	}
+	ids, err := p.memberCompanyIDs(s, models.MemberViewRoles)
+	if err != nil || len(ids) == 0 {
+		return false, err
+	}
+	var count int64
+	err = p.db.Model(&models.BookingService{}).
+		Joins("JOIN company_service ON company_service.company_service_id = booking_service.company_service_id").
+		Where("booking_service.booking_id = ? AND company_service.company_id IN ?", b.BookingID, ids).
+		Where("booking_service.status <> ?", models.BookingStatusPendingApproval).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// OwnsServiceRequest — пользователь автор заявки.
+func (p *Policy) OwnsServiceRequest(s Subject, req *models.ServiceRequest) bool {
+	return req.UserID == s.UserID
+}
+
+func (p *Policy) CanViewServiceRequest(s Subject, req *models.ServiceRequest) bool {
+	return p.OwnsServiceRequest(s, req) || s.Can(models.PermRequestsReadAll)
+}
+
+// Notifications — запрос, ограниченный уведомлениями пользователя.
+func (p *Policy) Notifications(s Subject) *gorm.DB {
+	return p.db.Model(&models.Notification{}).Where("user_id = ?", s.UserID)
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+// apiKeyTouchInterval — чаще этого last_used_at не обновляется, чтобы каждый
+// запрос интеграции не превращался в запись в базу.
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyFilter — отбор ключей для администратора; пустые поля не учитываются.
+type APIKeyFilter struct {
+	UserID     *int64
+	CompanyID  *int64
+	ActiveOnly bool
+}
+
+type APIKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepo(db *gorm.DB) *APIKeyRepo {
+	return &APIKeyRepo{db: db}
+}
+
+// Create сохраняет ключ и возвращает его целиком. Показать ключ можно только
+// сейчас — на сервере остаётся хеш.
+func (r *APIKeyRepo) Create(key *models.APIKey, scopes []string) (string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	raw := models.APIKeyPrefix + secret
+	key.Prefix = raw[:len(models.APIKeyPrefix)+8]
+	key.KeyHash = hashToken(raw)
+	key.Scopes = strings.Join(scopes, ",")
+	if err := r.db.Create(key).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (r *APIKeyRepo) Get(keyID int64) (*models.APIKey, error) {
+	var k models.APIKey
+	if err := r.db.First(&k, "key_id = ?", keyID).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ListPersonal — личные ключи пользователя (без ключей компаний).
+func (r *APIKeyRepo) ListPersonal(userID int64) ([]models.APIKey, error) {
+	var list []models.APIKey
+	err := r.db.Where("user_id = ? AND company_id IS NULL", userID).
+		Order("created_at DESC, key_id DESC").Find(&list).Error
+	return list, err
+}
+
+// ListByCompany — ключи компании.
+func (r *APIKeyRepo) ListByCompany(companyID int64) ([]models.APIKey, error) {
+	var list []models.APIKey
+	err := r.db.Where("company_id = ?", companyID).
+		Order("created_at DESC, key_id DESC").Find(&list).Error
+	return list, err
+}
+
+// List — ключи по фильтру, новые первыми.
+func (r *APIKeyRepo) List(f APIKeyFilter) ([]models.APIKey, error) {
+	q := r.db.Model(&models.APIKey{})
+	if f.UserID != nil {
+		q = q.Where("user_id = ?", *f.UserID)
+	}
+	if f.CompanyID != nil {
+		q = q.Where("company_id = ?", *f.CompanyID)
+	}
+	if f.ActiveOnly {
+		q = q.Where("revoked_at IS NULL AND expires_at > ?", time.Now().UTC())
+	}
+	var list []models.APIKey
+	err := q.Order("created_at DESC, key_id DESC").Find(&list).Error
+	return list, err
+}
+
+// Revoke отзывает ключ. Возвращает false, если он уже был отозван.
+func (r *APIKeyRepo) Revoke(keyID int64) (bool, error) {
+	res := r.db.Model(&models.APIKey{}).
+		Where("key_id = ? AND revoked_at IS NULL", keyID).
+		Update("revoked_at", time.Now().UTC())
+	return res.RowsAffected > 0, res.Error
+}
+
+// AuthenticateAPIKey находит действующий ключ и роль его владельца и отмечает
+// использование. Ключ приостановленного пользователя не действует, ключ
+// компании — пока владелец ключа управляет ею.
+// Для неизвестного, отозванного или истёкшего ключа возвращается nil.
+func (r *APIKeyRepo) AuthenticateAPIKey(raw, ip string) (*models.APIKey, string, error) {
+	var k models.APIKey
+	err := r.db.Where("key_hash = ?", hashToken(raw)).First(&k).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+	if !k.Active(now) {
+		return nil, "", nil
+	}
+
+	var roles []string
+	err = r.db.Model(&models.User{}).
+		Where("user_id = ? AND suspended_at IS NULL AND deactivated_at IS NULL", k.UserID).
+		Limit(1).Pluck("role", &roles).Error
+	if err != nil {
+		return nil, "", err
+	}
+	if len(roles) == 0 {
+		return nil, "", nil
+	}
+	if k.CompanyID != nil {
+		var count int64
+		err := r.db.Model(&models.CompanyMember{}).
+			Where("company_id = ? AND user_id = ? AND role IN ?", *k.CompanyID, k.UserID, models.MemberManageRoles).
+			Count(&count).Error
+		if err != nil {
+			return nil, "", err
+		}
+		if count == 0 {
+			return nil, "", nil
+		}
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval || (k.LastUsedIP != nil && *k.LastUsedIP != ip) {
+		err := r.db.Model(&models.APIKey{}).Where("key_id = ?", k.KeyID).
+			Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			return nil, "", err
+		}
+		k.LastUsedAt, k.LastUsedIP = &now, &ip
+	}
+	return &k, roles[0], nil
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+// defaultBookingDuration используется для брони, у которой задан только scheduled_start.
+const defaultBookingDuration = 24 * time.Hour
+
+type AvailabilitySlot struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	FreeCapacity int       `json:"free_capacity"`
+}
+
+const (
+	ConflictCapacity = "capacity"
+	ConflictBlackout = "blackout"
+)
+
+type AvailabilityConflictError struct {
+	CompanyServiceID int64
+	Reason           string
+	Start            time.Time
+	End              time.Time
+}
+
+func (e *AvailabilityConflictError) Error() string {
+	if e.Reason == ConflictBlackout {
+		return fmt.Sprintf("company service %d is unavailable (blackout) in the requested window", e.CompanyServiceID)
+	}
+	return fmt.Sprintf("company service %d has no free capacity in the requested window", e.CompanyServiceID)
+}
+
+type loadWindow struct {
+	start, end time.Time
+	units      int
+}
+
+// BookingWindow возвращает плановое окно брони; ok=false, если дата начала не задана.
+func BookingWindow(b *models.Booking) (start, end time.Time, ok bool) {
+	if b.ScheduledStart == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	start = b.ScheduledStart.UTC()
+	if b.ScheduledEnd != nil {
+		end = b.ScheduledEnd.UTC()
+	} else {
+		end = start.Add(defaultBookingDuration)
+	}
+	return start, end, true
+}
+
+func (r *CompanyServiceRepo) loadWindows(db *gorm.DB, csID int64, excludeBookingID int64) ([]loadWindow, error) {
+	var lines []models.BookingService
+	err := db.
+		Joins("JOIN booking ON booking.booking_id = booking_service.booking_id").
+		Where("booking_service.company_service_id = ?", csID).
+		Where("booking_service.status IN ?", models.BookingActiveStatuses).
+		Where("booking.scheduled_start IS NOT NULL").
+		Where("booking.booking_id <> ?", excludeBookingID).
+		Preload("Booking").
+		Find(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+
+	windows := make([]loadWindow, 0, len(lines))
+	for _, bs := range lines {
+		start, end, ok := BookingWindow(&bs.Booking)
+		if !ok {
+			continue
+		}
+		units := 1
+		if bs.Quantity != nil && *bs.Quantity > 0 {
+			units = *bs.Quantity
+		}
+		windows = append(windows, loadWindow{start: start, end: end, units: units})
+	}
+	return windows, nil
+}
+
+func (r *CompanyServiceRepo) loadBlackouts(db *gorm.DB, csID int64, from, to time.Time) ([]models.CompanyServiceBlackout, error) {
+	var all []models.CompanyServiceBlackout
+	if err := db.Where("company_service_id = ?", csID).Order("starts_at").Find(&all).Error; err != nil {
+		return nil, err
+	}
+	return Where(all, func(b models.CompanyServiceBlackout) bool {
+		return b.StartsAt.Before(to) && b.EndsAt.After(from)
+	}), nil
+}
+
+// segments делит [from, to) на интервалы с постоянной загрузкой и возвращает
+// свободную мощность для каждого. В период blackout свободная мощность равна нулю.
+func segments(capacity int, windows []loadWindow, blackouts []models.CompanyServiceBlackout, from, to time.Time) []AvailabilitySlot {
+	points := []time.Time{from, to}
+	clip := func(t time.Time) {
+		if t.After(from) && t.Before(to) {
+			points = append(points, t)
+		}
+	}
+	for _, w := range windows {
+		clip(w.start)
+		clip(w.end)
+	}
+	for _, b := range blackouts {
+		clip(b.StartsAt.UTC())
+		clip(b.EndsAt.UTC())
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Before(points[j]) })
+
+	var result []AvailabilitySlot
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		if !a.Before(b) {
+			continue
+		}
+
+		free := capacity
+		for _, w := range windows {
+			if w.start.Before(b) && w.end.After(a) {
+				free -= w.units
+			}
+		}
+		for _, bo := range blackouts {
+			if bo.StartsAt.Before(b) && bo.EndsAt.After(a) {
+				free = 0
+				break
+			}
+		}
+		if free < 0 {
+			free = 0
+		}
+
+		if n := len(result); n > 0 && result[n-1].FreeCapacity == free && result[n-1].End.Equal(a) {
+			result[n-1].End = b
+			continue
+		}
+		result = append(result, AvailabilitySlot{Start: a, End: b, FreeCapacity: free})
+	}
+	return result
+}
+
+// GetAvailability возвращает интервалы в [from, to), где у услуги компании
+// есть свободная мощность.
+func (r *CompanyServiceRepo) GetAvailability(csID int64, from, to time.Time) ([]AvailabilitySlot, error) {
+	from, to = from.UTC(), to.UTC()
+
+	cs, err := r.GetByID(csID)
+	if err != nil {
+		return nil, err
+	}
+
+	windows, err := r.loadWindows(r.db, csID, 0)
+	if err != nil {
+		return nil, err
+	}
+	blackouts, err := r.loadBlackouts(r.db, csID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	free := Where(segments(cs.Capacity, windows, blackouts, from, to), func(s AvailabilitySlot) bool {
+		return s.FreeCapacity > 0
+	})
+	if free == nil {
+		free = []AvailabilitySlot{}
+	}
+	return free, nil
+}
+
+// CheckAvailability проверяет, что в окне [start, end) у услуги хватает units
+// свободной мощности. Бронь excludeBookingID в загрузке не учитывается.
+// При нехватке возвращается *AvailabilityConflictError.
+func (r *CompanyServiceRepo) CheckAvailability(db *gorm.DB, csID int64, start, end time.Time, units int, excludeBookingID int64) error {
+	start, end = start.UTC(), end.UTC()
+
+	var cs models.CompanyService
+	if err := db.First(&cs, csID).Error; err != nil {
+		return err
+	}
+
+	blackouts, err := r.loadBlackouts(db, csID, start, end)
+	if err != nil {
+		return err
+	}
+	if len(blackouts) > 0 {
+		return &AvailabilityConflictError{CompanyServiceID: csID, Reason: ConflictBlackout, Start: start, End: end}
+	}
+
+	windows, err := r.loadWindows(db, csID, excludeBookingID)
+	if err != nil {
+		return err
+	}
+	for _, s := range segments(cs.Capacity, windows, nil, start, end) {
+		if s.FreeCapacity < units {
+			return &AvailabilityConflictError{CompanyServiceID: csID, Reason: ConflictCapacity, Start: start, End: end}
+		}
+	}
+	return nil
+}
+
+// CheckBookingAvailability проверяет все строки брони против мощности услуг.
+// Брони без планового окна не проверяются.
+func (r *CompanyServiceRepo) CheckBookingAvailability(db *gorm.DB, bookingID int64) error {
+	var b models.Booking
+	if err := db.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+		return err
+	}
+	return r.CheckLinesAvailability(db, &b, b.BookingServices)
+}
+
+// CheckLinesAvailability проверяет только переданные строки брони b —
+// например, часть одной компании перед выводом из листа ожидания.
+func (r *CompanyServiceRepo) CheckLinesAvailability(db *gorm.DB, b *models.Booking, lines []models.BookingService) error {
+	start, end, ok := BookingWindow(b)
+	if !ok {
+		return nil
+	}
+
+	units := map[int64]int{}
+	var order []int64
+	for _, bs := range lines {
+		if _, seen := units[bs.CompanyServiceID]; !seen {
+			order = append(order, bs.CompanyServiceID)
+		}
+		if bs.Quantity != nil && *bs.Quantity > 0 {
+			units[bs.CompanyServiceID] += *bs.Quantity
+		} else {
+			units[bs.CompanyServiceID]++
+		}
+	}
+
+	for _, csID := range order {
+		if err := r.CheckAvailability(db, csID, start, end, units[csID], b.BookingID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CompanyServiceRepo) GetBlackouts(csID int64) ([]models.CompanyServiceBlackout, error) {
+	var list []models.CompanyServiceBlackout
+	err := r.db.Where("company_service_id = ?", csID).Order("starts_at").Find(&list).Error
+	return list, err
+}
+
+func (r *CompanyServiceRepo) CreateBlackout(b *models.CompanyServiceBlackout) error {
+	return r.db.Create(b).Error
+}
+
+func (r *CompanyServiceRepo) DeleteBlackout(csID, blackoutID int64) (bool, error) {
+	res := r.db.Where("company_service_id = ? AND blackout_id = ?", csID, blackoutID).Delete(&models.CompanyServiceBlackout{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 	"oil-gas-service-booking/internal/models"
 )
@@ -13,10 +15,57 @@ func NewBookingRepo(db *gorm.DB) *BookingRepo {
 	return &BookingRepo{db: db}
 }
 
+// WithTx возвращает репозиторий, работающий внутри транзакции tx.
+func (r *BookingRepo) WithTx(tx *gorm.DB) *BookingRepo {
+	return &BookingRepo{db: tx}
+}
+
 func (r *BookingRepo) Create(b *models.Booking) error {
 	return r.db.Create(b).Error
 }
 
+func (r *BookingRepo) AddServices(lines []models.BookingService) error {
+	return r.db.Create(&lines).Error
+}
+
+// RecalculateTotals пересчитывает суммы строк и итоги брони по зафиксированным
+// в строках ценам. Брони, в которые уже нельзя добавлять строки, не трогаются.
+func (r *BookingRepo) RecalculateTotals(bookingID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var b models.Booking
+		if err := tx.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+			return err
+		}
+		if !models.BookingLinesEditable(b.Status) {
+			return nil
+		}
+		return saveTotals(tx, &b)
+	})
+}
+
+// saveTotals пересчитывает и сохраняет итоги брони b с загруженными строками.
+func saveTotals(tx *gorm.DB, b *models.Booking) error {
+	b.ApplyTotals(b.BookingServices)
+
+	for _, bs := range b.BookingServices {
+		if err := tx.Model(&models.BookingService{}).
+			Where("booking_service_id = ?", bs.BookingServiceID).
+			Update("line_total", bs.LineTotal).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.Booking{}).
+		Where("booking_id = ?", b.BookingID).
+		Updates(map[string]interface{}{
+			"currency": b.Currency,
+			"subtotal": b.Subtotal,
+			"vat_rate": b.VATRate,
+			"tax":      b.Tax,
+			"total":    b.Total,
+		}).Error
+}
+
 func (r *BookingRepo) GetAll() ([]models.Booking, error) {
 	var list []models.Booking
 	err := r.db.
@@ -34,18 +83,82 @@ func (r *BookingRepo) GetByID(id int64) (*models.Booking, error) {
 	return &b, err
 }
 
-func (r *BookingRepo) Update(b *models.Booking) error {
-	return r.db.Save(b).Error
+func (r *BookingRepo) GetWithServices(id int64) (*models.Booking, error) {
+	var b models.Booking
+	err := r.db.
+		Preload("BookingServices.CompanyService.Company").
+		Preload("BookingServices.CompanyService.Service").
+		First(&b, id).Error
+	return &b, err
+}
+
+// bookingHeaderColumns — поля шапки брони, которые сохраняет Update. Суммы
+// считаются по строкам, заказчик и организация после создания не меняются.
+var bookingHeaderColumns = []string{
+	"description", "scheduled_start", "scheduled_end",
+	"site_address", "site_field", "site_pad", "site_well",
+	"site_latitude", "site_longitude", "site_contact_name", "site_contact_phone",
+	"updated_at",
+}
+
+// Update сохраняет шапку брони и пересчитывает итоги по строкам. Смена
+// статуса администратором проходит через строки брони так же, как
+// UpdateStatus, и пишется в историю.
+func (r *BookingRepo) Update(b *models.Booking, actorUserID int64, reason *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Booking{BookingID: b.BookingID}).
+			Select(bookingHeaderColumns).
+			Updates(b).Error; err != nil {
+			return err
+		}
+
+		var current models.Booking
+		if err := tx.Preload("BookingServices").First(&current, b.BookingID).Error; err != nil {
+			return err
+		}
+		if current.Status != b.Status {
+			if _, err := applyBookingStatus(tx, &current, nil, b.Status, models.BookingActorAdmin, actorUserID, reason); err != nil {
+				return err
+			}
+		}
+
+		if models.BookingLinesEditable(current.Status) {
+			if err := saveTotals(tx, &current); err != nil {
+				return err
+			}
+		}
+		*b = current
+		return nil
+	})
 }
 
 func (r *BookingRepo) Delete(id int64) error {
 	return r.db.Delete(&models.Booking{}, id).Error
 }
 
-func (r *BookingRepo) GetByUserID(userID int64) ([]models.Booking, error) {
+// BookingFilter ограничивает выборку бронями, плановое окно которых
+// пересекается с интервалом [From, To). Пустые границы не фильтруют.
+// CompanyID сужает выборку броней компании до строк одной компании.
+type BookingFilter struct {
+	From      *time.Time
+	To        *time.Time
+	CompanyID *int64
+}
+
+func (f BookingFilter) apply(q *gorm.DB) *gorm.DB {
+	if f.From != nil {
+		q = q.Where("COALESCE(booking.scheduled_end, booking.scheduled_start) >= ?", f.From.UTC())
+	}
+	if f.To != nil {
+		q = q.Where("booking.scheduled_start < ?", f.To.UTC())
+	}
+	return q
+}
+
+func (r *BookingRepo) GetByUserID(userID int64, filter BookingFilter) ([]models.Booking, error) {
 	var bookings []models.Booking
 
-	err := r.db.
+	err := filter.apply(r.db).
 		Where("user_id = ?", userID).
 		Preload("BookingServices.CompanyService.Company").
 		Preload("BookingServices.CompanyService.Service").
@@ -54,35 +167,282 @@ func (r *BookingRepo) GetByUserID(userID int64) ([]models.Booking, error) {
 	return bookings, err
 }
 
-func (r *BookingRepo) UpdateStatus(bookingID int64, status string) error {
-	return r.db.
-		Model(&models.Booking{}).
+// UpdateStatus переводит всю бронь в новый статус через машину состояний:
+// каждая незавершённая строка проходит проверку перехода, после чего статус
+// брони вычисляется заново. Обновления условные по текущему статусу, поэтому
+// параллельная смена статуса тоже вернёт *models.BookingTransitionError.
+func (r *BookingRepo) UpdateStatus(bookingID int64, status string, actor models.BookingActor, actorUserID int64, reason *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var b models.Booking
+		if err := tx.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+			return err
+		}
+		_, err := applyBookingStatus(tx, &b, nil, status, actor, actorUserID, reason)
+		return err
+	})
+}
+
+// UpdateLinesStatus переводит в status только строки lineIDs брони; статус
+// брони пересчитывается по всем строкам.
+func (r *BookingRepo) UpdateLinesStatus(bookingID int64, lineIDs []int64, status string, actor models.BookingActor, actorUserID int64, reason *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var b models.Booking
+		if err := tx.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+			return err
+		}
+		_, err := applyBookingStatus(tx, &b, toSet(lineIDs), status, actor, actorUserID, reason)
+		return err
+	})
+}
+
+// UpdateCompanyStatus меняет статус только тех строк брони, которые относятся
+// к компаниям companyIDs — тем, от имени которых userID работает с бронями.
+// Строки других компаний не затрагиваются, статус брони пересчитывается.
+// Возвращает изменённые строки с услугой и компанией.
+func (r *BookingRepo) UpdateCompanyStatus(bookingID, userID int64, companyIDs []int64, status string, reason *string) ([]models.BookingService, error) {
+	var changed []models.BookingService
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var b models.Booking
+		if err := tx.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+			return err
+		}
+
+		owned, err := ownedLineIDs(tx, bookingID, companyIDs)
+		if err != nil {
+			return err
+		}
+		if len(owned) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		changed, err = applyBookingStatus(tx, &b, owned, status, models.BookingActorCompany, userID, reason)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(changed))
+		for _, bs := range changed {
+			ids = append(ids, bs.BookingServiceID)
+		}
+		return tx.
+			Preload("CompanyService.Company").
+			Preload("CompanyService.Service").
+			Where("booking_service_id IN ?", ids).
+			Order("booking_service_id").
+			Find(&changed).Error
+	})
+	return changed, err
+}
+
+// HoldForApproval ставит только что оформленную бронь и её строки в ожидание
+// внутреннего согласования. Это часть оформления, а не переход по графу
+// статусов: исполнители брони ещё не видели.
+func (r *BookingRepo) HoldForApproval(bookingID, actorUserID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.BookingService{}).
+			Where("booking_id = ? AND status = ?", bookingID, models.BookingStatusRequested).
+			Update("status", models.BookingStatusPendingApproval).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.Booking{}).
+			Where("booking_id = ? AND status = ?", bookingID, models.BookingStatusRequested).
+			Update("status", models.BookingStatusPendingApproval)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return &models.BookingTransitionError{To: models.BookingStatusPendingApproval, Actor: models.BookingActorCustomer}
+		}
+		return createStatusEvent(tx, bookingID, nil, models.BookingStatusRequested, models.BookingStatusPendingApproval, models.BookingActorCustomer, actorUserID, nil)
+	})
+}
+
+// GetCompanyLines возвращает строки брони, принадлежащие компаниям companyIDs.
+func (r *BookingRepo) GetCompanyLines(bookingID int64, companyIDs []int64) ([]models.BookingService, error) {
+	var lines []models.BookingService
+	err := r.db.
+		Where("booking_id = ? AND status <> ? AND company_service_id IN (?)", bookingID, models.BookingStatusPendingApproval, companyServices(r.db, companyIDs)).
+		Find(&lines).Error
+	return lines, err
+}
+
+// applyBookingStatus переводит строки брони в status. Если owned не nil,
+// меняются только строки из этого набора, иначе все незавершённые строки.
+// По каждой затронутой компании пишется событие, по самой брони — событие
+// при изменении вычисленного статуса. Бронь без строк переводится напрямую.
+func applyBookingStatus(
+	tx *gorm.DB,
+	b *models.Booking,
+	owned map[int64]bool,
+	status string,
+	actor models.BookingActor,
+	actorUserID int64,
+	reason *string,
+) ([]models.BookingService, error) {
+	if len(b.BookingServices) == 0 {
+		if err := models.CheckBookingTransition(b.Status, status, actor); err != nil {
+			return nil, err
+		}
+		if err := setBookingStatus(tx, b, status, actor); err != nil {
+			return nil, err
+		}
+		return nil, createStatusEvent(tx, b.BookingID, nil, b.Status, status, actor, actorUserID, reason)
+	}
+
+	before := map[int64][]models.BookingService{}
+	var companyOrder []int64
+
+	var changed []models.BookingService
+	for i := range b.BookingServices {
+		bs := &b.BookingServices[i]
+		if owned != nil && !owned[bs.BookingServiceID] {
+			continue
+		}
+		if bs.Status == status || (owned == nil && models.IsTerminalBookingStatus(bs.Status)) {
+			continue
+		}
+		if err := models.CheckBookingTransition(bs.Status, status, actor); err != nil {
+			return nil, err
+		}
+
+		res := tx.Model(&models.BookingService{}).
+			Where("booking_service_id = ? AND status = ?", bs.BookingServiceID, bs.Status).
+			Update("status", status)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil, &models.BookingTransitionError{From: bs.Status, To: status, Actor: actor}
+		}
+
+		var companyID int64
+		if err := tx.Model(&models.CompanyService{}).
+			Where("company_service_id = ?", bs.CompanyServiceID).
+			Pluck("company_id", &companyID).Error; err != nil {
+			return nil, err
+		}
+		if _, seen := before[companyID]; !seen {
+			companyOrder = append(companyOrder, companyID)
+		}
+		before[companyID] = append(before[companyID], *bs)
+
+		bs.Status = status
+		changed = append(changed, *bs)
+	}
+
+	if len(changed) == 0 {
+		from := b.Status
+		if owned != nil {
+			from = models.DeriveBookingStatus(Where(b.BookingServices, func(bs models.BookingService) bool {
+				return owned[bs.BookingServiceID]
+			}))
+		}
+		return nil, &models.BookingTransitionError{From: from, To: status, Actor: actor}
+	}
+
+	for _, companyID := range companyOrder {
+		companyID := companyID
+		from := models.DeriveBookingStatus(before[companyID])
+		if err := createStatusEvent(tx, b.BookingID, &companyID, from, status, actor, actorUserID, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	derived := models.DeriveBookingStatus(b.BookingServices)
+	if derived == b.Status {
+		return changed, nil
+	}
+	prev := b.Status
+	if err := setBookingStatus(tx, b, derived, actor); err != nil {
+		return nil, err
+	}
+	return changed, createStatusEvent(tx, b.BookingID, nil, prev, derived, actor, actorUserID, reason)
+}
+
+// setBookingStatus условно по текущему статусу обновляет статус брони.
+func setBookingStatus(tx *gorm.DB, b *models.Booking, status string, actor models.BookingActor) error {
+	res := tx.Model(&models.Booking{}).
+		Where("booking_id = ? AND status = ?", b.BookingID, b.Status).
+		Update("status", status)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &models.BookingTransitionError{From: b.Status, To: status, Actor: actor}
+	}
+	b.Status = status
+	return nil
+}
+
+func createStatusEvent(tx *gorm.DB, bookingID int64, companyID *int64, from, to string, actor models.BookingActor, actorUserID int64, reason *string) error {
+	return tx.Create(&models.BookingStatusEvent{
+		BookingID:   bookingID,
+		ActorUserID: &actorUserID,
+		ActorRole:   string(actor),
+		CompanyID:   companyID,
+		OldStatus:   from,
+		NewStatus:   to,
+		Reason:      reason,
+	}).Error
+}
+
+func ownedLineIDs(tx *gorm.DB, bookingID int64, companyIDs []int64) (map[int64]bool, error) {
+	var ids []int64
+	err := tx.Model(&models.BookingService{}).
+		Where("booking_id = ? AND status <> ? AND company_service_id IN (?)", bookingID, models.BookingStatusPendingApproval, companyServices(tx, companyIDs)).
+		Pluck("booking_service_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		owned[id] = true
+	}
+	return owned, nil
+}
+
+func (r *BookingRepo) GetStatusHistory(bookingID int64) ([]models.BookingStatusEvent, error) {
+	var events []models.BookingStatusEvent
+	err := r.db.
 		Where("booking_id = ?", bookingID).
-		Update("status", status).Error
+		Preload("Actor").
+		Preload("Company").
+		Order("created_at, event_id").
+		Find(&events).Error
+	return events, err
 }
 
-func (r *BookingRepo) GetByCompanyOwner(ownerUserID int64) ([]models.Booking, error) {
+// GetByCompanyMember возвращает брони, где есть услуги компаний, в которых
+// состоит userID. В BookingServices попадают только строки этих компаний;
+// строки, ждущие внутреннего согласования заказчика, исполнителю не видны.
+func (r *BookingRepo) GetByCompanyMember(userID int64, filter BookingFilter) ([]models.Booking, error) {
 	var bookings []models.Booking
-	err := r.db.
+	services := memberCompanyServices(r.db, userID, models.MemberViewRoles)
+	if filter.CompanyID != nil {
+		services = services.Where("company_service.company_id = ?", *filter.CompanyID)
+	}
+	err := filter.apply(r.db).
 		Distinct("booking.*").
 		Joins("JOIN booking_service ON booking_service.booking_id = booking.booking_id").
-		Joins("JOIN company_service ON company_service.company_service_id = booking_service.company_service_id").
-		Joins("JOIN company ON company.company_id = company_service.company_id").
-		Where("company.user_id = ?", ownerUserID).
+		Where("booking_service.company_service_id IN (?)", services).
+		Where("booking_service.status <> ?", models.BookingStatusPendingApproval).
 		Preload("User").
-		Preload("BookingServices.CompanyService.Company").
-		Preload("BookingServices.CompanyService.Service").
+		Preload("BookingServices", func(db *gorm.DB) *gorm.DB {
+			return db.
+				Where("company_service_id IN (?) AND status <> ?", services, models.BookingStatusPendingApproval).
+				Preload("CompanyService.Company").
+				Preload("CompanyService.Service")
+		}).
 		Find(&bookings).Error
 	return bookings, err
 }
 
-func (r *BookingRepo) IsBookingOwnedByCompanyOwner(bookingID, ownerUserID int64) (bool, error) {
+// IsCompanyMemberBooking — в брони есть строки компаний companyIDs.
+func (r *BookingRepo) IsCompanyMemberBooking(bookingID int64, companyIDs []int64) (bool, error) {
 	var count int64
 	err := r.db.
 		Model(&models.BookingService{}).
-		Joins("JOIN company_service ON company_service.company_service_id = booking_service.company_service_id").
-		Joins("JOIN company ON company.company_id = company_service.company_id").
-		Where("booking_service.booking_id = ? AND company.user_id = ?", bookingID, ownerUserID).
+		Where("booking_id = ? AND status <> ? AND company_service_id IN (?)", bookingID, models.BookingStatusPendingApproval, companyServices(r.db, companyIDs)).
 		Count(&count).Error
 	return count > 0, err
 }
@@ -13,6 +13,11 @@ func NewBookingServiceRepo(db *gorm.DB) *BookingServiceRepo {
 	return &BookingServiceRepo{db: db}
 }
 
+// WithTx возвращает репозиторий, работающий внутри транзакции tx.
+func (r *BookingServiceRepo) WithTx(tx *gorm.DB) *BookingServiceRepo {
+	return &BookingServiceRepo{db: tx}
+}
+
 func (r *BookingServiceRepo) Create(bs *models.BookingService) error {
 	return r.db.Create(bs).Error
 }
@@ -60,11 +65,11 @@ func (r *BookingServiceRepo) DeleteByBookingID(bookingID int64) error {
 	return r.db.Where("booking_id = ?", bookingID).Delete(&models.BookingService{}).Error
 }
 
-func (r *CompanyServiceRepo) GetByOwner(userID int64) ([]models.CompanyService, error) {
+// GetByMember возвращает услуги всех компаний, где состоит пользователь.
+func (r *CompanyServiceRepo) GetByMember(userID int64) ([]models.CompanyService, error) {
 	var list []models.CompanyService
 	err := r.db.
-		Joins("JOIN company ON company.company_id = company_service.company_id").
-		Where("company.user_id = ?", userID).
+		Where("company_id IN (?)", memberCompanyIDs(r.db, userID, models.MemberViewRoles)).
 		Preload("Company").
 		Preload("Service").
 		Find(&list).Error
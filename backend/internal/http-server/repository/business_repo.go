@@ -41,9 +41,14 @@ type CompanyServiceSearchResult struct {
 	LogoURL          *string `json:"LogoURL"`
 }
 
+// FindCompaniesByServiceID — проверенные компании, оказывающие услугу.
 func (r *BusinessRepo) FindCompaniesByServiceID(serviceID int64) ([]CompanyServiceSearchResult, error) {
 	var companySvcs []models.CompanyService
-	err := r.db.Where("service_id = ?", serviceID).Preload("Company").Find(&companySvcs).Error
+	err := r.db.
+		Joins("JOIN company ON company.company_id = company_service.company_id").
+		Where("company_service.service_id = ? AND company.verification_status = ?", serviceID, models.CompanyVerificationOK).
+		Preload("Company").
+		Find(&companySvcs).Error
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +66,15 @@ func (r *BusinessRepo) FindCompaniesByServiceID(serviceID int64) ([]CompanyServi
 	return results, nil
 }
 
+func isActiveBookingStatus(status string) bool {
+	for _, s := range models.BookingActiveStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type UserWithActiveBookings struct {
 	UserID   int64  `json:"user_id"`
 	Name     string `json:"name"`
@@ -79,7 +93,7 @@ func (r *BusinessRepo) FindUsersWithActiveBookings() ([]UserWithActiveBookings,
 	activeUsers := Where(users, func(u models.User) bool {
 		activeCount := 0
 		for _, booking := range u.Bookings {
-			if booking.Status == "requested" || booking.Status == "approved" {
+			if isActiveBookingStatus(booking.Status) {
 				activeCount++
 			}
 		}
@@ -89,7 +103,7 @@ func (r *BusinessRepo) FindUsersWithActiveBookings() ([]UserWithActiveBookings,
 	result := Select(activeUsers, func(u models.User) UserWithActiveBookings {
 		activeCount := 0
 		for _, booking := range u.Bookings {
-			if booking.Status == "requested" || booking.Status == "approved" {
+			if isActiveBookingStatus(booking.Status) {
 				activeCount++
 			}
 		}
@@ -220,7 +234,7 @@ func (r *BusinessRepo) GetSummary() (*Summary, error) {
 	if err := r.db.Model(&models.Booking{}).Count(&total).Error; err != nil {
 		return nil, err
 	}
-	if err := r.db.Model(&models.Booking{}).Where("status IN ?", []string{"requested", "approved"}).Count(&active).Error; err != nil {
+	if err := r.db.Model(&models.Booking{}).Where("status IN ?", models.BookingActiveStatuses).Count(&active).Error; err != nil {
 		return nil, err
 	}
 	if err := r.db.Model(&models.Company{}).Count(&companies).Error; err != nil {
@@ -304,9 +318,10 @@ type SearchResult struct {
 	Description string `json:"description"`
 }
 
+// SearchAll ищет по названиям услуг и проверенных компаний.
 func (r *BusinessRepo) SearchAll(query string) ([]SearchResult, error) {
 	var companies []models.Company
-	err := r.db.Find(&companies).Error
+	err := r.db.Where("verification_status = ?", models.CompanyVerificationOK).Find(&companies).Error
 	if err != nil {
 		return nil, err
 	}
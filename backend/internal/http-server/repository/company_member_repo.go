@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"oil-gas-service-booking/internal/models"
+)
+
+var (
+	// ErrPrimaryOwner — владельца из company.user_id нельзя понизить или
+	// исключить: компания всегда остаётся с владельцем.
+	ErrPrimaryOwner = errors.New("primary company owner cannot be changed or removed")
+	// ErrInvitationInvalid — приглашение не найдено, отозвано, истекло или уже принято.
+	ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+	// ErrInvitationEmail — приглашение выписано на другой email.
+	ErrInvitationEmail = errors.New("invitation was issued for another email")
+	// ErrAlreadyOwner — пользователь уже основной владелец компании.
+	ErrAlreadyOwner = errors.New("user is already the primary owner")
+	// ErrOwnerInactive — приостановленному или удалённому пользователю
+	// компанию передать нельзя.
+	ErrOwnerInactive = errors.New("new owner account is not active")
+)
+
+type CompanyMemberRepo struct {
+	db *gorm.DB
+}
+
+func NewCompanyMemberRepo(db *gorm.DB) *CompanyMemberRepo {
+	return &CompanyMemberRepo{db: db}
+}
+
+// CompanyMemberView — сотрудник компании с именем и email.
+type CompanyMemberView struct {
+	models.CompanyMember
+	Name         string  `json:"name"`
+	Email        *string `json:"email"`
+	PrimaryOwner bool    `json:"primary_owner"`
+}
+
+func (r *CompanyMemberRepo) List(companyID int64) ([]CompanyMemberView, error) {
+	var members []models.CompanyMember
+	if err := r.db.Preload("User").Preload("Company").
+		Where("company_id = ?", companyID).
+		Order("created_at, user_id").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	views := make([]CompanyMemberView, 0, len(members))
+	for _, m := range members {
+		views = append(views, CompanyMemberView{
+			CompanyMember: m,
+			Name:          m.User.Name,
+			Email:         m.User.Email,
+			PrimaryOwner:  m.Company.UserID == m.UserID,
+		})
+	}
+	return views, nil
+}
+
+// Role возвращает роль пользователя в компании; пустая строка — не сотрудник.
+func (r *CompanyMemberRepo) Role(companyID, userID int64) (string, error) {
+	var roles []string
+	err := r.db.Model(&models.CompanyMember{}).
+		Where("company_id = ? AND user_id = ?", companyID, userID).
+		Limit(1).
+		Pluck("role", &roles).Error
+	if err != nil || len(roles) == 0 {
+		return "", err
+	}
+	return roles[0], nil
+}
+
+// CompanyIDs — компании, где у пользователя одна из ролей roles.
+func (r *CompanyMemberRepo) CompanyIDs(userID int64, roles []string) ([]int64, error) {
+	ids := []int64{}
+	err := memberCompanyIDs(r.db, userID, roles).Pluck("company_id", &ids).Error
+	return ids, err
+}
+
+func (r *CompanyMemberRepo) UpdateRole(companyID, userID int64, role string) error {
+	if err := r.checkNotPrimaryOwner(companyID, userID); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.CompanyMember{}).
+			Where("company_id = ? AND user_id = ?", companyID, userID).
+			Update("role", role)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return grantRoleForMember(tx, userID, role)
+	})
+}
+
+func (r *CompanyMemberRepo) Remove(companyID, userID int64) error {
+	if err := r.checkNotPrimaryOwner(companyID, userID); err != nil {
+		return err
+	}
+	res := r.db.Where("company_id = ? AND user_id = ?", companyID, userID).Delete(&models.CompanyMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TransferOwnership делает newOwnerID основным владельцем компании и
+// возвращает прежнего. Прежний владелец остаётся в компании менеджером.
+func (r *CompanyMemberRepo) TransferOwnership(companyID, newOwnerID int64) (int64, error) {
+	var prevOwnerID int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var company models.Company
+		if err := tx.Select("company_id", "user_id").First(&company, companyID).Error; err != nil {
+			return err
+		}
+		if company.UserID == newOwnerID {
+			return ErrAlreadyOwner
+		}
+		prevOwnerID = company.UserID
+
+		var user models.User
+		if err := tx.First(&user, newOwnerID).Error; err != nil {
+			return err
+		}
+		if !user.Active() {
+			return ErrOwnerInactive
+		}
+
+		if err := tx.Model(&models.Company{}).Where("company_id = ?", companyID).
+			Update("user_id", newOwnerID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "company_id"}, {Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"role": models.MemberRoleOwner}),
+		}).Create(&models.CompanyMember{
+			CompanyID: companyID,
+			UserID:    newOwnerID,
+			Role:      models.MemberRoleOwner,
+		}).Error; err != nil {
+			return err
+		}
+		if err := grantRoleForMember(tx, newOwnerID, models.MemberRoleOwner); err != nil {
+			return err
+		}
+		return tx.Model(&models.CompanyMember{}).
+			Where("company_id = ? AND user_id = ?", companyID, prevOwnerID).
+			Update("role", models.MemberRoleManager).Error
+	})
+	return prevOwnerID, err
+}
+
+func (r *CompanyMemberRepo) checkNotPrimaryOwner(companyID, userID int64) error {
+	var company models.Company
+	if err := r.db.Select("company_id", "user_id").First(&company, companyID).Error; err != nil {
+		return err
+	}
+	if company.UserID == userID {
+		return ErrPrimaryOwner
+	}
+	return nil
+}
+
+// CreateInvitation сохраняет приглашение и возвращает одноразовый токен.
+// Прежние ожидающие приглашения на тот же email в эту компанию отзываются.
+func (r *CompanyMemberRepo) CreateInvitation(inv *models.CompanyInvitation, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	inv.Email = strings.ToLower(strings.TrimSpace(inv.Email))
+	inv.TokenHash = hashToken(raw)
+	inv.ExpiresAt = now.Add(ttl)
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CompanyInvitation{}).
+			Where("company_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.CompanyID, inv.Email).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(inv).Error
+	})
+	return raw, err
+}
+
+func (r *CompanyMemberRepo) ListInvitations(companyID int64) ([]models.CompanyInvitation, error) {
+	var list []models.CompanyInvitation
+	err := r.db.Where("company_id = ?", companyID).Order("invitation_id DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *CompanyMemberRepo) RevokeInvitation(companyID, invitationID int64) error {
+	res := r.db.Model(&models.CompanyInvitation{}).
+		Where("invitation_id = ? AND company_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID, companyID).
+		Update("revoked_at", time.Now().UTC())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation добавляет пользователя в компанию по токену приглашения.
+// Email пользователя должен совпадать с адресом приглашения. Если пользователь
+// уже сотрудник, его роль заменяется приглашённой (кроме основного владельца).
+func (r *CompanyMemberRepo) AcceptInvitation(raw string, user *models.User) (*models.CompanyInvitation, error) {
+	var inv models.CompanyInvitation
+	if err := r.db.Preload("Company").Where("token_hash = ?", hashToken(raw)).First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if inv.Status(now) != models.InvitationStatusPending {
+		return nil, ErrInvitationInvalid
+	}
+	if user.Email == nil || !strings.EqualFold(strings.TrimSpace(*user.Email), inv.Email) {
+		return nil, ErrInvitationEmail
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.CompanyInvitation{}).
+			Where("invitation_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.InvitationID).
+			Updates(map[string]interface{}{
+				"accepted_at":         now,
+				"accepted_by_user_id": user.UserID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+
+		if inv.Company.UserID != user.UserID {
+			member := models.CompanyMember{
+				CompanyID:       inv.CompanyID,
+				UserID:          user.UserID,
+				Role:            inv.Role,
+				InvitedByUserID: &inv.InvitedByUserID,
+			}
+			if err := tx.Where(models.CompanyMember{CompanyID: inv.CompanyID, UserID: user.UserID}).
+				Assign(models.CompanyMember{Role: inv.Role}).
+				FirstOrCreate(&member).Error; err != nil {
+				return err
+			}
+		}
+		return grantRoleForMember(tx, user.UserID, inv.Role)
+	})
+	if err != nil {
+		return nil, err
+	}
+	inv.AcceptedAt, inv.AcceptedByUserID = &now, &user.UserID
+	return &inv, nil
+}
+
+// CompanyMemberUserIDs возвращает сотрудников каждой из компаний — всех, кому
+// адресуются уведомления компании.
+func CompanyMemberUserIDs(db *gorm.DB, companyIDs []int64) (map[int64][]int64, error) {
+	result := make(map[int64][]int64, len(companyIDs))
+	if len(companyIDs) == 0 {
+		return result, nil
+	}
+	var members []models.CompanyMember
+	if err := db.Where("company_id IN ?", companyIDs).Order("company_id, user_id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		result[m.CompanyID] = append(result[m.CompanyID], m.UserID)
+	}
+	return result, nil
+}
+
+// grantRoleForMember повышает глобальную роль, чтобы сотруднику были доступны
+// маршруты компании: owner и manager получают company_owner, dispatcher —
+// company_dispatcher. Роль только повышается; admin и auditor не меняются.
+func grantRoleForMember(tx *gorm.DB, userID int64, memberRole string) error {
+	switch memberRole {
+	case models.MemberRoleOwner, models.MemberRoleManager:
+		return tx.Model(&models.User{}).
+			Where("user_id = ? AND role IN ?", userID, []string{models.RoleCustomer, models.RoleCompanyDispatcher}).
+			Update("role", models.RoleCompanyOwner).Error
+	case models.MemberRoleDispatcher:
+		return tx.Model(&models.User{}).
+			Where("user_id = ? AND role = ?", userID, models.RoleCustomer).
+			Update("role", models.RoleCompanyDispatcher).Error
+	}
+	return nil
+}
+
+// memberCompanyIDs — подзапрос компаний, где у пользователя одна из ролей roles.
+func memberCompanyIDs(db *gorm.DB, userID int64, roles []string) *gorm.DB {
+	return db.Model(&models.CompanyMember{}).
+		Select("company_id").
+		Where("user_id = ? AND role IN ?", userID, roles)
+}
+
+// companyServices — подзапрос услуг компаний companyIDs.
+func companyServices(db *gorm.DB, companyIDs []int64) *gorm.DB {
+	return db.Model(&models.CompanyService{}).
+		Select("company_service.company_service_id").
+		Where("company_service.company_id IN ?", companyIDs)
+}
+
+// memberCompanyServices — подзапрос услуг всех компаний пользователя с ролями roles.
+func memberCompanyServices(db *gorm.DB, userID int64, roles []string) *gorm.DB {
+	return db.Model(&models.CompanyService{}).
+		Select("company_service.company_service_id").
+		Where("company_service.company_id IN (?)", memberCompanyIDs(db, userID, roles))
+}
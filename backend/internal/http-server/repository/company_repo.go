@@ -1,11 +1,18 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"oil-gas-service-booking/internal/models"
 
 	"gorm.io/gorm"
 )
 
+// ErrVerificationState — смена статуса модерации не допускается из текущего
+// статуса компании (например, повторная отправка на проверку).
+var ErrVerificationState = errors.New("company verification status does not allow this action")
+
 type CompanyRepository struct {
 	db *gorm.DB
 }
@@ -14,16 +21,132 @@ func NewCompanyRepository(db *gorm.DB) *CompanyRepository {
 	return &CompanyRepository{db: db}
 }
 
+// Create сохраняет компанию и записывает автора её сотрудником с ролью owner.
+// Заказчик или диспетчер, заведший компанию, получает роль company_owner;
+// остальные роли не меняются.
 func (r *CompanyRepository) Create(company *models.Company) error {
-	return r.db.Create(company).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(company).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.CompanyMember{
+			CompanyID: company.CompanyID,
+			UserID:    company.UserID,
+			Role:      models.MemberRoleOwner,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("user_id = ? AND role IN ?", company.UserID, []string{models.RoleCustomer, models.RoleCompanyDispatcher}).
+			Update("role", models.RoleCompanyOwner).Error
+	})
 }
 
-func (r *CompanyRepository) GetAll() ([]models.Company, error) {
+// GetByMember возвращает компании, где пользователь состоит в любой роли.
+func (r *CompanyRepository) GetByMember(userID int64) ([]models.Company, error) {
 	var companies []models.Company
-	err := r.db.Find(&companies).Error
+	err := r.db.
+		Where("company_id IN (?)", memberCompanyIDs(r.db, userID, models.MemberViewRoles)).
+		Order("company_id").
+		Find(&companies).Error
 	return companies, err
 }
 
+// GetAll возвращает компании; непустой status оставляет только компании с
+// этим статусом модерации.
+func (r *CompanyRepository) GetAll(status string) ([]models.Company, error) {
+	var companies []models.Company
+	q := r.db
+	if status != "" {
+		q = q.Where("verification_status = ?", status)
+	}
+	err := q.Find(&companies).Error
+	return companies, err
+}
+
+// ModerationQueue — компании с данным статусом модерации, в порядке отправки
+// на проверку (первыми — ждущие дольше всех).
+func (r *CompanyRepository) ModerationQueue(status string) ([]models.Company, error) {
+	var companies []models.Company
+	err := r.db.
+		Where("verification_status = ?", status).
+		Order("submitted_at IS NULL, submitted_at, company_id").
+		Find(&companies).Error
+	return companies, err
+}
+
+// SubmitForReview переводит черновик или отклонённую компанию в очередь
+// модерации. Предыдущий комментарий проверяющего сохраняется до нового решения.
+func (r *CompanyRepository) SubmitForReview(company *models.Company) error {
+	now := time.Now()
+	res := r.db.Model(&models.Company{}).
+		Where("company_id = ? AND verification_status IN ?", company.CompanyID,
+			[]string{models.CompanyVerificationDraft, models.CompanyVerificationRejected}).
+		Updates(map[string]interface{}{
+			"verification_status": models.CompanyVerificationPending,
+			"submitted_at":        now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVerificationState
+	}
+	company.VerificationStatus = models.CompanyVerificationPending
+	company.SubmittedAt = &now
+	return nil
+}
+
+// Review записывает решение модератора. Одобрить можно только компанию из
+// очереди; отклонить — и ранее проверенную, тогда она скрывается от заказчиков.
+func (r *CompanyRepository) Review(company *models.Company, reviewerID int64, status string, notes *string) error {
+	from := []string{models.CompanyVerificationPending}
+	if status == models.CompanyVerificationRejected {
+		from = append(from, models.CompanyVerificationOK)
+	}
+	now := time.Now()
+	res := r.db.Model(&models.Company{}).
+		Where("company_id = ? AND verification_status IN ?", company.CompanyID, from).
+		Updates(map[string]interface{}{
+			"verification_status": status,
+			"review_notes":        notes,
+			"reviewed_at":         now,
+			"reviewed_by_user_id": reviewerID,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVerificationState
+	}
+	company.VerificationStatus = status
+	company.ReviewNotes = notes
+	company.ReviewedAt = &now
+	company.ReviewedByUserID = &reviewerID
+	return nil
+}
+
+// OwnerIDs — сотрудники компании с ролью owner.
+func (r *CompanyRepository) OwnerIDs(companyID int64) ([]int64, error) {
+	var ids []int64
+	err := r.db.Model(&models.CompanyMember{}).
+		Where("company_id = ? AND role = ?", companyID, models.MemberRoleOwner).
+		Order("user_id").
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// ModeratorIDs — действующие администраторы, которых уведомляют о новых
+// компаниях в очереди модерации.
+func (r *CompanyRepository) ModeratorIDs() ([]int64, error) {
+	var ids []int64
+	err := r.db.Model(&models.User{}).
+		Where("role = ? AND suspended_at IS NULL AND deactivated_at IS NULL", models.RoleAdmin).
+		Order("user_id").
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
 func (r *CompanyRepository) GetByID(id int64) (*models.Company, error) {
 	var company models.Company
 	err := r.db.First(&company, id).Error
@@ -33,10 +156,65 @@ func (r *CompanyRepository) GetByID(id int64) (*models.Company, error) {
 	return &company, nil
 }
 
+// Update сохраняет профиль компании и статус модерации. Владелец, логотип и
+// решение модератора меняются отдельными методами.
 func (r *CompanyRepository) Update(company *models.Company) error {
-	return r.db.Save(company).Error
+	return r.db.Model(company).
+		Select("name", "description", "address", "verification_status", "submitted_at", "updated_at").
+		Updates(company).Error
 }
 
 func (r *CompanyRepository) Delete(id int64) error {
 	return r.db.Delete(&models.Company{}, id).Error
 }
+
+// CompanyRouting — настройки, по которым компании подбираются заявки.
+type CompanyRouting struct {
+	Regions            []string `json:"regions"`
+	OptedOutCategories []string `json:"opted_out_categories"`
+}
+
+func (r *CompanyRepository) GetRouting(companyID int64) (*CompanyRouting, error) {
+	routing := CompanyRouting{Regions: []string{}, OptedOutCategories: []string{}}
+	if err := r.db.Model(&models.CompanyRegion{}).
+		Where("company_id = ?", companyID).
+		Order("region").
+		Pluck("region", &routing.Regions).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.CompanyRequestOptOut{}).
+		Where("company_id = ?", companyID).
+		Order("category").
+		Pluck("category", &routing.OptedOutCategories).Error; err != nil {
+		return nil, err
+	}
+	return &routing, nil
+}
+
+// SetRouting заменяет регионы и/или список категорий-исключений компании.
+// nil оставляет соответствующий список без изменений.
+func (r *CompanyRepository) SetRouting(companyID int64, regions, optOuts []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if regions != nil {
+			if err := tx.Where("company_id = ?", companyID).Delete(&models.CompanyRegion{}).Error; err != nil {
+				return err
+			}
+			for _, region := range regions {
+				if err := tx.Create(&models.CompanyRegion{CompanyID: companyID, Region: region}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if optOuts != nil {
+			if err := tx.Where("company_id = ?", companyID).Delete(&models.CompanyRequestOptOut{}).Error; err != nil {
+				return err
+			}
+			for _, category := range optOuts {
+				if err := tx.Create(&models.CompanyRequestOptOut{CompanyID: companyID, Category: category}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -35,6 +35,16 @@ func (r *CompanyServiceRepo) GetByID(id int64) (*models.CompanyService, error) {
 	return &cs, err
 }
 
+func (r *CompanyServiceRepo) GetByIDs(ids []int64) ([]models.CompanyService, error) {
+	var list []models.CompanyService
+	err := r.db.
+		Where("company_service_id IN ?", ids).
+		Preload("Company").
+		Preload("Service").
+		Find(&list).Error
+	return list, err
+}
+
 func (r *CompanyServiceRepo) GetByCompanyID(companyID int64) ([]models.CompanyService, error) {
 	var list []models.CompanyService
 	err := r.db.
@@ -65,8 +75,9 @@ func (r *CompanyServiceRepo) GetByCompanyAndService(companyID, serviceID int64)
 	return &cs, err
 }
 
+// Update сохраняет цену, валюту и мощность услуги компании.
 func (r *CompanyServiceRepo) Update(cs *models.CompanyService) error {
-	return r.db.Save(cs).Error
+	return r.db.Model(cs).Select("price", "currency", "capacity", "updated_at").Updates(cs).Error
 }
 
 func (r *CompanyServiceRepo) Delete(id int64) error {
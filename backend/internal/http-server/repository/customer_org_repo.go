@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+var (
+	// ErrAlreadyInOrg — пользователь уже состоит в организации заказчика.
+	ErrAlreadyInOrg = errors.New("user already belongs to a customer organization")
+	// ErrLastOrgAdmin — в организации должен остаться хотя бы один администратор.
+	ErrLastOrgAdmin = errors.New("organization must keep at least one admin")
+)
+
+type CustomerOrgRepo struct {
+	db *gorm.DB
+}
+
+func NewCustomerOrgRepo(db *gorm.DB) *CustomerOrgRepo {
+	return &CustomerOrgRepo{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий внутри транзакции tx.
+func (r *CustomerOrgRepo) WithTx(tx *gorm.DB) *CustomerOrgRepo {
+	return &CustomerOrgRepo{db: tx}
+}
+
+// CustomerOrgMemberView — сотрудник организации с именем и email.
+type CustomerOrgMemberView struct {
+	models.CustomerOrgMember
+	Name  string  `json:"name"`
+	Email *string `json:"email"`
+}
+
+// Create сохраняет организацию; автор становится её администратором.
+func (r *CustomerOrgRepo) Create(org *models.CustomerOrg) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.CustomerOrgMember{}).Where("user_id = ?", org.CreatedByUserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyInOrg
+		}
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.CustomerOrgMember{
+			OrgID:  org.OrgID,
+			UserID: org.CreatedByUserID,
+			Role:   models.OrgRoleAdmin,
+		}).Error
+	})
+}
+
+func (r *CustomerOrgRepo) GetByID(id int64) (*models.CustomerOrg, error) {
+	var org models.CustomerOrg
+	if err := r.db.First(&org, id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *CustomerOrgRepo) Update(org *models.CustomerOrg) error {
+	return r.db.Model(org).Select("name", "auto_approve_limit").Updates(org).Error
+}
+
+// MembershipOf возвращает членство пользователя вместе с организацией;
+// nil — пользователь не состоит ни в одной организации.
+func (r *CustomerOrgRepo) MembershipOf(userID int64) (*models.CustomerOrgMember, error) {
+	var m models.CustomerOrgMember
+	err := r.db.Preload("Org").Where("user_id = ?", userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *CustomerOrgRepo) Members(orgID int64) ([]CustomerOrgMemberView, error) {
+	var members []models.CustomerOrgMember
+	if err := r.db.Preload("User").Where("org_id = ?", orgID).Order("created_at, user_id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	views := make([]CustomerOrgMemberView, 0, len(members))
+	for _, m := range members {
+		views = append(views, CustomerOrgMemberView{CustomerOrgMember: m, Name: m.User.Name, Email: m.User.Email})
+	}
+	return views, nil
+}
+
+// CreateInvitation сохраняет приглашение в организацию и возвращает
+// одноразовый токен. Прежние ожидающие приглашения на тот же email в эту
+// организацию отзываются.
+func (r *CustomerOrgRepo) CreateInvitation(inv *models.CustomerOrgInvitation, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	inv.Email = strings.ToLower(strings.TrimSpace(inv.Email))
+	inv.TokenHash = hashToken(raw)
+	inv.ExpiresAt = now.Add(ttl)
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CustomerOrgInvitation{}).
+			Where("org_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.OrgID, inv.Email).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(inv).Error
+	})
+	return raw, err
+}
+
+func (r *CustomerOrgRepo) RevokeInvitation(orgID, invitationID int64) error {
+	res := r.db.Model(&models.CustomerOrgInvitation{}).
+		Where("invitation_id = ? AND org_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID, orgID).
+		Update("revoked_at", time.Now().UTC())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation добавляет пользователя в организацию по токену
+// приглашения. Email пользователя должен совпадать с адресом приглашения,
+// и пользователь не должен состоять в другой организации.
+func (r *CustomerOrgRepo) AcceptInvitation(raw string, user *models.User) (*models.CustomerOrgInvitation, error) {
+	var inv models.CustomerOrgInvitation
+	if err := r.db.Preload("Org").Where("token_hash = ?", hashToken(raw)).First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if inv.Status(now) != models.InvitationStatusPending {
+		return nil, ErrInvitationInvalid
+	}
+	if user.Email == nil || !strings.EqualFold(strings.TrimSpace(*user.Email), inv.Email) {
+		return nil, ErrInvitationEmail
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.CustomerOrgMember{}).Where("user_id = ?", user.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyInOrg
+		}
+
+		res := tx.Model(&models.CustomerOrgInvitation{}).
+			Where("invitation_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.InvitationID).
+			Updates(map[string]interface{}{
+				"accepted_at":         now,
+				"accepted_by_user_id": user.UserID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+
+		return tx.Create(&models.CustomerOrgMember{
+			OrgID:         inv.OrgID,
+			UserID:        user.UserID,
+			Role:          inv.Role,
+			ApprovalLimit: inv.ApprovalLimit,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	inv.AcceptedAt, inv.AcceptedByUserID = &now, &user.UserID
+	return &inv, nil
+}
+
+func (r *CustomerOrgRepo) UpdateMember(orgID, userID int64, role string, limit *float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if role != models.OrgRoleAdmin {
+			if err := keepOrgAdmin(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		res := tx.Model(&models.CustomerOrgMember{}).
+			Where("org_id = ? AND user_id = ?", orgID, userID).
+			Updates(map[string]interface{}{"role": role, "approval_limit": limit})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *CustomerOrgRepo) RemoveMember(orgID, userID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepOrgAdmin(tx, orgID, userID); err != nil {
+			return err
+		}
+		res := tx.Where("org_id = ? AND user_id = ?", orgID, userID).Delete(&models.CustomerOrgMember{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// keepOrgAdmin не даёт лишить организацию последнего администратора.
+func keepOrgAdmin(tx *gorm.DB, orgID, userID int64) error {
+	var others int64
+	if err := tx.Model(&models.CustomerOrgMember{}).
+		Where("org_id = ? AND role = ? AND user_id <> ?", orgID, models.OrgRoleAdmin, userID).
+		Count(&others).Error; err != nil {
+		return err
+	}
+	var self int64
+	if err := tx.Model(&models.CustomerOrgMember{}).
+		Where("org_id = ? AND role = ? AND user_id = ?", orgID, models.OrgRoleAdmin, userID).
+		Count(&self).Error; err != nil {
+		return err
+	}
+	if self > 0 && others == 0 {
+		return ErrLastOrgAdmin
+	}
+	return nil
+}
+
+// Approvers — менеджеры и администраторы организации, кроме exceptUserID.
+// withinLimit оставляет только тех, кто может согласовать сумму total.
+func (r *CustomerOrgRepo) Approvers(orgID int64, total *float64, withinLimit bool, exceptUserID int64) ([]int64, error) {
+	var members []models.CustomerOrgMember
+	if err := r.db.Where("org_id = ? AND role IN ? AND user_id <> ?", orgID,
+		[]string{models.OrgRoleAdmin, models.OrgRoleManager}, exceptUserID).
+		Order("user_id").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for _, m := range members {
+		if !withinLimit || m.CanApprove(total) {
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids, nil
+}
+
+// PendingBookings — брони организации, ожидающие внутреннего согласования.
+func (r *CustomerOrgRepo) PendingBookings(orgID int64) ([]models.Booking, error) {
+	var list []models.Booking
+	err := r.db.
+		Where("org_id = ? AND status = ?", orgID, models.BookingStatusPendingApproval).
+		Preload("User").
+		Preload("BookingServices.CompanyService.Company").
+		Preload("BookingServices.CompanyService.Service").
+		Order("created_at").
+		Find(&list).Error
+	return list, err
+}
+
+// Approvals — цепочка согласования брони по порядку.
+func (r *CustomerOrgRepo) Approvals(bookingID int64) ([]models.BookingApproval, error) {
+	var list []models.BookingApproval
+	err := r.db.Where("booking_id = ?", bookingID).Order("approval_id").Find(&list).Error
+	return list, err
+}
+
+// ApprovedTotal — сумма, на которую бронь была окончательно согласована;
+// nil, если согласования не было.
+func (r *CustomerOrgRepo) ApprovedTotal(bookingID int64) (*float64, error) {
+	var approvals []models.BookingApproval
+	err := r.db.
+		Where("booking_id = ? AND decision = ?", bookingID, models.ApprovalDecisionApproved).
+		Order("approval_id DESC").
+		Limit(1).
+		Find(&approvals).Error
+	if err != nil || len(approvals) == 0 {
+		return nil, err
+	}
+	return approvals[0].Total, nil
+}
+
+// RecordDecision сохраняет шаг согласования и, если решение окончательное,
+// переводит бронь в status от имени согласующего в той же транзакции.
+func (r *CustomerOrgRepo) RecordDecision(approval *models.BookingApproval, status string, reason *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(approval).Error; err != nil {
+			return err
+		}
+		if status == "" {
+			return nil
+		}
+		return (&BookingRepo{db: tx}).UpdateStatus(approval.BookingID, status, models.BookingActorApprover, approval.ApproverUserID, reason)
+	})
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+// ErrImpersonationEnded — сеанс уже завершён или истёк.
+var ErrImpersonationEnded = errors.New("impersonation session has already ended")
+
+// ImpersonationFilter — отбор сеансов; пустые поля не учитываются.
+type ImpersonationFilter struct {
+	AdminUserID *int64
+	UserID      *int64
+	ActiveOnly  bool
+}
+
+type ImpersonationRepo struct {
+	db *gorm.DB
+}
+
+func NewImpersonationRepo(db *gorm.DB) *ImpersonationRepo {
+	return &ImpersonationRepo{db: db}
+}
+
+func (r *ImpersonationRepo) Create(s *models.ImpersonationSession) error {
+	return r.db.Create(s).Error
+}
+
+func (r *ImpersonationRepo) Get(sessionID int64) (*models.ImpersonationSession, error) {
+	var s models.ImpersonationSession
+	if err := r.db.First(&s, "session_id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List — сеансы по фильтру, новые первыми.
+func (r *ImpersonationRepo) List(f ImpersonationFilter) ([]models.ImpersonationSession, error) {
+	q := r.db.Model(&models.ImpersonationSession{})
+	if f.AdminUserID != nil {
+		q = q.Where("admin_user_id = ?", *f.AdminUserID)
+	}
+	if f.UserID != nil {
+		q = q.Where("user_id = ?", *f.UserID)
+	}
+	if f.ActiveOnly {
+		q = q.Where("ended_at IS NULL AND expires_at > ?", time.Now().UTC())
+	}
+	var list []models.ImpersonationSession
+	err := q.Order("created_at DESC, session_id DESC").Find(&list).Error
+	return list, err
+}
+
+// End завершает сеанс; access-токен отзывает вызывающий.
+func (r *ImpersonationRepo) End(sessionID int64) error {
+	now := time.Now().UTC()
+	res := r.db.Model(&models.ImpersonationSession{}).
+		Where("session_id = ? AND ended_at IS NULL AND expires_at > ?", sessionID, now).
+		Update("ended_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrImpersonationEnded
+	}
+	return nil
+}
+
+// Requests — журнал запросов сеанса в порядке выполнения.
+func (r *ImpersonationRepo) Requests(sessionID int64) ([]models.ImpersonationRequest, error) {
+	var list []models.ImpersonationRequest
+	err := r.db.Where("session_id = ?", sessionID).Order("request_id").Find(&list).Error
+	return list, err
+}
+
+// StartImpersonatedRequest реализует middleware.ImpersonationAuditor.
+func (r *ImpersonationRepo) StartImpersonatedRequest(entry *models.ImpersonationRequest) error {
+	var ids []int64
+	if err := r.db.Model(&models.ImpersonationSession{}).
+		Where("token_id = ?", entry.TokenID).Limit(1).Pluck("session_id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		entry.SessionID = &ids[0]
+	}
+	return r.db.Create(entry).Error
+}
+
+// FinishImpersonatedRequest реализует middleware.ImpersonationAuditor.
+func (r *ImpersonationRepo) FinishImpersonatedRequest(requestID int64, status int) error {
+	return r.db.Model(&models.ImpersonationRequest{}).
+		Where("request_id = ?", requestID).Update("status", status).Error
+}
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+// Веса признаков при подборе компаний для заявки. Сумма максимумов — 120.
+const (
+	matchWeightCatalogExact   = 50.0
+	matchWeightCatalogPartial = 25.0
+	matchWeightCategory       = 30.0
+	matchWeightRegion         = 20.0
+	matchWeightResponsiveness = 20.0
+)
+
+type MatchingRepo struct {
+	db *gorm.DB
+}
+
+func NewMatchingRepo(db *gorm.DB) *MatchingRepo {
+	return &MatchingRepo{db: db}
+}
+
+// CompanyMatch — компания-кандидат для заявки с разбивкой оценки по признакам.
+type CompanyMatch struct {
+	CompanyID           int64   `json:"company_id"`
+	CompanyName         string  `json:"company_name"`
+	OwnerUserID         int64   `json:"owner_user_id"`
+	Score               float64 `json:"score"`
+	CatalogScore        float64 `json:"catalog_score"`
+	CategoryScore       float64 `json:"category_score"`
+	RegionScore         float64 `json:"region_score"`
+	ResponsivenessScore float64 `json:"responsiveness_score"`
+	Invited             int     `json:"invited"`
+	Responded           int     `json:"responded"`
+	AlreadyInvited      bool    `json:"already_invited"`
+}
+
+type invitationStats struct {
+	CompanyID int64
+	Invited   int
+	Responded int
+}
+
+// RequestCategory возвращает категорию заявки: указанную заказчиком, а если её
+// нет — категорию услуги каталога с тем же названием.
+func (r *MatchingRepo) RequestCategory(req *models.ServiceRequest) (string, error) {
+	if req.Category != nil && *req.Category != "" {
+		return normalizeKey(*req.Category), nil
+	}
+	var categories []string
+	err := r.db.Model(&models.Service{}).
+		Where("LOWER(title) = LOWER(?) AND category IS NOT NULL", req.ServiceName).
+		Limit(1).
+		Pluck("category", &categories).Error
+	if err != nil || len(categories) == 0 {
+		return "", err
+	}
+	return normalizeKey(categories[0]), nil
+}
+
+// MatchCompanies ранжирует компании для заявки по каталогу услуг, категории,
+// регионам работы и отзывчивости на прошлые приглашения. Компании без
+// совпадений по каталогу или категории, отказавшиеся от категории
+// заявки, уже откликнувшиеся на неё и не прошедшие модерацию в список не
+// попадают.
+func (r *MatchingRepo) MatchCompanies(req *models.ServiceRequest) ([]CompanyMatch, error) {
+	category, err := r.RequestCategory(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var companies []models.Company
+	if err := r.db.Preload("CompanyServices.Service").
+		Where("verification_status = ?", models.CompanyVerificationOK).
+		Order("company_id").
+		Find(&companies).Error; err != nil {
+		return nil, err
+	}
+
+	var regions []models.CompanyRegion
+	if err := r.db.Find(&regions).Error; err != nil {
+		return nil, err
+	}
+	regionsOf := map[int64]map[string]bool{}
+	for _, cr := range regions {
+		if regionsOf[cr.CompanyID] == nil {
+			regionsOf[cr.CompanyID] = map[string]bool{}
+		}
+		regionsOf[cr.CompanyID][normalizeKey(cr.Region)] = true
+	}
+
+	optedOut := map[int64]bool{}
+	if category != "" {
+		var ids []int64
+		if err := r.db.Model(&models.CompanyRequestOptOut{}).
+			Where("category = ?", category).
+			Pluck("company_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			optedOut[id] = true
+		}
+	}
+
+	var stats []invitationStats
+	if err := r.db.Raw(`SELECT i.company_id, COUNT(*) AS invited, COUNT(resp.response_id) AS responded
+		FROM service_request_invitation i
+		LEFT JOIN service_request_response resp
+			ON resp.request_id = i.request_id AND resp.company_id = i.company_id
+		GROUP BY i.company_id`).Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	statsOf := make(map[int64]invitationStats, len(stats))
+	for _, st := range stats {
+		statsOf[st.CompanyID] = st
+	}
+
+	var invitedIDs, respondedIDs []int64
+	if err := r.db.Model(&models.ServiceRequestInvitation{}).
+		Where("request_id = ?", req.RequestID).
+		Pluck("company_id", &invitedIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.ServiceRequestResponse{}).
+		Where("request_id = ?", req.RequestID).
+		Pluck("company_id", &respondedIDs).Error; err != nil {
+		return nil, err
+	}
+	invited := toSet(invitedIDs)
+	responded := toSet(respondedIDs)
+
+	wantTitle := normalizeKey(req.ServiceName)
+	wantWords := significantWords(req.ServiceName)
+	wantRegion := ""
+	if req.Region != nil {
+		wantRegion = normalizeKey(*req.Region)
+	}
+
+	var matches []CompanyMatch
+	for _, c := range companies {
+		if optedOut[c.CompanyID] || responded[c.CompanyID] {
+			continue
+		}
+
+		m := CompanyMatch{
+			CompanyID:      c.CompanyID,
+			CompanyName:    c.Name,
+			OwnerUserID:    c.UserID,
+			AlreadyInvited: invited[c.CompanyID],
+		}
+		for _, cs := range c.CompanyServices {
+			title := normalizeKey(cs.Service.Title)
+			switch {
+			case title == wantTitle:
+				m.CatalogScore = matchWeightCatalogExact
+			case m.CatalogScore < matchWeightCatalogPartial && sharesWord(wantWords, significantWords(cs.Service.Title)):
+				m.CatalogScore = matchWeightCatalogPartial
+			}
+			if category != "" && cs.Service.Category != nil && normalizeKey(*cs.Service.Category) == category {
+				m.CategoryScore = matchWeightCategory
+			}
+		}
+		if wantRegion != "" && regionsOf[c.CompanyID][wantRegion] {
+			m.RegionScore = matchWeightRegion
+		}
+		// Регион только поднимает компанию в списке: без совпадения по каталогу
+		// или категории заявку получали бы все компании региона.
+		if m.CatalogScore+m.CategoryScore == 0 {
+			continue
+		}
+
+		// Сглаживание Лапласа: у новой компании без истории отзывчивость 0,5.
+		st := statsOf[c.CompanyID]
+		m.Invited, m.Responded = st.Invited, st.Responded
+		m.ResponsivenessScore = roundScore(matchWeightResponsiveness * float64(st.Responded+1) / float64(st.Invited+2))
+
+		m.Score = roundScore(m.CatalogScore + m.CategoryScore + m.RegionScore + m.ResponsivenessScore)
+		matches = append(matches, m)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches, nil
+}
+
+// RecordInvitations сохраняет факт рассылки заявки компаниям.
+func (r *MatchingRepo) RecordInvitations(requestID int64, matches []CompanyMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	invitations := make([]models.ServiceRequestInvitation, 0, len(matches))
+	for _, m := range matches {
+		invitations = append(invitations, models.ServiceRequestInvitation{
+			RequestID: requestID,
+			CompanyID: m.CompanyID,
+			Score:     m.Score,
+		})
+	}
+	return r.db.Create(&invitations).Error
+}
+
+func normalizeKey(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// significantWords выделяет из названия слова длиннее трёх символов —
+// предлоги и сокращения в скобках на совпадение не влияют.
+func significantWords(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return Where(words, func(w string) bool { return len([]rune(w)) > 3 })
+}
+
+func sharesWord(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toSet(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func roundScore(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"oil-gas-service-booking/internal/models"
+	"oil-gas-service-booking/internal/totp"
+)
+
+var (
+	// ErrMFAAlreadyEnabled — второй фактор уже подключён; сначала его нужно отключить.
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrMFANotEnrolled — настройка второго фактора не начата или не завершена.
+	ErrMFANotEnrolled = errors.New("two-factor authentication is not set up")
+	// ErrMFAInvalidCode — неверный или уже использованный код.
+	ErrMFAInvalidCode = errors.New("invalid two-factor code")
+	// ErrMFAChallengeInvalid — шаг входа истёк, уже пройден или исчерпал попытки.
+	ErrMFAChallengeInvalid = errors.New("login step expired, start again")
+)
+
+const (
+	// RecoveryCodeCount — сколько резервных кодов выдаётся за раз.
+	RecoveryCodeCount = 10
+	// MFAChallengeAttempts — сколько кодов можно ввести на одном шаге входа.
+	MFAChallengeAttempts = 5
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFARepo struct {
+	db *gorm.DB
+}
+
+func NewMFARepo(db *gorm.DB) *MFARepo {
+	return &MFARepo{db: db}
+}
+
+// Get возвращает настройку второго фактора или nil, если её нет.
+func (r *MFARepo) Get(userID int64) (*models.UserTOTP, error) {
+	var t models.UserTOTP
+	err := r.db.First(&t, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Enabled сообщает, подключён ли у пользователя второй фактор.
+func (r *MFARepo) Enabled(userID int64) (bool, error) {
+	t, err := r.Get(userID)
+	return t != nil && t.ConfirmedAt != nil, err
+}
+
+// BeginSetup создаёт новый секрет. Незавершённая настройка заменяется.
+func (r *MFARepo) BeginSetup(userID int64) (string, error) {
+	current, err := r.Get(userID)
+	if err != nil {
+		return "", err
+	}
+	if current != nil && current.ConfirmedAt != nil {
+		return "", ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	err = r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "created_at"}),
+	}).Create(&models.UserTOTP{UserID: userID, Secret: secret, CreatedAt: time.Now().UTC()}).Error
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Confirm завершает настройку первым верным кодом и выдаёт резервные коды.
+func (r *MFARepo) Confirm(userID int64, code string) ([]string, error) {
+	var codes []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var t models.UserTOTP
+		if err := tx.First(&t, "user_id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMFANotEnrolled
+			}
+			return err
+		}
+		if t.ConfirmedAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+		step, ok := totp.Validate(t.Secret, code, time.Now(), t.LastUsedStep)
+		if !ok {
+			return ErrMFAInvalidCode
+		}
+		if err := tx.Model(&t).Updates(map[string]interface{}{
+			"confirmed_at":   time.Now().UTC(),
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyCode проверяет код аутентификатора подключённого второго фактора.
+func (r *MFARepo) VerifyCode(userID int64, code string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var t models.UserTOTP
+		if err := tx.First(&t, "user_id = ? AND confirmed_at IS NOT NULL", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMFANotEnrolled
+			}
+			return err
+		}
+		step, ok := totp.Validate(t.Secret, code, time.Now(), t.LastUsedStep)
+		if !ok {
+			return ErrMFAInvalidCode
+		}
+		// Условие на last_used_step не даёт принять один код в двух
+		// параллельных запросах.
+		res := tx.Model(&models.UserTOTP{}).
+			Where("user_id = ? AND last_used_step < ?", userID, step).
+			Update("last_used_step", step)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrMFAInvalidCode
+		}
+		return nil
+	})
+}
+
+// UseRecoveryCode погашает резервный код.
+func (r *MFARepo) UseRecoveryCode(userID int64, code string) error {
+	res := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now().UTC())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes выдаёт новый набор резервных кодов взамен прежнего.
+func (r *MFARepo) RegenerateRecoveryCodes(userID int64) ([]string, error) {
+	var codes []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RecoveryCodesLeft — сколько резервных кодов ещё не использовано.
+func (r *MFARepo) RecoveryCodesLeft(userID int64) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+// Disable удаляет второй фактор и резервные коды пользователя и закрывает
+// начатые шаги входа.
+func (r *MFARepo) Disable(userID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.MFAChallenge{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", time.Now().UTC()).Error
+	})
+}
+
+// CreateChallenge начинает второй шаг входа и возвращает его токен.
+func (r *MFARepo) CreateChallenge(userID int64, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = r.db.Create(&models.MFAChallenge{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Challenge возвращает действующий шаг входа по токену.
+func (r *MFARepo) Challenge(raw string) (*models.MFAChallenge, error) {
+	var c models.MFAChallenge
+	if err := r.db.Where("token_hash = ?", hashToken(raw)).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFAChallengeInvalid
+		}
+		return nil, err
+	}
+	if c.UsedAt != nil || !c.ExpiresAt.After(time.Now().UTC()) || c.Attempts >= MFAChallengeAttempts {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return &c, nil
+}
+
+// FailChallenge учитывает неверный код на шаге входа.
+func (r *MFARepo) FailChallenge(challengeID int64) error {
+	return r.db.Model(&models.MFAChallenge{}).
+		Where("challenge_id = ?", challengeID).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// CompleteChallenge закрывает шаг входа. Повторно пройти его нельзя.
+func (r *MFARepo) CompleteChallenge(challengeID int64) error {
+	res := r.db.Model(&models.MFAChallenge{}).
+		Where("challenge_id = ? AND used_at IS NULL", challengeID).
+		Update("used_at", time.Now().UTC())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMFAChallengeInvalid
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int64) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, RecoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+// ErrNoErasureRequest — у пользователя нет ожидающего запроса на удаление.
+var ErrNoErasureRequest = errors.New("no pending erasure request")
+
+// ExportProfile — учётная запись без хеша пароля.
+type ExportProfile struct {
+	UserID          int64      `json:"user_id"`
+	Name            string     `json:"name"`
+	Email           *string    `json:"email"`
+	Role            string     `json:"role"`
+	AvatarURL       *string    `json:"avatar_url"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PersonalDataExport — всё, что сервис хранит о пользователе.
+type PersonalDataExport struct {
+	ExportedAt             time.Time                  `json:"exported_at"`
+	Profile                ExportProfile              `json:"profile"`
+	TwoFactorEnabled       bool                       `json:"two_factor_enabled"`
+	Bookings               []models.Booking           `json:"bookings"`
+	ServiceRequests        []models.ServiceRequest    `json:"service_requests"`
+	Notifications          []models.Notification      `json:"notifications"`
+	CompanyMemberships     []models.CompanyMember     `json:"company_memberships"`
+	CustomerOrgMemberships []models.CustomerOrgMember `json:"customer_org_memberships"`
+	APIKeys                []models.APIKey            `json:"api_keys"`
+	Sessions               []models.UserSession       `json:"sessions"`
+	SecurityEvents         []models.SecurityEvent     `json:"security_events"`
+	ErasureRequests        []models.ErasureRequest    `json:"erasure_requests"`
+}
+
+type PrivacyRepo struct {
+	db *gorm.DB
+}
+
+func NewPrivacyRepo(db *gorm.DB) *PrivacyRepo {
+	return &PrivacyRepo{db: db}
+}
+
+// Export собирает данные пользователя для выгрузки.
+func (r *PrivacyRepo) Export(userID int64) (*PersonalDataExport, error) {
+	var u models.User
+	if err := r.db.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	out := &PersonalDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			UserID:          u.UserID,
+			Name:            u.Name,
+			Email:           u.Email,
+			Role:            u.Role,
+			AvatarURL:       u.AvatarURL,
+			EmailVerifiedAt: u.EmailVerifiedAt,
+			CreatedAt:       u.CreatedAt,
+			UpdatedAt:       u.UpdatedAt,
+		},
+	}
+
+	var mfa int64
+	if err := r.db.Model(&models.UserTOTP{}).Where("user_id = ? AND confirmed_at IS NOT NULL", userID).Count(&mfa).Error; err != nil {
+		return nil, err
+	}
+	out.TwoFactorEnabled = mfa > 0
+
+	queries := []struct {
+		dst   interface{}
+		query *gorm.DB
+	}{
+		{&out.Bookings, r.db.Preload("BookingServices").Order("booking_id")},
+		{&out.ServiceRequests, r.db.Order("request_id")},
+		{&out.Notifications, r.db.Order("created_at")},
+		{&out.CompanyMemberships, r.db.Order("company_id")},
+		{&out.CustomerOrgMemberships, r.db},
+		{&out.APIKeys, r.db.Order("key_id")},
+		{&out.Sessions, r.db.Order("session_id")},
+		{&out.SecurityEvents, r.db.Order("created_at")},
+		{&out.ErasureRequests, r.db.Order("request_id")},
+	}
+	for _, q := range queries {
+		if err := q.query.Where("user_id = ?", userID).Find(q.dst).Error; err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// RequestErasure ставит удаление данных в очередь не раньше чем через grace.
+// Если запрос уже ждёт исполнения, возвращается он.
+func (r *PrivacyRepo) RequestErasure(userID int64, grace time.Duration) (*models.ErasureRequest, error) {
+	if req, err := r.PendingErasure(userID); err == nil {
+		return req, nil
+	} else if !errors.Is(err, ErrNoErasureRequest) {
+		return nil, err
+	}
+	req := &models.ErasureRequest{
+		UserID:       userID,
+		Status:       models.ErasurePending,
+		ScheduledFor: time.Now().UTC().Add(grace),
+	}
+	if err := r.db.Create(req).Error; err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// PendingErasure — ожидающий запрос пользователя.
+func (r *PrivacyRepo) PendingErasure(userID int64) (*models.ErasureRequest, error) {
+	var req models.ErasureRequest
+	err := r.db.Where("user_id = ? AND status = ?", userID, models.ErasurePending).First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoErasureRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// CancelErasure отменяет ожидающий запрос.
+func (r *PrivacyRepo) CancelErasure(userID int64) error {
+	res := r.db.Model(&models.ErasureRequest{}).
+		Where("user_id = ? AND status = ?", userID, models.ErasurePending).
+		Updates(map[string]interface{}{"status": models.ErasureCancelled, "cancelled_at": time.Now().UTC()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNoErasureRequest
+	}
+	return nil
+}
+
+// DueErasures — запросы, срок ожидания которых истёк.
+func (r *PrivacyRepo) DueErasures(now time.Time) ([]models.ErasureRequest, error) {
+	var list []models.ErasureRequest
+	err := r.db.Where("status = ? AND scheduled_for <= ?", models.ErasurePending, now.UTC()).
+		Order("scheduled_for").Find(&list).Error
+	return list, err
+}
+
+// ErasureRequests — запросы для администратора; пустой status — все.
+func (r *PrivacyRepo) ErasureRequests(status string) ([]models.ErasureRequest, error) {
+	q := r.db.Model(&models.ErasureRequest{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var list []models.ErasureRequest
+	err := q.Order("created_at DESC, request_id DESC").Find(&list).Error
+	return list, err
+}
+
+// EraseTraces убирает персональные данные, которые остаются после
+// обезличивания учётной записи: контактное лицо на площадке в бронях
+// пользователя, IP и браузер его сеансов, email, IP и браузер в журнале
+// безопасности. Сами брони, их суммы и статусы сохраняются для отчётности.
+func (r *PrivacyRepo) EraseTraces(userID int64, email *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Booking{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"site_contact_name":  nil,
+			"site_contact_phone": nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserSession{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"ip":           "",
+			"last_seen_ip": "",
+			"user_agent":   "",
+		}).Error; err != nil {
+			return err
+		}
+		q := tx.Model(&models.SecurityEvent{}).Where("user_id = ?", userID)
+		if email != nil {
+			q = tx.Model(&models.SecurityEvent{}).Where("user_id = ? OR email = ?", userID, NormalizeLoginEmail(*email))
+		}
+		return q.Updates(map[string]interface{}{"email": nil, "ip": "", "user_agent": nil}).Error
+	})
+}
+
+// CompleteErasure отмечает запрос исполненным.
+func (r *PrivacyRepo) CompleteErasure(requestID int64) error {
+	return r.db.Model(&models.ErasureRequest{}).Where("request_id = ?", requestID).
+		Updates(map[string]interface{}{
+			"status":       models.ErasureCompleted,
+			"completed_at": time.Now().UTC(),
+			"last_error":   nil,
+		}).Error
+}
+
+// FailErasure сохраняет причину неудачи; запрос остаётся в очереди.
+func (r *PrivacyRepo) FailErasure(requestID int64, reason string) error {
+	return r.db.Model(&models.ErasureRequest{}).Where("request_id = ?", requestID).
+		Update("last_error", reason).Error
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+type QuoteRepo struct {
+	db *gorm.DB
+}
+
+func NewQuoteRepo(db *gorm.DB) *QuoteRepo {
+	return &QuoteRepo{db: db}
+}
+
+// QuoteStateError — предложение уже не ожидает решения заказчика.
+type QuoteStateError struct {
+	QuoteID int64
+	Status  string
+}
+
+func (e *QuoteStateError) Error() string {
+	return fmt.Sprintf("quote %d is %s", e.QuoteID, e.Status)
+}
+
+// Create сохраняет новую версию предложения компании по брони. Предыдущая
+// ожидающая версия той же компании помечается как superseded.
+func (r *QuoteRepo) Create(q *models.BookingQuote) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.BookingQuote{}).
+			Where("booking_id = ? AND company_id = ? AND status = ?", q.BookingID, q.CompanyID, models.QuoteStatusPending).
+			Update("status", models.QuoteStatusSuperseded).Error; err != nil {
+			return err
+		}
+
+		var last int
+		if err := tx.Model(&models.BookingQuote{}).
+			Where("booking_id = ? AND company_id = ?", q.BookingID, q.CompanyID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+		q.Version = last + 1
+		q.Status = models.QuoteStatusPending
+
+		return tx.Create(q).Error
+	})
+}
+
+// GetByBooking возвращает все версии предложений по брони. Если companyIDs
+// не nil, только предложения этих компаний.
+func (r *QuoteRepo) GetByBooking(bookingID int64, companyIDs []int64) ([]models.BookingQuote, error) {
+	if err := r.expireStale(bookingID); err != nil {
+		return nil, err
+	}
+
+	q := r.db.Where("booking_id = ?", bookingID)
+	if companyIDs != nil {
+		q = q.Where("company_id IN ?", companyIDs)
+	}
+	var quotes []models.BookingQuote
+	err := q.
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("quote_line_id") }).
+		Preload("Company").
+		Order("company_id, version").
+		Find(&quotes).Error
+	return quotes, err
+}
+
+func (r *QuoteRepo) GetByID(bookingID, quoteID int64) (*models.BookingQuote, error) {
+	var q models.BookingQuote
+	err := r.db.
+		Preload("Lines").
+		Preload("Company").
+		Where("booking_id = ?", bookingID).
+		First(&q, quoteID).Error
+	return &q, err
+}
+
+// Accept принимает предложение: цены из него фиксируются в строках брони,
+// строки переходят в approved от имени заказчика, итоги брони пересчитываются.
+func (r *QuoteRepo) Accept(bookingID, quoteID, customerUserID int64, reason *string) (*models.BookingQuote, error) {
+	if err := r.expireStale(bookingID); err != nil {
+		return nil, err
+	}
+
+	var q models.BookingQuote
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := pendingQuote(tx, bookingID, quoteID, &q); err != nil {
+			return err
+		}
+
+		lines := make(map[int64]bool, len(q.Lines))
+		for _, ql := range q.Lines {
+			lines[ql.BookingServiceID] = true
+			if err := tx.Model(&models.BookingService{}).
+				Where("booking_service_id = ?", ql.BookingServiceID).
+				Updates(map[string]interface{}{
+					"unit_price": ql.UnitPrice,
+					"currency":   q.Currency,
+					"quote_id":   q.QuoteID,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		var b models.Booking
+		if err := tx.Preload("BookingServices").First(&b, bookingID).Error; err != nil {
+			return err
+		}
+		if _, err := applyBookingStatus(tx, &b, lines, models.BookingStatusApproved, models.BookingActorCustomer, customerUserID, reason); err != nil {
+			return err
+		}
+		if err := saveTotals(tx, &b); err != nil {
+			return err
+		}
+
+		return decideQuote(tx, &q, models.QuoteStatusAccepted, reason)
+	})
+	return &q, err
+}
+
+// Reject отклоняет предложение; строки брони остаются в прежнем статусе,
+// и компания может прислать новую версию.
+func (r *QuoteRepo) Reject(bookingID, quoteID int64, reason *string) (*models.BookingQuote, error) {
+	if err := r.expireStale(bookingID); err != nil {
+		return nil, err
+	}
+
+	var q models.BookingQuote
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := pendingQuote(tx, bookingID, quoteID, &q); err != nil {
+			return err
+		}
+		return decideQuote(tx, &q, models.QuoteStatusRejected, reason)
+	})
+	return &q, err
+}
+
+// expireStale помечает просроченные ожидающие предложения по брони как expired.
+func (r *QuoteRepo) expireStale(bookingID int64) error {
+	return r.db.Model(&models.BookingQuote{}).
+		Where("booking_id = ? AND status = ? AND valid_until <= ?", bookingID, models.QuoteStatusPending, time.Now().UTC()).
+		Update("status", models.QuoteStatusExpired).Error
+}
+
+// pendingQuote загружает предложение и проверяет, что по нему ещё можно принять решение.
+func pendingQuote(tx *gorm.DB, bookingID, quoteID int64, q *models.BookingQuote) error {
+	if err := tx.Preload("Lines").Preload("Company").
+		Where("booking_id = ?", bookingID).
+		First(q, quoteID).Error; err != nil {
+		return err
+	}
+	if q.Status != models.QuoteStatusPending {
+		return &QuoteStateError{QuoteID: q.QuoteID, Status: q.Status}
+	}
+	return nil
+}
+
+func decideQuote(tx *gorm.DB, q *models.BookingQuote, status string, reason *string) error {
+	now := time.Now().UTC()
+	res := tx.Model(&models.BookingQuote{}).
+		Where("quote_id = ? AND status = ?", q.QuoteID, models.QuoteStatusPending).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"decided_at":      now,
+			"decision_reason": reason,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &QuoteStateError{QuoteID: q.QuoteID, Status: q.Status}
+	}
+	q.Status, q.DecidedAt, q.DecisionReason = status, &now, reason
+	return nil
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+// LoginGuard — пороги защиты входа. Первые FreeAttempts неудач проходят без
+// паузы, дальше каждая неудача удваивает паузу от BaseDelay до MaxDelay.
+// После AccountLockAfter (IPLockAfter) неудач подряд учётная запись (IP)
+// блокируется на LockDuration. Счётчик обнуляется, если неудач не было
+// дольше Window.
+type LoginGuard struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	AccountLockAfter int
+	IPLockAfter      int
+	LockDuration     time.Duration
+	Window           time.Duration
+}
+
+var DefaultLoginGuard = LoginGuard{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         5 * time.Minute,
+	AccountLockAfter: 10,
+	IPLockAfter:      50,
+	LockDuration:     15 * time.Minute,
+	Window:           time.Hour,
+}
+
+// LoginBlock — причина, по которой попытка входа сейчас не принимается.
+type LoginBlock struct {
+	Scope  string
+	Until  time.Time
+	Locked bool
+}
+
+// LockedAccountView — заблокированная учётная запись для администратора.
+// UserID и Name пусты, если пользователя с таким email нет.
+type LockedAccountView struct {
+	models.LoginAttemptCounter
+	UserID *int64  `json:"user_id"`
+	Name   *string `json:"name"`
+}
+
+// SecurityEventFilter — отбор событий журнала; пустые поля не учитываются.
+type SecurityEventFilter struct {
+	Type   string
+	UserID *int64
+	Email  string
+	IP     string
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+}
+
+const (
+	defaultSecurityEvents = 100
+	maxSecurityEvents     = 1000
+)
+
+type SecurityRepo struct {
+	db    *gorm.DB
+	guard LoginGuard
+}
+
+func NewSecurityRepo(db *gorm.DB, guard LoginGuard) *SecurityRepo {
+	return &SecurityRepo{db: db, guard: guard}
+}
+
+// NormalizeLoginEmail — ключ счётчика учётной записи. Он не зависит от того,
+// существует ли пользователь, поэтому по блокировке нельзя узнать, есть ли email.
+func NormalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CheckLogin возвращает блокировку, действующую для IP или email, либо nil.
+// Из двух блокировок возвращается более долгая.
+func (r *SecurityRepo) CheckLogin(ip, email string) (*LoginBlock, error) {
+	var counters []models.LoginAttemptCounter
+	err := r.db.Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+		models.LoginScopeIP, ip, models.LoginScopeAccount, NormalizeLoginEmail(email)).
+		Find(&counters).Error
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var block *LoginBlock
+	for _, c := range counters {
+		until, locked := c.BlockedUntil, false
+		if c.LockedUntil != nil && (until == nil || c.LockedUntil.After(*until)) {
+			until, locked = c.LockedUntil, true
+		}
+		if until == nil || !until.After(now) {
+			continue
+		}
+		if block == nil || until.After(block.Until) {
+			block = &LoginBlock{Scope: c.Scope, Until: *until, Locked: locked}
+		}
+	}
+	return block, nil
+}
+
+// RecordLoginFailure учитывает неудачный вход. Возвращает true, если именно
+// эта попытка заблокировала учётную запись.
+func (r *SecurityRepo) RecordLoginFailure(ip, email string) (bool, error) {
+	var accountLocked bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := r.recordFailure(tx, models.LoginScopeIP, ip, r.guard.IPLockAfter); err != nil {
+			return err
+		}
+		var err error
+		accountLocked, err = r.recordFailure(tx, models.LoginScopeAccount, NormalizeLoginEmail(email), r.guard.AccountLockAfter)
+		return err
+	})
+	return accountLocked, err
+}
+
+func (r *SecurityRepo) recordFailure(tx *gorm.DB, scope, key string, lockAfter int) (bool, error) {
+	now := time.Now().UTC()
+	var c models.LoginAttemptCounter
+	err := tx.Where("scope = ? AND key = ?", scope, key).First(&c).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c = models.LoginAttemptCounter{Scope: scope, Key: key}
+	case err != nil:
+		return false, err
+	}
+
+	locked := c.LockedUntil != nil && c.LockedUntil.After(now)
+	if !locked && now.Sub(c.LastFailureAt) > r.guard.Window {
+		c.Failures = 0
+		c.LockedUntil = nil
+	}
+	c.Failures++
+	c.LastFailureAt = now
+	c.BlockedUntil = nil
+	if over := c.Failures - r.guard.FreeAttempts; over > 0 {
+		delay := r.guard.MaxDelay
+		if over <= 30 {
+			if d := r.guard.BaseDelay << (over - 1); d < delay {
+				delay = d
+			}
+		}
+		until := now.Add(delay)
+		c.BlockedUntil = &until
+	}
+
+	lockedNow := false
+	if lockAfter > 0 && c.Failures >= lockAfter && !locked {
+		until := now.Add(r.guard.LockDuration)
+		c.LockedUntil = &until
+		lockedNow = true
+	}
+	return lockedNow, tx.Save(&c).Error
+}
+
+// RecordLoginSuccess сбрасывает счётчик учётной записи. Счётчик IP остаётся:
+// удачный вход в свою учётную запись не должен открывать перебор чужих.
+func (r *SecurityRepo) RecordLoginSuccess(email string) error {
+	return r.ClearAccount(email)
+}
+
+// ClearAccount снимает блокировку и обнуляет неудачи учётной записи.
+func (r *SecurityRepo) ClearAccount(email string) error {
+	return r.db.Where("scope = ? AND key = ?", models.LoginScopeAccount, NormalizeLoginEmail(email)).
+		Delete(&models.LoginAttemptCounter{}).Error
+}
+
+// LockedAccounts — учётные записи, заблокированные на текущий момент.
+func (r *SecurityRepo) LockedAccounts() ([]LockedAccountView, error) {
+	var list []LockedAccountView
+	err := r.db.Table("login_attempt_counter AS c").
+		Select("c.*, u.user_id, u.name").
+		Joins(`LEFT JOIN "user" u ON LOWER(u.email) = c.key`).
+		Where("c.scope = ? AND c.locked_until > ?", models.LoginScopeAccount, time.Now().UTC()).
+		Order("c.locked_until DESC").
+		Scan(&list).Error
+	return list, err
+}
+
+// Unlock снимает блокировку учётной записи по идентификатору счётчика.
+func (r *SecurityRepo) Unlock(counterID int64) (*models.LoginAttemptCounter, error) {
+	var c models.LoginAttemptCounter
+	if err := r.db.Where("counter_id = ? AND scope = ?", counterID, models.LoginScopeAccount).First(&c).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Delete(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UserByEmail ищет пользователя по email без учёта регистра.
+func (r *SecurityRepo) UserByEmail(email string, user *models.User) error {
+	return r.db.Where("LOWER(email) = ?", NormalizeLoginEmail(email)).First(user).Error
+}
+
+// LogEvent записывает событие в журнал безопасности.
+func (r *SecurityRepo) LogEvent(ev *models.SecurityEvent) error {
+	return r.db.Create(ev).Error
+}
+
+// Events — события журнала по фильтру, новые первыми.
+func (r *SecurityRepo) Events(f SecurityEventFilter) ([]models.SecurityEvent, error) {
+	q := r.db.Model(&models.SecurityEvent{})
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.UserID != nil {
+		q = q.Where("user_id = ?", *f.UserID)
+	}
+	if f.Email != "" {
+		q = q.Where("email = ?", NormalizeLoginEmail(f.Email))
+	}
+	if f.IP != "" {
+		q = q.Where("ip = ?", f.IP)
+	}
+	if f.Since != nil {
+		q = q.Where("created_at >= ?", f.Since.UTC())
+	}
+	if f.Until != nil {
+		q = q.Where("created_at < ?", f.Until.UTC())
+	}
+	switch {
+	case f.Limit <= 0:
+		f.Limit = defaultSecurityEvents
+	case f.Limit > maxSecurityEvents:
+		f.Limit = maxSecurityEvents
+	}
+
+	var list []models.SecurityEvent
+	err := q.Order("created_at DESC, event_id DESC").Limit(f.Limit).Find(&list).Error
+	return list, err
+}
@@ -37,10 +37,13 @@ func (r *ServiceRepo) Delete(id int64) error {
 	return r.db.Delete(&models.Service{}, id).Error
 }
 
+// GetAvailable — услуги, которые оказывает хотя бы одна проверенная компания.
 func (r *ServiceRepo) GetAvailable() ([]models.Service, error) {
 	var services []models.Service
 	err := r.db.
-		Where("service_id IN (SELECT DISTINCT service_id FROM company_service)").
+		Where(`service_id IN (SELECT DISTINCT company_service.service_id FROM company_service
+			JOIN company ON company.company_id = company_service.company_id
+			WHERE company.verification_status = ?)`, models.CompanyVerificationOK).
 		Find(&services).Error
 	return services, err
 }
@@ -0,0 +1,481 @@
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"oil-gas-service-booking/internal/models"
+)
+
+var (
+	// ErrRefreshTokenInvalid — токен не найден, истёк или его владелец удалён.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused — предъявлен уже использованный токен; вся цепочка отозвана.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrUserTokenInvalid — ссылка из письма не найдена, истекла или уже использована.
+	ErrUserTokenInvalid = errors.New("link is invalid, expired or already used")
+	// ErrEmailTaken — адрес уже занят другим пользователем.
+	ErrEmailTaken = errors.New("email is already in use")
+)
+
+// sessionTouchInterval — как apiKeyTouchInterval, но для last_seen_at сеанса.
+const sessionTouchInterval = time.Minute
+
+type TokenRepo struct {
+	db         *gorm.DB
+	refreshTTL time.Duration
+}
+
+func NewTokenRepo(db *gorm.DB, refreshTTL time.Duration) *TokenRepo {
+	return &TokenRepo{db: db, refreshTTL: refreshTTL}
+}
+
+// IsAccessTokenRevoked реализует middleware.TokenStore.
+func (r *TokenRepo) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedAccessToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// CurrentRole реализует middleware.TokenStore.
+func (r *TokenRepo) CurrentRole(userID int64) (string, bool, bool, error) {
+	var users []models.User
+	err := r.db.Select("role", "suspended_at").
+		Where("user_id = ? AND deactivated_at IS NULL", userID).
+		Limit(1).Find(&users).Error
+	if err != nil {
+		return "", false, false, err
+	}
+	if len(users) == 0 {
+		return "", false, false, nil
+	}
+	return users[0].Role, users[0].SuspendedAt != nil, true, nil
+}
+
+// StartSession начинает сеанс (новый вход) и выпускает первый refresh-токен
+// его цепочки. Возвращается сам токен — на сервере остаётся только его хеш.
+func (r *TokenRepo) StartSession(userID int64, ip, userAgent string) (string, int64, error) {
+	var raw string
+	var session models.UserSession
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		familyID, err := randomToken(16)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		session = models.UserSession{
+			UserID:     userID,
+			FamilyID:   familyID,
+			UserAgent:  userAgent,
+			IP:         ip,
+			LastSeenAt: now,
+			LastSeenIP: ip,
+			ExpiresAt:  now.Add(r.refreshTTL),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		raw, err = r.issueRefresh(tx, userID, familyID, nil)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return raw, session.SessionID, nil
+}
+
+func (r *TokenRepo) issueRefresh(tx *gorm.DB, userID int64, familyID string, created *models.RefreshToken) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	rt := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(r.refreshTTL),
+	}
+	if err := tx.Create(&rt).Error; err != nil {
+		return "", err
+	}
+	if created != nil {
+		*created = rt
+	}
+	return raw, nil
+}
+
+// Rotate обменивает refresh-токен на новый из той же цепочки и возвращает
+// владельца с актуальной ролью и сеанс цепочки. Приостановленной или удалённой
+// учётной записи новый токен не выдаётся. Повторное использование отозванного
+// токена отзывает всю цепочку — украденный токен перестаёт работать у обеих
+// сторон.
+func (r *TokenRepo) Rotate(raw, ip, userAgent string) (string, *models.User, int64, error) {
+	var old models.RefreshToken
+	if err := r.db.Where("token_hash = ?", hashToken(raw)).First(&old).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, 0, ErrRefreshTokenInvalid
+		}
+		return "", nil, 0, err
+	}
+	if old.RevokedAt != nil {
+		if err := r.revokeFamily(old.FamilyID); err != nil {
+			return "", nil, 0, err
+		}
+		return "", nil, 0, ErrRefreshTokenReused
+	}
+	if !old.ExpiresAt.After(time.Now().UTC()) {
+		return "", nil, 0, ErrRefreshTokenInvalid
+	}
+
+	var user models.User
+	err := r.db.Where("suspended_at IS NULL AND deactivated_at IS NULL").First(&user, old.UserID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := r.revokeFamily(old.FamilyID); err != nil {
+				return "", nil, 0, err
+			}
+			return "", nil, 0, ErrRefreshTokenInvalid
+		}
+		return "", nil, 0, err
+	}
+
+	var next string
+	var sessionID int64
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		session, err := familySession(tx, &old, ip, userAgent)
+		if err != nil {
+			return err
+		}
+		sessionID = session.SessionID
+		var created models.RefreshToken
+		if next, err = r.issueRefresh(tx, old.UserID, old.FamilyID, &created); err != nil {
+			return err
+		}
+		res := tx.Model(&models.RefreshToken{}).
+			Where("token_id = ? AND revoked_at IS NULL", old.TokenID).
+			Updates(map[string]interface{}{
+				"revoked_at":     time.Now().UTC(),
+				"replaced_by_id": created.TokenID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		return tx.Model(&models.UserSession{}).Where("session_id = ?", session.SessionID).
+			Updates(map[string]interface{}{
+				"last_seen_at": time.Now().UTC(),
+				"last_seen_ip": ip,
+				"expires_at":   created.ExpiresAt,
+			}).Error
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := r.revokeFamily(old.FamilyID); err != nil {
+			return "", nil, 0, err
+		}
+		return "", nil, 0, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return next, &user, sessionID, nil
+}
+
+// familySession — сеанс цепочки refresh-токенов. Для цепочек, начатых до
+// появления сеансов, он создаётся при первой ротации.
+func familySession(tx *gorm.DB, rt *models.RefreshToken, ip, userAgent string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := tx.Where("family_id = ?", rt.FamilyID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		now := time.Now().UTC()
+		session = models.UserSession{
+			UserID:     rt.UserID,
+			FamilyID:   rt.FamilyID,
+			UserAgent:  userAgent,
+			IP:         ip,
+			LastSeenAt: now,
+			LastSeenIP: ip,
+			ExpiresAt:  rt.ExpiresAt,
+		}
+		return &session, tx.Create(&session).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return &session, nil
+}
+
+// RevokeRefresh отзывает цепочку, к которой относится токен пользователя.
+// Чужой или неизвестный токен молча игнорируется.
+func (r *TokenRepo) RevokeRefresh(raw string, userID int64) error {
+	var rt models.RefreshToken
+	err := r.db.Where("token_hash = ? AND user_id = ?", hashToken(raw), userID).First(&rt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.revokeFamily(rt.FamilyID)
+}
+
+// RevokeAccess заносит access-токен в список отозванных до истечения его срока.
+// Заодно удаляются записи, срок которых уже вышел.
+func (r *TokenRepo) RevokeAccess(jti string, userID int64, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	if err := r.db.Where("expires_at <= ?", now).Delete(&models.RevokedAccessToken{}).Error; err != nil {
+		return err
+	}
+	if !expiresAt.After(now) {
+		return nil
+	}
+	return r.db.Where(models.RevokedAccessToken{JTI: jti}).
+		FirstOrCreate(&models.RevokedAccessToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt.UTC()}).Error
+}
+
+// RevokeAllForUser завершает все сеансы пользователя.
+func (r *TokenRepo) RevokeAllForUser(userID int64) error {
+	return revokeSessions(r.db, userID)
+}
+
+// TouchSession реализует middleware.TokenStore: сеанс действует, пока не
+// отозван. last_seen обновляется не чаще sessionTouchInterval или при смене IP.
+func (r *TokenRepo) TouchSession(sessionID int64, ip string) (bool, error) {
+	var sessions []models.UserSession
+	err := r.db.Select("session_id", "revoked_at", "last_seen_at", "last_seen_ip").
+		Where("session_id = ?", sessionID).Limit(1).Find(&sessions).Error
+	if err != nil {
+		return false, err
+	}
+	if len(sessions) == 0 || sessions[0].RevokedAt != nil {
+		return false, nil
+	}
+	s := sessions[0]
+	now := time.Now().UTC()
+	if now.Sub(s.LastSeenAt) >= sessionTouchInterval || s.LastSeenIP != ip {
+		err := r.db.Model(&models.UserSession{}).Where("session_id = ?", sessionID).
+			Updates(map[string]interface{}{"last_seen_at": now, "last_seen_ip": ip}).Error
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Sessions — сеансы пользователя, недавно активные первыми; activeOnly
+// оставляет только не отозванные и не истёкшие.
+func (r *TokenRepo) Sessions(userID int64, activeOnly bool) ([]models.UserSession, error) {
+	q := r.db.Where("user_id = ?", userID)
+	if activeOnly {
+		q = q.Where("revoked_at IS NULL AND expires_at > ?", time.Now().UTC())
+	}
+	var list []models.UserSession
+	err := q.Order("last_seen_at DESC, session_id DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *TokenRepo) GetSession(sessionID int64) (*models.UserSession, error) {
+	var s models.UserSession
+	if err := r.db.First(&s, "session_id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// RevokeSession завершает сеанс. Возвращает false, если он уже был отозван.
+func (r *TokenRepo) RevokeSession(session *models.UserSession) (bool, error) {
+	if session.RevokedAt != nil {
+		return false, nil
+	}
+	return true, r.revokeFamily(session.FamilyID)
+}
+
+// RevokeOtherSessions завершает все сеансы пользователя, кроме keepSessionID
+// (0 — все), и возвращает число завершённых.
+func (r *TokenRepo) RevokeOtherSessions(userID, keepSessionID int64) (int64, error) {
+	var revoked int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		keep := tx.Model(&models.UserSession{}).Select("family_id").Where("session_id = ?", keepSessionID)
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL AND family_id NOT IN (?)", userID, keep).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ? AND session_id <> ?", userID, now, keepSessionID).
+			Update("revoked_at", now)
+		revoked = res.RowsAffected
+		if res.Error != nil {
+			return res.Error
+		}
+		// Истёкшие сеансы тоже закрываем, чтобы их записи не выглядели живыми.
+		return tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND revoked_at IS NULL AND session_id <> ?", userID, keepSessionID).
+			Update("revoked_at", now).Error
+	})
+	return revoked, err
+}
+
+// IssueUserToken выпускает одноразовый токен для письма. Прежние
+// неиспользованные токены того же назначения перестают действовать.
+func (r *TokenRepo) IssueUserToken(userID int64, purpose, email string, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			Email:     email,
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ResetPassword меняет пароль по токену сброса и завершает все сессии
+// пользователя. Токен действует только для адреса, на который был отправлен.
+func (r *TokenRepo) ResetPassword(raw, passwordHash string) (int64, error) {
+	var userID int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		t, err := consumeUserToken(tx, raw, models.UserTokenPasswordReset)
+		if err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, t.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserTokenInvalid
+			}
+			return err
+		}
+		if user.Email == nil || !strings.EqualFold(*user.Email, t.Email) {
+			return ErrUserTokenInvalid
+		}
+		userID = user.UserID
+		return setPassword(tx, user.UserID, passwordHash)
+	})
+	return userID, err
+}
+
+// SetPassword сохраняет новый хеш пароля и отзывает все refresh-токены.
+func (r *TokenRepo) SetPassword(userID int64, passwordHash string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, userID, passwordHash)
+	})
+}
+
+func setPassword(tx *gorm.DB, userID int64, passwordHash string) error {
+	if err := tx.Model(&models.User{}).Where("user_id = ?", userID).
+		Update("password", passwordHash).Error; err != nil {
+		return err
+	}
+	return revokeSessions(tx, userID)
+}
+
+// VerifyEmail подтверждает адрес из токена и делает его email пользователя.
+func (r *TokenRepo) VerifyEmail(raw string) (*models.User, error) {
+	var user models.User
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		t, err := consumeUserToken(tx, raw, models.UserTokenEmailVerify)
+		if err != nil {
+			return err
+		}
+		var taken int64
+		if err := tx.Model(&models.User{}).
+			Where("LOWER(email) = LOWER(?) AND user_id <> ?", t.Email, t.UserID).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailTaken
+		}
+		if err := tx.Model(&models.User{}).Where("user_id = ?", t.UserID).Updates(map[string]interface{}{
+			"email":             t.Email,
+			"email_verified_at": time.Now().UTC(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.First(&user, t.UserID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// consumeUserToken помечает токен использованным. Условное обновление не даёт
+// использовать один токен дважды при параллельных запросах.
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	var t models.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserTokenInvalid
+		}
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if t.UsedAt != nil || !t.ExpiresAt.After(now) {
+		return nil, ErrUserTokenInvalid
+	}
+	res := tx.Model(&models.UserToken{}).
+		Where("token_id = ? AND used_at IS NULL", t.TokenID).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrUserTokenInvalid
+	}
+	return &t, nil
+}
+
+// revokeFamily отзывает цепочку refresh-токенов и её сеанс.
+func (r *TokenRepo) revokeFamily(familyID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserSession{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
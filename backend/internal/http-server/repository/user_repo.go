@@ -1,10 +1,31 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"gorm.io/gorm"
 	"oil-gas-service-booking/internal/models"
 )
 
+var (
+	// ErrLastAdmin — в системе должен оставаться хотя бы один действующий
+	// администратор.
+	ErrLastAdmin = errors.New("the last active administrator cannot be demoted, suspended or deactivated")
+	// ErrOwnsCompanies — пользователь — основной владелец компаний; перед
+	// удалением их нужно передать другому владельцу.
+	ErrOwnsCompanies = errors.New("user is the primary owner of companies, transfer ownership first")
+	// ErrSoleOrgAdmin — пользователь — единственный администратор организации
+	// заказчика; перед удалением нужно назначить другого.
+	ErrSoleOrgAdmin = errors.New("user is the only admin of a customer organization, appoint another admin first")
+	// ErrUserDeactivated — учётная запись уже удалена.
+	ErrUserDeactivated = errors.New("user is deactivated")
+)
+
+// DeactivatedUserName — имя, которое остаётся у удалённого пользователя в
+// истории броней и заявок.
+const DeactivatedUserName = "Удалённый пользователь"
+
 type UserRepo struct {
 	db *gorm.DB
 }
@@ -33,6 +54,195 @@ func (r *UserRepo) Update(u *models.User) error {
 	return r.db.Save(u).Error
 }
 
-func (r *UserRepo) Delete(id int64) error {
-	return r.db.Delete(&models.User{}, id).Error
+// ChangeRole меняет глобальную роль. Действующие токены с прежней ролью
+// middleware после этого отвергает.
+func (r *UserRepo) ChangeRole(id int64, role string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		u, err := activeUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if u.Role == models.RoleAdmin && role != models.RoleAdmin {
+			if err := checkNotLastAdmin(tx, id); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.User{}).Where("user_id = ?", id).Update("role", role).Error
+	})
+}
+
+// Suspend приостанавливает учётную запись и отзывает её refresh-токены.
+func (r *UserRepo) Suspend(id int64, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		u, err := activeUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if u.Role == models.RoleAdmin && u.SuspendedAt == nil {
+			if err := checkNotLastAdmin(tx, id); err != nil {
+				return err
+			}
+		}
+		var why *string
+		if reason != "" {
+			why = &reason
+		}
+		if err := tx.Model(&models.User{}).Where("user_id = ?", id).Updates(map[string]interface{}{
+			"suspended_at":      time.Now().UTC(),
+			"suspension_reason": why,
+		}).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, id)
+	})
+}
+
+// Reactivate снимает приостановку.
+func (r *UserRepo) Reactivate(id int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := activeUser(tx, id); err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("user_id = ?", id).Updates(map[string]interface{}{
+			"suspended_at":      nil,
+			"suspension_reason": nil,
+		}).Error
+	})
+}
+
+// PrimaryOwnedCompanies — компании, где пользователь основной владелец.
+func (r *UserRepo) PrimaryOwnedCompanies(id int64) ([]models.Company, error) {
+	var list []models.Company
+	err := r.db.Where("user_id = ?", id).Order("company_id").Find(&list).Error
+	return list, err
+}
+
+// Deactivate удаляет учётную запись без удаления строки: имя, email, пароль
+// и аватар обезличиваются, входы, второй фактор, ключи, членства и личные
+// уведомления удаляются. Брони, заявки и журналы остаются и ссылаются на
+// обезличенного пользователя. Возвращает пользователя до обезличивания.
+func (r *UserRepo) Deactivate(id int64) (*models.User, error) {
+	var before models.User
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		u, err := activeUser(tx, id)
+		if err != nil {
+			return err
+		}
+		before = *u
+		if u.Role == models.RoleAdmin && u.SuspendedAt == nil {
+			if err := checkNotLastAdmin(tx, id); err != nil {
+				return err
+			}
+		}
+		var owned int64
+		if err := tx.Model(&models.Company{}).Where("user_id = ?", id).Count(&owned).Error; err != nil {
+			return err
+		}
+		if owned > 0 {
+			return ErrOwnsCompanies
+		}
+		if err := checkNotSoleOrgAdmin(tx, id); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if err := tx.Model(&models.User{}).Where("user_id = ?", id).Updates(map[string]interface{}{
+			"name":              DeactivatedUserName,
+			"email":             nil,
+			"password":          "!",
+			"role":              models.RoleCustomer,
+			"avatar_url":        nil,
+			"email_verified_at": nil,
+			"suspended_at":      nil,
+			"suspension_reason": nil,
+			"deactivated_at":    now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		for _, m := range []interface{}{
+			&models.UserToken{}, &models.UserTOTP{}, &models.RecoveryCode{}, &models.MFAChallenge{},
+			&models.CompanyMember{}, &models.CustomerOrgMember{}, &models.Notification{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if before.Email != nil {
+			return tx.Where("scope = ? AND key = ?", models.LoginScopeAccount, NormalizeLoginEmail(*before.Email)).
+				Delete(&models.LoginAttemptCounter{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &before, nil
+}
+
+func activeUser(tx *gorm.DB, id int64) (*models.User, error) {
+	var u models.User
+	if err := tx.First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	if u.DeactivatedAt != nil {
+		return nil, ErrUserDeactivated
+	}
+	return &u, nil
+}
+
+// CheckNotSoleOrgAdmin возвращает ErrSoleOrgAdmin, если без пользователя
+// его организация заказчика останется без администратора.
+func (r *UserRepo) CheckNotSoleOrgAdmin(id int64) error {
+	return checkNotSoleOrgAdmin(r.db, id)
+}
+
+func checkNotSoleOrgAdmin(tx *gorm.DB, userID int64) error {
+	var m models.CustomerOrgMember
+	err := tx.Where("user_id = ?", userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = keepOrgAdmin(tx, m.OrgID, userID)
+	if errors.Is(err, ErrLastOrgAdmin) {
+		return ErrSoleOrgAdmin
+	}
+	return err
+}
+
+func checkNotLastAdmin(tx *gorm.DB, exceptID int64) error {
+	var count int64
+	err := tx.Model(&models.User{}).
+		Where("role = ? AND user_id <> ? AND suspended_at IS NULL AND deactivated_at IS NULL", models.RoleAdmin, exceptID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// revokeSessions завершает все сеансы пользователя: отзывает refresh-токены,
+// а access-токены с их sid перестаёт принимать middleware.
+func revokeSessions(tx *gorm.DB, userID int64) error {
+	now := time.Now().UTC()
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
 }
@@ -9,6 +9,7 @@ import (
 
 	"oil-gas-service-booking/internal/http-server/handlers"
 	authmw "oil-gas-service-booking/internal/http-server/middleware"
+	"oil-gas-service-booking/internal/models"
 )
 
 func NewRouter(
@@ -24,6 +25,15 @@ func NewRouter(
 	uploadsDir string,
 	serviceRequestHandler *handlers.ServiceRequestHandler,
 	notificationHandler *handlers.NotificationHandler,
+	bookingQuoteHandler *handlers.BookingQuoteHandler,
+	jwksHandler *handlers.JWKSHandler,
+	companyMemberHandler *handlers.CompanyMemberHandler,
+	customerOrgHandler *handlers.CustomerOrgHandler,
+	securityHandler *handlers.SecurityHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	privacyHandler *handlers.PrivacyHandler,
+	impersonationHandler *handlers.ImpersonationHandler,
+	sessionHandler *handlers.SessionHandler,
 ) *chi.Mux {
 
 	r := chi.NewRouter()
@@ -34,104 +44,204 @@ func NewRouter(
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173", "http://localhost:80", "http://localhost"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
+	r.Get("/.well-known/jwks.json", jwksHandler.Get)
+
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/register", authHandler.Register)
 		r.Post("/login", authHandler.Login)
+		r.Post("/login/2fa", authHandler.LoginMFA)
+		r.Post("/login/2fa/setup", authHandler.LoginMFASetup)
+		r.Post("/refresh", authHandler.Refresh)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/logout", authHandler.Logout)
+		r.Post("/password/forgot", authHandler.ForgotPassword)
+		r.Post("/password/reset", authHandler.ResetPassword)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/password/change", authHandler.ChangePassword)
+		r.Post("/email/verify", authHandler.VerifyEmail)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/email/verification", authHandler.ResendVerification)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Get("/2fa", authHandler.MFAStatus)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/2fa/setup", authHandler.MFASetup)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/2fa/confirm", authHandler.MFAConfirm)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/2fa/disable", authHandler.MFADisable)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/2fa/recovery-codes", authHandler.MFARecoveryCodes)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Get("/api-keys", apiKeyHandler.ListMine)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/api-keys", apiKeyHandler.CreateMine)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Delete("/api-keys/{id}", apiKeyHandler.RevokeMine)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Get("/sessions", sessionHandler.List)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Delete("/sessions", sessionHandler.RevokeAll)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Delete("/sessions/{id}", sessionHandler.Revoke)
 	})
 
-	r.With(authmw.BasicAuthMiddleware(false)).Get("/auth/me", authHandler.Me)
-	r.With(authmw.BasicAuthMiddleware(false)).Patch("/auth/me", authHandler.UpdateMe)
-	r.With(authmw.BasicAuthMiddleware(false)).Get("/auth/me/stats", authHandler.MyStats)
+	r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/auth/me", authHandler.Me)
+	r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Patch("/auth/me", authHandler.UpdateMe)
+	r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/auth/me/stats", authHandler.MyStats)
+	r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Get("/auth/me/export", privacyHandler.Export)
+	r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Get("/auth/me/erasure", privacyHandler.ErasureStatus)
+	r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/auth/me/erasure", privacyHandler.RequestErasure)
+	r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Delete("/auth/me/erasure", privacyHandler.CancelErasure)
 
 	r.Route("/companies", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/", companyHandler.Create)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/", companyHandler.GetAll)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/my", companyHandler.GetMy)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/{id}", companyHandler.GetByID)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/{id}", companyHandler.Update)
-		r.With(authmw.BasicAuthMiddleware(false)).Delete("/{id}", companyHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesCreate)).Post("/", companyHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/", companyHandler.GetAll)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/my", companyHandler.GetMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManageAll)).Get("/moderation", companyHandler.ModerationQueue)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/{id}", companyHandler.GetByID)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Put("/{id}", companyHandler.Update)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Delete("/{id}", companyHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Get("/{id}/routing", companyHandler.GetRouting)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Put("/{id}/routing", companyHandler.UpdateRouting)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Post("/{id}/verification", companyHandler.SubmitForReview)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManageAll)).Post("/{id}/review", companyHandler.Review)
+
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/{id}/members", companyMemberHandler.List)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Patch("/{id}/members/{userId}", companyMemberHandler.UpdateRole)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Delete("/{id}/members/{userId}", companyMemberHandler.Remove)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Post("/{id}/owner", companyMemberHandler.TransferOwnership)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Post("/{id}/invitations", companyMemberHandler.Invite)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Get("/{id}/invitations", companyMemberHandler.ListInvitations)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Delete("/{id}/invitations/{invitationId}", companyMemberHandler.RevokeInvitation)
+
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Get("/{id}/api-keys", apiKeyHandler.ListCompany)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Post("/{id}/api-keys", apiKeyHandler.CreateCompany)
+		r.With(authmw.SessionAuthMiddleware(models.PermCompaniesManage)).Delete("/{id}/api-keys/{keyId}", apiKeyHandler.RevokeCompany)
+	})
+
+	r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/invitations/accept", companyMemberHandler.Accept)
+
+	r.Route("/customer-orgs", func(r chi.Router) {
+		r.With(authmw.SessionAuthMiddleware(models.PermBookingsCreate)).Post("/", customerOrgHandler.Create)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Post("/invitations/accept", customerOrgHandler.AcceptInvitation)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/my", customerOrgHandler.GetMy)
+		r.With(authmw.SessionAuthMiddleware(models.PermBookingsCreate)).Put("/{id}", customerOrgHandler.Update)
+		r.With(authmw.SessionAuthMiddleware(models.PermBookingsCreate)).Post("/{id}/members", customerOrgHandler.Invite)
+		r.With(authmw.SessionAuthMiddleware(models.PermBookingsCreate)).Patch("/{id}/members/{userId}", customerOrgHandler.UpdateMember)
+		r.With(authmw.SessionAuthMiddleware(models.PermAccount)).Delete("/{id}/members/{userId}", customerOrgHandler.RemoveMember)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Get("/{id}/approvals", customerOrgHandler.PendingApprovals)
 	})
 
 	r.Route("/services", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/", serviceHandler.Create)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/", serviceHandler.GetAll)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/available", serviceHandler.GetAvailable)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/my", serviceHandler.GetMy)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/{id}", serviceHandler.GetByID)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/{id}", serviceHandler.Update)
-		r.With(authmw.BasicAuthMiddleware(false)).Delete("/{id}", serviceHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogWrite)).Post("/", serviceHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/", serviceHandler.GetAll)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/available", serviceHandler.GetAvailable)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/my", serviceHandler.GetMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/{id}", serviceHandler.GetByID)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogManage)).Put("/{id}", serviceHandler.Update)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogManage)).Delete("/{id}", serviceHandler.Delete)
 	})
 
 	r.Route("/users", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/", userHandler.GetAll)
-		r.With(authmw.BasicAuthMiddleware(true)).Delete("/{id}", userHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/", userHandler.GetAll)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Delete("/{id}", userHandler.Delete)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Patch("/{id}/role", userHandler.UpdateRole)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Post("/{id}/suspend", userHandler.Suspend)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Post("/{id}/reactivate", userHandler.Reactivate)
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersManage)).Delete("/{id}/2fa", authHandler.ResetUserMFA)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Post("/{id}/api-keys", apiKeyHandler.CreateForUser)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersImpersonate)).Post("/{id}/impersonate", impersonationHandler.Start)
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/{id}/sessions", sessionHandler.ListForUser)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Delete("/{id}/sessions", sessionHandler.RevokeAllForUser)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Delete("/{id}/sessions/{sessionId}", sessionHandler.RevokeForUser)
+	})
+
+	r.Route("/impersonations", func(r chi.Router) {
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/", impersonationHandler.List)
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/{id}/requests", impersonationHandler.Requests)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersImpersonate)).Delete("/{id}", impersonationHandler.End)
 	})
 
+	r.Route("/api-keys", func(r chi.Router) {
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/", apiKeyHandler.List)
+		r.With(authmw.SessionAuthMiddleware(models.PermUsersManage)).Delete("/{id}", apiKeyHandler.Revoke)
+	})
+
+	r.Route("/security", func(r chi.Router) {
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/locks", securityHandler.LockedAccounts)
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersManage)).Delete("/locks/{id}", securityHandler.Unlock)
+		r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/events", securityHandler.Events)
+	})
+
+	r.With(authmw.BasicAuthMiddleware(models.PermUsersRead)).Get("/privacy/erasure-requests", privacyHandler.ErasureRequests)
+
 	r.Route("/bookings", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/", bookingHandler.Create)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/me", bookingHandler.GetMyBookings)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/company", bookingHandler.GetMyCompanyBookings)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/{id}/cancel", bookingHandler.CancelMy)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/{id}/company-status", bookingHandler.UpdateMyCompanyBookingStatus)
-		r.With(authmw.BasicAuthMiddleware(false)).Delete("/{id}/me", bookingHandler.DeleteMy)
-
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/", bookingHandler.GetAll)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/{id}", bookingHandler.GetByID)
-		r.With(authmw.BasicAuthMiddleware(true)).Put("/{id}", bookingHandler.Update)
-		r.With(authmw.BasicAuthMiddleware(true)).Delete("/{id}", bookingHandler.Delete)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/{booking_id}/services", bookingServiceHandler.GetByBookingID)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Post("/", bookingHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Post("/checkout", bookingHandler.Checkout)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/me", bookingHandler.GetMyBookings)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/company", bookingHandler.GetMyCompanyBookings)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Put("/{id}/cancel", bookingHandler.CancelMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesOperate)).Put("/{id}/company-status", bookingHandler.UpdateMyCompanyBookingStatus)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Delete("/{id}/me", bookingHandler.DeleteMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsRead)).Get("/{id}/history", bookingHandler.GetHistory)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsRead)).Get("/{id}/quotes", bookingQuoteHandler.GetByBooking)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesOperate)).Post("/{id}/quotes", bookingQuoteHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Post("/{id}/quotes/{quoteId}/accept", bookingQuoteHandler.Accept)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Post("/{id}/quotes/{quoteId}/reject", bookingQuoteHandler.Reject)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsRead)).Get("/{id}/approvals", customerOrgHandler.GetBookingApprovals)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Post("/{id}/approval", customerOrgHandler.Decide)
+
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsReadAll)).Get("/", bookingHandler.GetAll)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsReadAll)).Get("/{id}", bookingHandler.GetByID)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsManageAll)).Put("/{id}", bookingHandler.Update)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsManageAll)).Delete("/{id}", bookingHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsReadAll)).Get("/{booking_id}/services", bookingServiceHandler.GetByBookingID)
 	})
 
 	r.Route("/booking-services", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/", bookingServiceHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermBookingsCreate)).Post("/", bookingServiceHandler.Create)
 	})
 
 	r.Route("/company-services", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/my", companyServiceHandler.GetMy)
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/", companyServiceHandler.Create)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/{id}", companyServiceHandler.Update)
-		r.With(authmw.BasicAuthMiddleware(false)).Delete("/{id}", companyServiceHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/my", companyServiceHandler.GetMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Post("/", companyServiceHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Put("/{id}", companyServiceHandler.Update)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Delete("/{id}", companyServiceHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/{id}/availability", companyServiceHandler.GetAvailability)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Get("/{id}/blackouts", companyServiceHandler.GetBlackouts)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Post("/{id}/blackouts", companyServiceHandler.CreateBlackout)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Delete("/{id}/blackouts/{blackoutId}", companyServiceHandler.DeleteBlackout)
 	})
 
 	r.Route("/upload", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/avatar", uploadHandler.UploadAvatar)
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/companies/{id}/logo", uploadHandler.UploadCompanyLogo)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Post("/avatar", uploadHandler.UploadAvatar)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesManage)).Post("/companies/{id}/logo", uploadHandler.UploadCompanyLogo)
 	})
 
 	r.Handle("/uploads/*", http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadsDir))))
 
 	r.Route("/service-requests", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/", serviceRequestHandler.Create)
-		r.With(authmw.BasicAuthMiddleware(false)).Post("/{id}/respond", serviceRequestHandler.Respond)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/", serviceRequestHandler.GetAll)
-		r.With(authmw.BasicAuthMiddleware(true)).Put("/{id}/status", serviceRequestHandler.UpdateStatus)
-		r.With(authmw.BasicAuthMiddleware(true)).Post("/{id}/notify-companies", serviceRequestHandler.NotifyCompanies)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsCreate)).Post("/", serviceRequestHandler.Create)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/my", serviceRequestHandler.GetMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermCompaniesOperate)).Post("/{id}/respond", serviceRequestHandler.Respond)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsRead)).Get("/{id}/bids", serviceRequestHandler.GetBids)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsCreate)).Post("/{id}/award", serviceRequestHandler.Award)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsReadAll)).Get("/", serviceRequestHandler.GetAll)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsManage)).Put("/{id}/status", serviceRequestHandler.UpdateStatus)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsReadAll)).Get("/{id}/matches", serviceRequestHandler.PreviewMatches)
+		r.With(authmw.BasicAuthMiddleware(models.PermRequestsManage)).Post("/{id}/notify-companies", serviceRequestHandler.NotifyCompanies)
 	})
 
 	r.Route("/notifications", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/", notificationHandler.GetMy)
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/unread-count", notificationHandler.UnreadCount)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/{id}/read", notificationHandler.MarkRead)
-		r.With(authmw.BasicAuthMiddleware(false)).Put("/read-all", notificationHandler.MarkAllRead)
-		r.With(authmw.BasicAuthMiddleware(false)).Delete("/{id}", notificationHandler.Delete)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/", notificationHandler.GetMy)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Get("/unread-count", notificationHandler.UnreadCount)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Put("/{id}/read", notificationHandler.MarkRead)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Put("/read-all", notificationHandler.MarkAllRead)
+		r.With(authmw.BasicAuthMiddleware(models.PermAccount)).Delete("/{id}", notificationHandler.Delete)
 	})
 
 	r.Route("/business", func(r chi.Router) {
-		r.With(authmw.BasicAuthMiddleware(false)).Get("/companies-by-service/{serviceId}", businessHandler.FindCompaniesByService)
-
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/users-with-active-bookings", businessHandler.FindUsersWithActiveBookings)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/company-stats", businessHandler.GetCompanyStats)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/popular-services", businessHandler.FindPopularServices)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/popular-companies", businessHandler.FindPopularCompanies)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/summary", businessHandler.GetSummary)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/bookings-by-date", businessHandler.GetBookingsByDate)
-		r.With(authmw.BasicAuthMiddleware(true)).Get("/search", businessHandler.SearchAll)
+		r.With(authmw.BasicAuthMiddleware(models.PermCatalogRead)).Get("/companies-by-service/{serviceId}", businessHandler.FindCompaniesByService)
+
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/users-with-active-bookings", businessHandler.FindUsersWithActiveBookings)
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/company-stats", businessHandler.GetCompanyStats)
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/popular-services", businessHandler.FindPopularServices)
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/popular-companies", businessHandler.FindPopularCompanies)
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/summary", businessHandler.GetSummary)
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/bookings-by-date", businessHandler.GetBookingsByDate)
+		r.With(authmw.BasicAuthMiddleware(models.PermReportsRead)).Get("/search", businessHandler.SearchAll)
 	})
 
 	return r
@@ -0,0 +1,115 @@
+// Package jobs — фоновые задачи сервера.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"oil-gas-service-booking/internal/http-server/repository"
+	"oil-gas-service-booking/internal/mailer"
+	"oil-gas-service-booking/internal/models"
+	"oil-gas-service-booking/internal/storage"
+)
+
+// Eraser исполняет запросы на удаление персональных данных, срок ожидания
+// которых истёк: обезличивает учётную запись (repository.UserRepo.Deactivate),
+// стирает следы в бронях и журнале и удаляет файлы аватара.
+type Eraser struct {
+	privacy    *repository.PrivacyRepo
+	users      *repository.UserRepo
+	security   *repository.SecurityRepo
+	mail       mailer.Mailer
+	uploadsDir string
+}
+
+func NewEraser(
+	privacy *repository.PrivacyRepo,
+	users *repository.UserRepo,
+	security *repository.SecurityRepo,
+	mail mailer.Mailer,
+	uploadsDir string,
+) *Eraser {
+	return &Eraser{privacy: privacy, users: users, security: security, mail: mail, uploadsDir: uploadsDir}
+}
+
+// Run обрабатывает очередь раз в interval, пока не отменён ctx.
+func (e *Eraser) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.RunOnce(ctx); err != nil {
+			log.Printf("erasure: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce исполняет все просроченные запросы. Запрос, который сейчас
+// исполнить нельзя (пользователь владеет компанией, последний администратор
+// или единственный администратор организации заказчика), остаётся в очереди
+// с причиной в LastError.
+func (e *Eraser) RunOnce(ctx context.Context) error {
+	due, err := e.privacy.DueErasures(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, req := range due {
+		if err := e.erase(ctx, req); err != nil {
+			log.Printf("erasure: запрос %d пользователя %d: %v", req.RequestID, req.UserID, err)
+			if ferr := e.privacy.FailErasure(req.RequestID, err.Error()); ferr != nil {
+				return ferr
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Eraser) erase(ctx context.Context, req models.ErasureRequest) error {
+	before, err := e.users.Deactivate(req.UserID)
+	switch {
+	case errors.Is(err, repository.ErrUserDeactivated):
+		// Учётную запись уже удалил администратор — дочищаем остальное.
+		before = nil
+	case err != nil:
+		return err
+	}
+
+	var email *string
+	if before != nil {
+		email = before.Email
+	}
+	if err := e.privacy.EraseTraces(req.UserID, email); err != nil {
+		return err
+	}
+	if err := storage.RemoveAvatars(e.uploadsDir, req.UserID); err != nil {
+		return err
+	}
+	if err := e.privacy.CompleteErasure(req.RequestID); err != nil {
+		return err
+	}
+
+	userID := req.UserID
+	detail := fmt.Sprintf("request %d", req.RequestID)
+	if err := e.security.LogEvent(&models.SecurityEvent{Type: models.SecurityEventErased, UserID: &userID, Detail: &detail}); err != nil {
+		log.Printf("erasure: не удалось записать событие: %v", err)
+	}
+	if email != nil {
+		msg := mailer.Message{
+			To:      *email,
+			Subject: "Ваши персональные данные удалены",
+			Body: "Здравствуйте!\n\nПо вашему запросу учётная запись удалена, а персональные данные обезличены. " +
+				"Сведения о бронях сохранены без ваших личных данных.",
+		}
+		if err := e.mail.Send(ctx, msg); err != nil {
+			log.Printf("erasure: не удалось отправить письмо: %v", err)
+		}
+	}
+	return nil
+}
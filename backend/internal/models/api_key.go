@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Области действия API-ключа.
+const (
+	APIKeyScopeBookingsRead  = "bookings:read"
+	APIKeyScopeBookingsWrite = "bookings:write"
+	APIKeyScopeCatalogWrite  = "catalog:write"
+)
+
+// APIKeyPrefix — начало каждого ключа; по нему middleware отличает ключ от JWT.
+const APIKeyPrefix = "ogk_"
+
+// apiKeyScopePermissions — права, которые открывает область. Итоговые права
+// запроса — пересечение прав областей ключа и прав роли его владельца.
+var apiKeyScopePermissions = map[string][]Permission{
+	APIKeyScopeBookingsRead: {
+		PermAccount, PermCatalogRead, PermBookingsRead,
+	},
+	APIKeyScopeBookingsWrite: {
+		PermAccount, PermCatalogRead, PermBookingsRead,
+		PermBookingsCreate, PermCompaniesOperate,
+	},
+	APIKeyScopeCatalogWrite: {
+		PermAccount, PermCatalogRead, PermCatalogWrite, PermCompaniesManage,
+	},
+}
+
+func IsValidAPIKeyScope(scope string) bool {
+	_, ok := apiKeyScopePermissions[scope]
+	return ok
+}
+
+// APIKeyScopePermissions — объединение прав перечисленных областей.
+func APIKeyScopePermissions(scopes []string) map[Permission]bool {
+	set := map[Permission]bool{}
+	for _, s := range scopes {
+		for _, p := range apiKeyScopePermissions[s] {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// RoleAllowsAPIKeyScope — роль владельца обладает хотя бы одним правом,
+// ради которого существует область (без этого ключ был бы бесполезен).
+func RoleAllowsAPIKeyScope(role, scope string) bool {
+	switch scope {
+	case APIKeyScopeBookingsRead:
+		return RoleHasPermission(role, PermBookingsRead)
+	case APIKeyScopeBookingsWrite:
+		return RoleHasPermission(role, PermBookingsCreate) || RoleHasPermission(role, PermCompaniesOperate)
+	case APIKeyScopeCatalogWrite:
+		return RoleHasPermission(role, PermCompaniesManage)
+	}
+	return false
+}
+
+// APIKey — ключ для интеграций. Действует от имени UserID в пределах областей
+// Scopes (через запятую). Ключ компании (CompanyID задан) создаёт владелец или
+// менеджер компании; работать через него можно только с этой компанией, и он
+// перестаёт действовать, когда создатель теряет право управлять компанией.
+// Сам ключ хранится только в виде SHA-256, Prefix — его открытое начало для
+// списков.
+type APIKey struct {
+	KeyID           int64      `gorm:"column:key_id;primaryKey;autoIncrement" json:"key_id"`
+	UserID          int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	CompanyID       *int64     `gorm:"column:company_id;index" json:"company_id,omitempty"`
+	Name            string     `gorm:"column:name;not null" json:"name"`
+	Prefix          string     `gorm:"column:prefix;not null" json:"prefix"`
+	KeyHash         string     `gorm:"column:key_hash;not null;uniqueIndex" json:"-"`
+	Scopes          string     `gorm:"column:scopes;not null" json:"-"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	LastUsedAt      *time.Time `gorm:"column:last_used_at" json:"last_used_at,omitempty"`
+	LastUsedIP      *string    `gorm:"column:last_used_ip" json:"last_used_ip,omitempty"`
+	CreatedByUserID int64      `gorm:"column:created_by_user_id;not null" json:"created_by_user_id"`
+	RevokedAt       *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	User    User     `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Company *Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (APIKey) TableName() string { return "api_key" }
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// Active — ключ не отозван и не истёк.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && k.ExpiresAt.After(now)
+}
@@ -0,0 +1,118 @@
+package models
+
+import "time"
+
+// RefreshToken — серверная запись refresh-токена. Сам токен хранится только в
+// виде SHA-256; при обновлении токен отзывается и заменяется новым из той же
+// цепочки (FamilyID). Повторное предъявление отозванного токена отзывает всю
+// цепочку.
+type RefreshToken struct {
+	TokenID      int64      `gorm:"column:token_id;primaryKey;autoIncrement" json:"token_id"`
+	UserID       int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	FamilyID     string     `gorm:"column:family_id;not null;index" json:"family_id"`
+	TokenHash    string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	ExpiresAt    time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	RevokedAt    *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	ReplacedByID *int64     `gorm:"column:replaced_by_id" json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (RefreshToken) TableName() string { return "refresh_token" }
+
+// UserSession — один вход пользователя: цепочка refresh-токенов FamilyID и
+// все access-токены, выданные в ней (claim sid). ExpiresAt сдвигается при
+// каждой ротации; отзыв сеанса отзывает цепочку, а middleware перестаёт
+// принимать его access-токены.
+type UserSession struct {
+	SessionID  int64      `gorm:"column:session_id;primaryKey;autoIncrement" json:"session_id"`
+	UserID     int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	FamilyID   string     `gorm:"column:family_id;not null;uniqueIndex" json:"-"`
+	UserAgent  string     `gorm:"column:user_agent;not null;default:''" json:"user_agent"`
+	IP         string     `gorm:"column:ip;not null" json:"ip"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at;not null" json:"last_seen_at"`
+	LastSeenIP string     `gorm:"column:last_seen_ip;not null" json:"last_seen_ip"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at;index" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (UserSession) TableName() string { return "user_session" }
+
+// RevokedAccessToken — отозванный до истечения срока access-токен (по jti).
+// Запись нужна только до ExpiresAt, после этого токен отвергается и так.
+type RevokedAccessToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey" json:"jti"`
+	UserID    int64     `gorm:"column:user_id;not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (RevokedAccessToken) TableName() string { return "revoked_access_token" }
+
+const (
+	UserTokenPasswordReset = "password_reset"
+	UserTokenEmailVerify   = "email_verification"
+)
+
+// UserToken — одноразовая ссылка из письма: сброс пароля или подтверждение
+// email. Хранится только SHA-256 токена; Email — адрес, который подтверждается
+// (при смене email он ещё не записан в пользователя).
+type UserToken struct {
+	TokenID   int64      `gorm:"column:token_id;primaryKey;autoIncrement" json:"token_id"`
+	UserID    int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	Purpose   string     `gorm:"column:purpose;not null" json:"purpose"`
+	TokenHash string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	Email     string     `gorm:"column:email;not null" json:"email"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (UserToken) TableName() string { return "user_token" }
+
+// UserTOTP — второй фактор входа. Пока ConfirmedAt пуст, настройка не
+// завершена и при входе не требуется. LastUsedStep — шаг последнего принятого
+// кода, чтобы один код нельзя было предъявить повторно.
+type UserTOTP struct {
+	UserID       int64      `gorm:"column:user_id;primaryKey" json:"user_id"`
+	Secret       string     `gorm:"column:secret;not null" json:"-"`
+	ConfirmedAt  *time.Time `gorm:"column:confirmed_at" json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `gorm:"column:last_used_step;not null;default:0" json:"-"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (UserTOTP) TableName() string { return "user_totp" }
+
+// RecoveryCode — одноразовый резервный код входа на случай потери
+// аутентификатора. Хранится только SHA-256.
+type RecoveryCode struct {
+	CodeID    int64      `gorm:"column:code_id;primaryKey;autoIncrement" json:"code_id"`
+	UserID    int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"column:code_hash;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (RecoveryCode) TableName() string { return "recovery_code" }
+
+// MFAChallenge — первый шаг входа пройден, ждём код второго фактора. Токен
+// выдаётся клиенту вместо JWT и хранится только в виде SHA-256.
+type MFAChallenge struct {
+	ChallengeID int64      `gorm:"column:challenge_id;primaryKey;autoIncrement" json:"challenge_id"`
+	UserID      int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	TokenHash   string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	ExpiresAt   time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UsedAt      *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (MFAChallenge) TableName() string { return "mfa_challenge" }
@@ -0,0 +1,193 @@
+package models
+
+import "fmt"
+
+const (
+	// Бронь сотрудника организации заказчика ждёт внутреннего согласования;
+	// исполнители её не видят.
+	BookingStatusPendingApproval = "pending_internal_approval"
+
+	BookingStatusRequested  = "requested"
+	BookingStatusWaitlisted = "waitlisted"
+	BookingStatusApproved   = "approved"
+	BookingStatusInProgress = "in_progress"
+	BookingStatusCompleted  = "completed"
+	BookingStatusRejected   = "rejected"
+	BookingStatusCancelled  = "cancelled"
+
+	// Сводные статусы брони, в которой строки разных компаний находятся на
+	// разных этапах. Выставляются только вычислением, см. DeriveBookingStatus.
+	BookingStatusPartiallyApproved  = "partially_approved"
+	BookingStatusPartiallyCompleted = "partially_completed"
+)
+
+// BookingActor — сторона, инициирующая смену статуса брони.
+type BookingActor string
+
+const (
+	BookingActorCustomer BookingActor = "customer"
+	BookingActorCompany  BookingActor = "company"
+	BookingActorAdmin    BookingActor = "admin"
+	// BookingActorApprover — согласующий из организации заказчика.
+	BookingActorApprover BookingActor = "approver"
+)
+
+// BookingActiveStatuses — статусы, в которых бронь считается активной.
+var BookingActiveStatuses = []string{
+	BookingStatusRequested,
+	BookingStatusApproved,
+	BookingStatusInProgress,
+	BookingStatusPartiallyApproved,
+	BookingStatusPartiallyCompleted,
+}
+
+// bookingTransitions описывает жизненный цикл брони: из какого статуса в какой
+// можно перейти и кому это разрешено. Администратор может выполнить любой
+// допустимый переход, но не может нарушить сам граф.
+var bookingTransitions = map[string]map[string][]BookingActor{
+	BookingStatusPendingApproval: {
+		BookingStatusRequested:  {BookingActorApprover, BookingActorAdmin},
+		BookingStatusWaitlisted: {BookingActorApprover, BookingActorAdmin},
+		// Бронь по выбранному предложению после согласования сразу подтверждена.
+		BookingStatusApproved: {BookingActorApprover, BookingActorAdmin},
+		// Отказ согласующего — отмена со стороны заказчика.
+		BookingStatusCancelled: {BookingActorCustomer, BookingActorApprover, BookingActorAdmin},
+	},
+	BookingStatusRequested: {
+		BookingStatusWaitlisted: {BookingActorCustomer, BookingActorAdmin},
+		// Заказчик подтверждает строки, принимая коммерческое предложение компании.
+		BookingStatusApproved:  {BookingActorCompany, BookingActorCustomer, BookingActorAdmin},
+		BookingStatusRejected:  {BookingActorCompany, BookingActorAdmin},
+		BookingStatusCancelled: {BookingActorCustomer, BookingActorAdmin},
+	},
+	BookingStatusWaitlisted: {
+		BookingStatusRequested: {BookingActorCompany, BookingActorAdmin},
+		BookingStatusRejected:  {BookingActorCompany, BookingActorAdmin},
+		BookingStatusCancelled: {BookingActorCustomer, BookingActorAdmin},
+	},
+	BookingStatusApproved: {
+		BookingStatusInProgress: {BookingActorCompany, BookingActorAdmin},
+		BookingStatusRejected:   {BookingActorCompany, BookingActorAdmin},
+		BookingStatusCancelled:  {BookingActorCustomer, BookingActorAdmin},
+	},
+	BookingStatusInProgress: {
+		BookingStatusCompleted: {BookingActorCompany, BookingActorAdmin},
+	},
+	BookingStatusCompleted: {},
+	BookingStatusRejected:  {},
+	BookingStatusCancelled: {},
+}
+
+// BookingLinesEditable сообщает, можно ли ещё менять состав и цены строк брони.
+func BookingLinesEditable(status string) bool {
+	return status == BookingStatusPendingApproval || status == BookingStatusRequested || status == BookingStatusWaitlisted
+}
+
+func IsValidBookingStatus(status string) bool {
+	_, ok := bookingTransitions[status]
+	return ok
+}
+
+// IsTerminalBookingStatus сообщает, что из статуса нет ни одного перехода.
+func IsTerminalBookingStatus(status string) bool {
+	next, ok := bookingTransitions[status]
+	return ok && len(next) == 0
+}
+
+// DeriveBookingStatus вычисляет статус брони по статусам её строк. Отклонённые
+// и отменённые строки не мешают остальным: бронь, где одна компания отказала,
+// а другая подтвердила, считается подтверждённой. Для брони без строк
+// возвращается пустая строка — статус остаётся как есть.
+func DeriveBookingStatus(lines []BookingService) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	counts := map[string]int{}
+	live := 0
+	for _, bs := range lines {
+		counts[bs.Status]++
+		if bs.Status != BookingStatusRejected && bs.Status != BookingStatusCancelled {
+			live++
+		}
+	}
+
+	switch {
+	case live == 0 && counts[BookingStatusRejected] == len(lines):
+		return BookingStatusRejected
+	case live == 0:
+		return BookingStatusCancelled
+	}
+	for _, s := range []string{
+		BookingStatusPendingApproval,
+		BookingStatusRequested,
+		BookingStatusWaitlisted,
+		BookingStatusApproved,
+		BookingStatusInProgress,
+		BookingStatusCompleted,
+	} {
+		if counts[s] == live {
+			return s
+		}
+	}
+
+	switch {
+	case counts[BookingStatusCompleted] > 0:
+		return BookingStatusPartiallyCompleted
+	case counts[BookingStatusInProgress] > 0:
+		return BookingStatusInProgress
+	case counts[BookingStatusApproved] > 0:
+		return BookingStatusPartiallyApproved
+	case counts[BookingStatusRequested] > 0:
+		return BookingStatusRequested
+	}
+	return BookingStatusWaitlisted
+}
+
+// NextBookingStatuses возвращает статусы, в которые actor может перевести бронь из from.
+func NextBookingStatuses(from string, actor BookingActor) []string {
+	next := []string{}
+	for _, to := range bookingStatusOrder {
+		for _, a := range bookingTransitions[from][to] {
+			if a == actor {
+				next = append(next, to)
+				break
+			}
+		}
+	}
+	return next
+}
+
+var bookingStatusOrder = []string{
+	BookingStatusPendingApproval,
+	BookingStatusRequested,
+	BookingStatusWaitlisted,
+	BookingStatusApproved,
+	BookingStatusInProgress,
+	BookingStatusCompleted,
+	BookingStatusRejected,
+	BookingStatusCancelled,
+}
+
+type BookingTransitionError struct {
+	From  string
+	To    string
+	Actor BookingActor
+}
+
+func (e *BookingTransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("booking cannot be created with status %s", e.To)
+	}
+	return fmt.Sprintf("booking status transition %s -> %s is not allowed for %s", e.From, e.To, e.Actor)
+}
+
+// CheckBookingTransition проверяет, может ли actor перевести бронь из from в to.
+func CheckBookingTransition(from, to string, actor BookingActor) error {
+	for _, a := range bookingTransitions[from][to] {
+		if a == actor {
+			return nil
+		}
+	}
+	return &BookingTransitionError{From: from, To: to, Actor: actor}
+}
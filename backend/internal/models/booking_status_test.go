@@ -0,0 +1,190 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckBookingTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		actor    BookingActor
+		allowed  bool
+	}{
+		// Внутреннее согласование организации заказчика.
+		{BookingStatusPendingApproval, BookingStatusRequested, BookingActorApprover, true},
+		{BookingStatusPendingApproval, BookingStatusWaitlisted, BookingActorApprover, true},
+		{BookingStatusPendingApproval, BookingStatusApproved, BookingActorApprover, true},
+		{BookingStatusPendingApproval, BookingStatusCancelled, BookingActorApprover, true},
+		{BookingStatusPendingApproval, BookingStatusCancelled, BookingActorCustomer, true},
+		{BookingStatusPendingApproval, BookingStatusRequested, BookingActorAdmin, true},
+		{BookingStatusPendingApproval, BookingStatusRequested, BookingActorCustomer, false},
+		{BookingStatusPendingApproval, BookingStatusApproved, BookingActorCompany, false},
+		{BookingStatusPendingApproval, BookingStatusRejected, BookingActorCompany, false},
+		{BookingStatusPendingApproval, BookingStatusInProgress, BookingActorAdmin, false},
+
+		{BookingStatusRequested, BookingStatusApproved, BookingActorCompany, true},
+		{BookingStatusRequested, BookingStatusApproved, BookingActorCustomer, true},
+		{BookingStatusRequested, BookingStatusRejected, BookingActorCompany, true},
+		{BookingStatusRequested, BookingStatusRejected, BookingActorCustomer, false},
+		{BookingStatusRequested, BookingStatusCancelled, BookingActorCustomer, true},
+		{BookingStatusRequested, BookingStatusCancelled, BookingActorCompany, false},
+		{BookingStatusRequested, BookingStatusWaitlisted, BookingActorCustomer, true},
+		{BookingStatusRequested, BookingStatusWaitlisted, BookingActorCompany, false},
+		{BookingStatusRequested, BookingStatusInProgress, BookingActorCompany, false},
+		{BookingStatusRequested, BookingStatusRequested, BookingActorAdmin, false},
+		{BookingStatusRequested, BookingStatusPendingApproval, BookingActorApprover, false},
+
+		{BookingStatusWaitlisted, BookingStatusRequested, BookingActorCompany, true},
+		{BookingStatusWaitlisted, BookingStatusRequested, BookingActorCustomer, false},
+		{BookingStatusWaitlisted, BookingStatusRejected, BookingActorCompany, true},
+		{BookingStatusWaitlisted, BookingStatusCancelled, BookingActorCustomer, true},
+		{BookingStatusWaitlisted, BookingStatusApproved, BookingActorCompany, false},
+
+		{BookingStatusApproved, BookingStatusInProgress, BookingActorCompany, true},
+		{BookingStatusApproved, BookingStatusInProgress, BookingActorCustomer, false},
+		{BookingStatusApproved, BookingStatusRejected, BookingActorCompany, true},
+		{BookingStatusApproved, BookingStatusCancelled, BookingActorCustomer, true},
+		{BookingStatusApproved, BookingStatusCompleted, BookingActorCompany, false},
+		{BookingStatusApproved, BookingStatusCompleted, BookingActorAdmin, false},
+
+		{BookingStatusInProgress, BookingStatusCompleted, BookingActorCompany, true},
+		{BookingStatusInProgress, BookingStatusCompleted, BookingActorAdmin, true},
+		{BookingStatusInProgress, BookingStatusCompleted, BookingActorCustomer, false},
+		{BookingStatusInProgress, BookingStatusCancelled, BookingActorCustomer, false},
+		{BookingStatusInProgress, BookingStatusCancelled, BookingActorAdmin, false},
+
+		// Из конечных статусов не выводит никто, включая администратора.
+		{BookingStatusCompleted, BookingStatusInProgress, BookingActorAdmin, false},
+		{BookingStatusCompleted, BookingStatusCancelled, BookingActorCustomer, false},
+		{BookingStatusRejected, BookingStatusRequested, BookingActorAdmin, false},
+		{BookingStatusRejected, BookingStatusApproved, BookingActorCompany, false},
+		{BookingStatusCancelled, BookingStatusRequested, BookingActorAdmin, false},
+		{BookingStatusCancelled, BookingStatusRequested, BookingActorCustomer, false},
+
+		// Сводные статусы вычисляются, вручную в них не переводят.
+		{BookingStatusRequested, BookingStatusPartiallyApproved, BookingActorAdmin, false},
+		{"", BookingStatusRequested, BookingActorCustomer, false},
+		{"unknown", BookingStatusCancelled, BookingActorAdmin, false},
+	}
+	for _, tt := range tests {
+		err := CheckBookingTransition(tt.from, tt.to, tt.actor)
+		if tt.allowed && err != nil {
+			t.Errorf("%s -> %s by %s: unexpected error %v", tt.from, tt.to, tt.actor, err)
+		}
+		if !tt.allowed {
+			var terr *BookingTransitionError
+			if !errors.As(err, &terr) {
+				t.Errorf("%s -> %s by %s: got %v, want *BookingTransitionError", tt.from, tt.to, tt.actor, err)
+			}
+		}
+	}
+}
+
+func TestBookingTerminalStatuses(t *testing.T) {
+	for _, s := range []string{BookingStatusCompleted, BookingStatusRejected, BookingStatusCancelled} {
+		if !IsTerminalBookingStatus(s) {
+			t.Errorf("%s: want terminal", s)
+		}
+		for _, actor := range []BookingActor{BookingActorCustomer, BookingActorCompany, BookingActorAdmin, BookingActorApprover} {
+			if next := NextBookingStatuses(s, actor); len(next) != 0 {
+				t.Errorf("%s by %s: want no transitions, got %v", s, actor, next)
+			}
+		}
+	}
+	for _, s := range []string{BookingStatusPendingApproval, BookingStatusRequested, BookingStatusWaitlisted, BookingStatusApproved, BookingStatusInProgress} {
+		if IsTerminalBookingStatus(s) {
+			t.Errorf("%s: want non-terminal", s)
+		}
+	}
+	if IsTerminalBookingStatus("unknown") {
+		t.Error("unknown status must not be terminal")
+	}
+}
+
+func TestNextBookingStatuses(t *testing.T) {
+	tests := []struct {
+		from  string
+		actor BookingActor
+		want  []string
+	}{
+		{BookingStatusPendingApproval, BookingActorApprover, []string{BookingStatusRequested, BookingStatusWaitlisted, BookingStatusApproved, BookingStatusCancelled}},
+		{BookingStatusPendingApproval, BookingActorCustomer, []string{BookingStatusCancelled}},
+		{BookingStatusPendingApproval, BookingActorCompany, []string{}},
+		{BookingStatusRequested, BookingActorCompany, []string{BookingStatusApproved, BookingStatusRejected}},
+		{BookingStatusRequested, BookingActorCustomer, []string{BookingStatusWaitlisted, BookingStatusApproved, BookingStatusCancelled}},
+		{BookingStatusApproved, BookingActorAdmin, []string{BookingStatusInProgress, BookingStatusRejected, BookingStatusCancelled}},
+		{BookingStatusInProgress, BookingActorCompany, []string{BookingStatusCompleted}},
+	}
+	for _, tt := range tests {
+		got := NextBookingStatuses(tt.from, tt.actor)
+		if !equalStrings(got, tt.want) {
+			t.Errorf("NextBookingStatuses(%s, %s) = %v, want %v", tt.from, tt.actor, got, tt.want)
+		}
+	}
+}
+
+func TestBookingLinesEditable(t *testing.T) {
+	editable := map[string]bool{
+		BookingStatusPendingApproval: true,
+		BookingStatusRequested:       true,
+		BookingStatusWaitlisted:      true,
+	}
+	for _, s := range bookingStatusOrder {
+		if got := BookingLinesEditable(s); got != editable[s] {
+			t.Errorf("BookingLinesEditable(%s) = %v, want %v", s, got, editable[s])
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func linesWith(statuses ...string) []BookingService {
+	lines := make([]BookingService, 0, len(statuses))
+	for _, s := range statuses {
+		lines = append(lines, BookingService{Status: s})
+	}
+	return lines
+}
+
+func TestDeriveBookingStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []BookingService
+		want  string
+	}{
+		{"no lines", nil, ""},
+		{"all requested", linesWith(BookingStatusRequested, BookingStatusRequested), BookingStatusRequested},
+		{"all pending approval", linesWith(BookingStatusPendingApproval, BookingStatusPendingApproval), BookingStatusPendingApproval},
+		{"all waitlisted", linesWith(BookingStatusWaitlisted), BookingStatusWaitlisted},
+		{"all approved", linesWith(BookingStatusApproved, BookingStatusApproved), BookingStatusApproved},
+		{"all completed", linesWith(BookingStatusCompleted, BookingStatusCompleted), BookingStatusCompleted},
+		{"all rejected", linesWith(BookingStatusRejected, BookingStatusRejected), BookingStatusRejected},
+		{"all cancelled", linesWith(BookingStatusCancelled, BookingStatusCancelled), BookingStatusCancelled},
+		{"rejected and cancelled", linesWith(BookingStatusRejected, BookingStatusCancelled), BookingStatusCancelled},
+		{"approved and rejected", linesWith(BookingStatusApproved, BookingStatusRejected), BookingStatusApproved},
+		{"completed and cancelled", linesWith(BookingStatusCompleted, BookingStatusCancelled), BookingStatusCompleted},
+		{"approved and requested", linesWith(BookingStatusApproved, BookingStatusRequested), BookingStatusPartiallyApproved},
+		{"approved and waitlisted", linesWith(BookingStatusApproved, BookingStatusWaitlisted, BookingStatusRejected), BookingStatusPartiallyApproved},
+		{"completed and approved", linesWith(BookingStatusCompleted, BookingStatusApproved), BookingStatusPartiallyCompleted},
+		{"completed and in progress", linesWith(BookingStatusCompleted, BookingStatusInProgress), BookingStatusPartiallyCompleted},
+		{"in progress and approved", linesWith(BookingStatusInProgress, BookingStatusApproved), BookingStatusInProgress},
+		{"requested and waitlisted", linesWith(BookingStatusRequested, BookingStatusWaitlisted), BookingStatusRequested},
+		{"waitlisted and rejected", linesWith(BookingStatusWaitlisted, BookingStatusRejected), BookingStatusWaitlisted},
+	}
+	for _, tt := range tests {
+		if got := DeriveBookingStatus(tt.lines); got != tt.want {
+			t.Errorf("%s: DeriveBookingStatus = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Роли сотрудника внутри компании. Глобальная роль пользователя (User.Role)
+// определяет доступные маршруты, роль в компании — что он может с ней делать.
+const (
+	MemberRoleOwner      = "owner"
+	MemberRoleManager    = "manager"
+	MemberRoleDispatcher = "dispatcher"
+	MemberRoleViewer     = "viewer"
+)
+
+// MemberManageRoles — правка профиля компании, её услуг и расписания.
+var MemberManageRoles = []string{MemberRoleOwner, MemberRoleManager}
+
+// MemberOperateRoles — работа с бронями, предложениями и откликами на заявки.
+var MemberOperateRoles = []string{MemberRoleOwner, MemberRoleManager, MemberRoleDispatcher}
+
+// MemberViewRoles — просмотр броней компании.
+var MemberViewRoles = []string{MemberRoleOwner, MemberRoleManager, MemberRoleDispatcher, MemberRoleViewer}
+
+func IsValidMemberRole(role string) bool {
+	for _, r := range MemberViewRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CompanyMember — сотрудник компании. Пользователь из Company.UserID всегда
+// состоит в компании с ролью owner.
+type CompanyMember struct {
+	CompanyID       int64     `gorm:"column:company_id;primaryKey" json:"company_id"`
+	UserID          int64     `gorm:"column:user_id;primaryKey;index" json:"user_id"`
+	Role            string    `gorm:"column:role;not null" json:"role"`
+	InvitedByUserID *int64    `gorm:"column:invited_by_user_id" json:"invited_by_user_id,omitempty"`
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Company Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	User    User    `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CompanyMember) TableName() string { return "company_member" }
+
+// CompanyInvitation — приглашение в компанию по email. Токен хранится только
+// в виде SHA-256 и действует до ExpiresAt.
+type CompanyInvitation struct {
+	InvitationID     int64      `gorm:"column:invitation_id;primaryKey;autoIncrement" json:"invitation_id"`
+	CompanyID        int64      `gorm:"column:company_id;not null;index" json:"company_id"`
+	Email            string     `gorm:"column:email;not null;index" json:"email"`
+	Role             string     `gorm:"column:role;not null" json:"role"`
+	TokenHash        string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	ExpiresAt        time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	InvitedByUserID  int64      `gorm:"column:invited_by_user_id;not null" json:"invited_by_user_id"`
+	AcceptedAt       *time.Time `gorm:"column:accepted_at" json:"accepted_at,omitempty"`
+	AcceptedByUserID *int64     `gorm:"column:accepted_by_user_id" json:"accepted_by_user_id,omitempty"`
+	RevokedAt        *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Company Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CompanyInvitation) TableName() string { return "company_invitation" }
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+	InvitationStatusExpired  = "expired"
+)
+
+// Status вычисляет состояние приглашения на момент now.
+func (inv *CompanyInvitation) Status(now time.Time) string {
+	switch {
+	case inv.AcceptedAt != nil:
+		return InvitationStatusAccepted
+	case inv.RevokedAt != nil:
+		return InvitationStatusRevoked
+	case !inv.ExpiresAt.After(now):
+		return InvitationStatusExpired
+	}
+	return InvitationStatusPending
+}
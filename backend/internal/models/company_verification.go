@@ -0,0 +1,29 @@
+package models
+
+// Статусы модерации компании: владелец заполняет черновик и отправляет его на
+// проверку, администратор одобряет или отклоняет с комментарием.
+const (
+	CompanyVerificationDraft    = "draft"
+	CompanyVerificationPending  = "pending_review"
+	CompanyVerificationOK       = "verified"
+	CompanyVerificationRejected = "rejected"
+)
+
+func IsValidCompanyVerification(status string) bool {
+	switch status {
+	case CompanyVerificationDraft, CompanyVerificationPending, CompanyVerificationOK, CompanyVerificationRejected:
+		return true
+	}
+	return false
+}
+
+// Verified — компания прошла проверку и доступна заказчикам.
+func (c *Company) Verified() bool {
+	return c.VerificationStatus == CompanyVerificationOK
+}
+
+// CanSubmitForReview — на проверку отправляется черновик или отклонённая
+// компания после исправлений.
+func (c *Company) CanSubmitForReview() bool {
+	return c.VerificationStatus == CompanyVerificationDraft || c.VerificationStatus == CompanyVerificationRejected
+}
@@ -0,0 +1,132 @@
+package models
+
+import "time"
+
+// Роли в организации заказчика. Инженер оформляет брони, менеджер
+// согласует их в пределах своего лимита, администратор организации
+// согласует без ограничений и управляет составом и лимитами.
+const (
+	OrgRoleAdmin    = "admin"
+	OrgRoleManager  = "manager"
+	OrgRoleEngineer = "engineer"
+)
+
+func IsValidOrgRole(role string) bool {
+	return role == OrgRoleAdmin || role == OrgRoleManager || role == OrgRoleEngineer
+}
+
+// CustomerOrg — организация заказчика (оператор месторождения), от имени
+// которой сотрудники оформляют брони. Брони на сумму до AutoApproveLimit
+// включительно не требуют внутреннего согласования.
+type CustomerOrg struct {
+	OrgID            int64     `gorm:"column:org_id;primaryKey;autoIncrement" json:"org_id"`
+	Name             string    `gorm:"column:name;not null" json:"name"`
+	AutoApproveLimit float64   `gorm:"column:auto_approve_limit;not null;default:0" json:"auto_approve_limit"`
+	CreatedByUserID  int64     `gorm:"column:created_by_user_id;not null" json:"created_by_user_id"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (CustomerOrg) TableName() string { return "customer_org" }
+
+// CustomerOrgMember — сотрудник организации заказчика. Пользователь состоит
+// не более чем в одной организации. ApprovalLimit — предельная сумма брони,
+// которую менеджер согласует сам; nil — без ограничения.
+type CustomerOrgMember struct {
+	OrgID         int64     `gorm:"column:org_id;not null;index" json:"org_id"`
+	UserID        int64     `gorm:"column:user_id;primaryKey" json:"user_id"`
+	Role          string    `gorm:"column:role;not null" json:"role"`
+	ApprovalLimit *float64  `gorm:"column:approval_limit" json:"approval_limit"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Org  CustomerOrg `gorm:"foreignKey:OrgID;references:OrgID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	User User        `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CustomerOrgMember) TableName() string { return "customer_org_member" }
+
+// CustomerOrgInvitation — приглашение в организацию заказчика по email.
+// Сотрудником пользователь становится, только приняв приглашение; токен
+// хранится в виде SHA-256 и действует до ExpiresAt.
+type CustomerOrgInvitation struct {
+	InvitationID     int64      `gorm:"column:invitation_id;primaryKey;autoIncrement" json:"invitation_id"`
+	OrgID            int64      `gorm:"column:org_id;not null;index" json:"org_id"`
+	Email            string     `gorm:"column:email;not null;index" json:"email"`
+	Role             string     `gorm:"column:role;not null" json:"role"`
+	ApprovalLimit    *float64   `gorm:"column:approval_limit" json:"approval_limit"`
+	TokenHash        string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	ExpiresAt        time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	InvitedByUserID  int64      `gorm:"column:invited_by_user_id;not null" json:"invited_by_user_id"`
+	AcceptedAt       *time.Time `gorm:"column:accepted_at" json:"accepted_at,omitempty"`
+	AcceptedByUserID *int64     `gorm:"column:accepted_by_user_id" json:"accepted_by_user_id,omitempty"`
+	RevokedAt        *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Org CustomerOrg `gorm:"foreignKey:OrgID;references:OrgID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CustomerOrgInvitation) TableName() string { return "customer_org_invitation" }
+
+// Status вычисляет состояние приглашения на момент now.
+func (inv *CustomerOrgInvitation) Status(now time.Time) string {
+	switch {
+	case inv.AcceptedAt != nil:
+		return InvitationStatusAccepted
+	case inv.RevokedAt != nil:
+		return InvitationStatusRevoked
+	case !inv.ExpiresAt.After(now):
+		return InvitationStatusExpired
+	}
+	return InvitationStatusPending
+}
+
+// CanApprove сообщает, может ли сотрудник согласовать бронь на сумму total.
+// Сумму без цены (nil) согласуют только сотрудники без лимита.
+func (m *CustomerOrgMember) CanApprove(total *float64) bool {
+	switch m.Role {
+	case OrgRoleAdmin:
+		return true
+	case OrgRoleManager:
+		return m.ApprovalLimit == nil || (total != nil && *total <= *m.ApprovalLimit)
+	}
+	return false
+}
+
+// NeedsApproval — бронь сотрудника m на сумму total нужно согласовать:
+// сумма выше порога организации, и собственного лимита m не хватает.
+func (o *CustomerOrg) NeedsApproval(m *CustomerOrgMember, total *float64) bool {
+	if total != nil && *total <= o.AutoApproveLimit {
+		return false
+	}
+	return !m.CanApprove(total)
+}
+
+// IsApprover — сотрудник участвует в цепочке согласования.
+func (m *CustomerOrgMember) IsApprover() bool {
+	return m.Role == OrgRoleAdmin || m.Role == OrgRoleManager
+}
+
+const (
+	ApprovalDecisionApproved  = "approved"
+	ApprovalDecisionEscalated = "escalated"
+	ApprovalDecisionRejected  = "rejected"
+)
+
+// BookingApproval — шаг цепочки внутреннего согласования брони. Менеджер,
+// чьего лимита не хватает, одобряет бронь с решением escalated, и она ждёт
+// согласующего с бо́льшим лимитом; approved выпускает бронь к исполнителям.
+type BookingApproval struct {
+	ApprovalID     int64     `gorm:"column:approval_id;primaryKey;autoIncrement" json:"approval_id"`
+	BookingID      int64     `gorm:"column:booking_id;not null;index" json:"booking_id"`
+	OrgID          int64     `gorm:"column:org_id;not null;index" json:"org_id"`
+	ApproverUserID int64     `gorm:"column:approver_user_id;not null" json:"approver_user_id"`
+	Decision       string    `gorm:"column:decision;not null" json:"decision"`
+	Total          *float64  `gorm:"column:total" json:"total"`
+	Comment        *string   `gorm:"column:comment" json:"comment,omitempty"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Booking  Booking `gorm:"foreignKey:BookingID;references:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Approver User    `gorm:"foreignKey:ApproverUserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (BookingApproval) TableName() string { return "booking_approval" }
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// ImpersonationSession — вход администратора от имени пользователя, чтобы
+// увидеть сервис его глазами. TokenID — jti выданного токена: завершение
+// сеанса отзывает токен.
+type ImpersonationSession struct {
+	SessionID   int64      `gorm:"column:session_id;primaryKey;autoIncrement" json:"session_id"`
+	AdminUserID int64      `gorm:"column:admin_user_id;not null;index" json:"admin_user_id"`
+	UserID      int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	Reason      string     `gorm:"column:reason;not null" json:"reason"`
+	TokenID     string     `gorm:"column:token_id;not null;uniqueIndex" json:"-"`
+	ExpiresAt   time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	EndedAt     *time.Time `gorm:"column:ended_at" json:"ended_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (ImpersonationSession) TableName() string { return "impersonation_session" }
+
+// Active — сеанс не завершён и его токен ещё действует.
+func (s *ImpersonationSession) Active(now time.Time) bool {
+	return s.EndedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// ImpersonationRequest — запрос, выполненный под имперсонацией. Blocked —
+// запрос отклонён (изменяющие действия под чужим именем запрещены); Status
+// пуст, пока обработчик не ответил.
+type ImpersonationRequest struct {
+	RequestID   int64     `gorm:"column:request_id;primaryKey;autoIncrement" json:"request_id"`
+	SessionID   *int64    `gorm:"column:session_id;index" json:"session_id,omitempty"`
+	AdminUserID int64     `gorm:"column:admin_user_id;not null;index" json:"admin_user_id"`
+	UserID      int64     `gorm:"column:user_id;not null" json:"user_id"`
+	TokenID     string    `gorm:"column:token_id;not null" json:"-"`
+	Method      string    `gorm:"column:method;not null" json:"method"`
+	Path        string    `gorm:"column:path;not null" json:"path"`
+	Status      *int      `gorm:"column:status" json:"status,omitempty"`
+	Blocked     bool      `gorm:"column:blocked;not null;default:false" json:"blocked"`
+	IP          string    `gorm:"column:ip;not null" json:"ip"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+}
+
+func (ImpersonationRequest) TableName() string { return "impersonation_request" }
@@ -3,14 +3,21 @@ package models
 import "time"
 
 type Company struct {
-	CompanyID   int64     `gorm:"column:company_id;primaryKey;autoIncrement"`
-	UserID      int64     `gorm:"column:user_id;not null;index"`
-	Name        string    `gorm:"column:name;not null"`
-	Description *string   `gorm:"column:description"`
-	Address     *string   `gorm:"column:address"`
-	LogoURL     *string   `gorm:"column:logo_url" json:"logo_url"`
-	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	CompanyID   int64   `gorm:"column:company_id;primaryKey;autoIncrement"`
+	UserID      int64   `gorm:"column:user_id;not null;index"`
+	Name        string  `gorm:"column:name;not null"`
+	Description *string `gorm:"column:description"`
+	Address     *string `gorm:"column:address"`
+	LogoURL     *string `gorm:"column:logo_url" json:"logo_url"`
+	// VerificationStatus — статус модерации (CompanyVerification*); заказчики
+	// видят и бронируют только проверенные компании.
+	VerificationStatus string     `gorm:"column:verification_status;not null;default:'draft';index" json:"verification_status"`
+	ReviewNotes        *string    `gorm:"column:review_notes" json:"review_notes,omitempty"`
+	SubmittedAt        *time.Time `gorm:"column:submitted_at" json:"submitted_at,omitempty"`
+	ReviewedAt         *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	ReviewedByUserID   *int64     `gorm:"column:reviewed_by_user_id" json:"reviewed_by_user_id,omitempty"`
+	CreatedAt          time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt          time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 
 	User            User             `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
 	CompanyServices []CompanyService `gorm:"foreignKey:CompanyID"`
@@ -23,6 +30,7 @@ type Service struct {
 	Title           string           `gorm:"column:title;not null"`
 	Description     *string          `gorm:"column:description"`
 	Price           *float64         `gorm:"column:price"`
+	Category        *string          `gorm:"column:category;index"`
 	ImageURL        *string          `gorm:"column:image_url" json:"image_url"`
 	CreatedAt       time.Time        `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt       time.Time        `gorm:"column:updated_at;autoUpdateTime"`
@@ -31,11 +39,33 @@ type Service struct {
 
 func (Service) TableName() string { return "service" }
 
+// CompanyRegion — регион, в котором компания готова работать.
+type CompanyRegion struct {
+	CompanyID int64  `gorm:"column:company_id;primaryKey" json:"company_id"`
+	Region    string `gorm:"column:region;primaryKey" json:"region"`
+
+	Company Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CompanyRegion) TableName() string { return "company_region" }
+
+// CompanyRequestOptOut — категория заявок, о которых компания не хочет получать уведомления.
+type CompanyRequestOptOut struct {
+	CompanyID int64  `gorm:"column:company_id;primaryKey" json:"company_id"`
+	Category  string `gorm:"column:category;primaryKey" json:"category"`
+
+	Company Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CompanyRequestOptOut) TableName() string { return "company_request_opt_out" }
+
 type CompanyService struct {
 	CompanyServiceID int64     `gorm:"column:company_service_id;primaryKey;autoIncrement"`
 	CompanyID        int64     `gorm:"column:company_id;not null;index"`
 	ServiceID        int64     `gorm:"column:service_id;not null;index"`
 	Price            *float64  `gorm:"column:price"`
+	Currency         string    `gorm:"column:currency;not null;default:'RUB'"`
+	Capacity         int       `gorm:"column:capacity;not null;default:1"`
 	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime"`
 
@@ -46,29 +76,72 @@ type CompanyService struct {
 
 func (CompanyService) TableName() string { return "company_service" }
 
+type CompanyServiceBlackout struct {
+	BlackoutID       int64     `gorm:"column:blackout_id;primaryKey;autoIncrement" json:"blackout_id"`
+	CompanyServiceID int64     `gorm:"column:company_service_id;not null;index" json:"company_service_id"`
+	StartsAt         time.Time `gorm:"column:starts_at;not null" json:"starts_at"`
+	EndsAt           time.Time `gorm:"column:ends_at;not null" json:"ends_at"`
+	Reason           *string   `gorm:"column:reason" json:"reason"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	CompanyService CompanyService `gorm:"foreignKey:CompanyServiceID;references:CompanyServiceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CompanyServiceBlackout) TableName() string { return "company_service_blackout" }
+
 type User struct {
-	UserID    int64     `gorm:"column:user_id;primaryKey;autoIncrement"`
-	Name      string    `gorm:"column:name;not null"`
-	Email     *string   `gorm:"column:email;uniqueIndex"`
-	Password  string    `gorm:"column:password;not null"`
-	Role      string    `gorm:"column:role;default:'customer'"`
-	AvatarURL *string   `gorm:"column:avatar_url"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	UserID    int64   `gorm:"column:user_id;primaryKey;autoIncrement"`
+	Name      string  `gorm:"column:name;not null"`
+	Email     *string `gorm:"column:email;uniqueIndex"`
+	Password  string  `gorm:"column:password;not null"`
+	Role      string  `gorm:"column:role;default:'customer'"`
+	AvatarURL *string `gorm:"column:avatar_url"`
+	// EmailVerifiedAt — когда пользователь подтвердил текущий email по ссылке.
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at"`
+	// SuspendedAt — учётная запись приостановлена администратором: вход и
+	// запросы отклоняются, пока её не восстановят.
+	SuspendedAt      *time.Time `gorm:"column:suspended_at"`
+	SuspensionReason *string    `gorm:"column:suspension_reason"`
+	// DeactivatedAt — учётная запись удалена: личные данные обезличены,
+	// брони и заявки остаются в истории.
+	DeactivatedAt *time.Time `gorm:"column:deactivated_at;index"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 
 	Companies []Company `gorm:"foreignKey:UserID"`
 	Bookings  []Booking `gorm:"foreignKey:UserID"`
 }
 
+// Active — пользователь может входить и работать.
+func (u *User) Active() bool {
+	return u.SuspendedAt == nil && u.DeactivatedAt == nil
+}
+
 func (User) TableName() string { return "user" }
 
 type Booking struct {
-	BookingID   int64     `gorm:"column:booking_id;primaryKey;autoIncrement"`
-	UserID      *int64    `gorm:"column:user_id;index"`
-	Description *string   `gorm:"column:description"`
-	Status      string    `gorm:"column:status;not null;default:'requested'"`
-	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	BookingID        int64      `gorm:"column:booking_id;primaryKey;autoIncrement"`
+	UserID           *int64     `gorm:"column:user_id;index"`
+	OrgID            *int64     `gorm:"column:org_id;index"`
+	Description      *string    `gorm:"column:description"`
+	Status           string     `gorm:"column:status;not null;default:'requested'"`
+	ScheduledStart   *time.Time `gorm:"column:scheduled_start;index"`
+	ScheduledEnd     *time.Time `gorm:"column:scheduled_end;index"`
+	SiteAddress      *string    `gorm:"column:site_address"`
+	SiteField        *string    `gorm:"column:site_field"`
+	SitePad          *string    `gorm:"column:site_pad"`
+	SiteWell         *string    `gorm:"column:site_well"`
+	SiteLatitude     *float64   `gorm:"column:site_latitude"`
+	SiteLongitude    *float64   `gorm:"column:site_longitude"`
+	SiteContactName  *string    `gorm:"column:site_contact_name"`
+	SiteContactPhone *string    `gorm:"column:site_contact_phone"`
+	Currency         string     `gorm:"column:currency;not null;default:'RUB'"`
+	Subtotal         *float64   `gorm:"column:subtotal"`
+	VATRate          *float64   `gorm:"column:vat_rate"`
+	Tax              *float64   `gorm:"column:tax"`
+	Total            *float64   `gorm:"column:total"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 
 	User            *User            `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	BookingServices []BookingService `gorm:"foreignKey:BookingID"`
@@ -82,6 +155,11 @@ type BookingService struct {
 	CompanyServiceID int64     `gorm:"column:company_service_id;not null;index"`
 	Notes            *string   `gorm:"column:notes"`
 	Quantity         *int      `gorm:"column:quantity;default:1"`
+	Status           string    `gorm:"column:status;not null;default:'requested';index"`
+	UnitPrice        *float64  `gorm:"column:unit_price"`
+	Currency         string    `gorm:"column:currency;not null;default:'RUB'"`
+	LineTotal        *float64  `gorm:"column:line_total"`
+	QuoteID          *int64    `gorm:"column:quote_id;index"`
 	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime"`
 
@@ -91,16 +169,83 @@ type BookingService struct {
 
 func (BookingService) TableName() string { return "booking_service" }
 
-type ServiceRequest struct {
-	RequestID   int64     `gorm:"column:request_id;primaryKey;autoIncrement" json:"request_id"`
-	UserID      int64     `gorm:"column:user_id;not null;index" json:"user_id"`
-	ServiceName string    `gorm:"column:service_name;not null" json:"service_name"`
-	Comment     *string   `gorm:"column:comment" json:"comment"`
-	Status      string    `gorm:"column:status;default:'pending'" json:"status"`
+type BookingStatusEvent struct {
+	EventID     int64     `gorm:"column:event_id;primaryKey;autoIncrement" json:"event_id"`
+	BookingID   int64     `gorm:"column:booking_id;not null;index" json:"booking_id"`
+	ActorUserID *int64    `gorm:"column:actor_user_id;index" json:"actor_user_id"`
+	ActorRole   string    `gorm:"column:actor_role;not null" json:"actor_role"`
+	CompanyID   *int64    `gorm:"column:company_id;index" json:"company_id"`
+	OldStatus   string    `gorm:"column:old_status;not null" json:"old_status"`
+	NewStatus   string    `gorm:"column:new_status;not null" json:"new_status"`
+	Reason      *string   `gorm:"column:reason" json:"reason"`
 	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 
-	User      User                     `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user,omitempty"`
-	Responses []ServiceRequestResponse `gorm:"foreignKey:RequestID" json:"responses,omitempty"`
+	Booking Booking  `gorm:"foreignKey:BookingID;references:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Actor   *User    `gorm:"foreignKey:ActorUserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+	Company *Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+}
+
+func (BookingStatusEvent) TableName() string { return "booking_status_event" }
+
+type BookingQuote struct {
+	QuoteID         int64      `gorm:"column:quote_id;primaryKey;autoIncrement" json:"quote_id"`
+	BookingID       int64      `gorm:"column:booking_id;not null;uniqueIndex:idx_booking_quote_version" json:"booking_id"`
+	CompanyID       int64      `gorm:"column:company_id;not null;uniqueIndex:idx_booking_quote_version" json:"company_id"`
+	Version         int        `gorm:"column:version;not null;uniqueIndex:idx_booking_quote_version" json:"version"`
+	Status          string     `gorm:"column:status;not null;default:'pending'" json:"status"`
+	Currency        string     `gorm:"column:currency;not null;default:'RUB'" json:"currency"`
+	Subtotal        float64    `gorm:"column:subtotal;not null" json:"subtotal"`
+	ValidUntil      time.Time  `gorm:"column:valid_until;not null" json:"valid_until"`
+	Terms           *string    `gorm:"column:terms" json:"terms"`
+	CreatedByUserID *int64     `gorm:"column:created_by_user_id" json:"created_by_user_id"`
+	DecidedAt       *time.Time `gorm:"column:decided_at" json:"decided_at"`
+	DecisionReason  *string    `gorm:"column:decision_reason" json:"decision_reason"`
+	CreatedAt       time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Booking   Booking            `gorm:"foreignKey:BookingID;references:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Company   Company            `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	CreatedBy *User              `gorm:"foreignKey:CreatedByUserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+	Lines     []BookingQuoteLine `gorm:"foreignKey:QuoteID" json:"lines"`
+}
+
+func (BookingQuote) TableName() string { return "booking_quote" }
+
+type BookingQuoteLine struct {
+	QuoteLineID      int64   `gorm:"column:quote_line_id;primaryKey;autoIncrement" json:"quote_line_id"`
+	QuoteID          int64   `gorm:"column:quote_id;not null;index" json:"quote_id"`
+	BookingServiceID int64   `gorm:"column:booking_service_id;not null;index" json:"booking_service_id"`
+	Quantity         int     `gorm:"column:quantity;not null" json:"quantity"`
+	UnitPrice        float64 `gorm:"column:unit_price;not null" json:"unit_price"`
+	LineTotal        float64 `gorm:"column:line_total;not null" json:"line_total"`
+
+	BookingService BookingService `gorm:"foreignKey:BookingServiceID;references:BookingServiceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (BookingQuoteLine) TableName() string { return "booking_quote_line" }
+
+type ServiceRequest struct {
+	RequestID        int64      `gorm:"column:request_id;primaryKey;autoIncrement" json:"request_id"`
+	UserID           int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	ServiceName      string     `gorm:"column:service_name;not null" json:"service_name"`
+	Category         *string    `gorm:"column:category" json:"category"`
+	Region           *string    `gorm:"column:region" json:"region"`
+	Comment          *string    `gorm:"column:comment" json:"comment"`
+	Scope            *string    `gorm:"column:scope" json:"scope"`
+	Quantity         int        `gorm:"column:quantity;not null;default:1" json:"quantity"`
+	SiteAddress      *string    `gorm:"column:site_address" json:"site_address"`
+	SiteField        *string    `gorm:"column:site_field" json:"site_field"`
+	SitePad          *string    `gorm:"column:site_pad" json:"site_pad"`
+	SiteWell         *string    `gorm:"column:site_well" json:"site_well"`
+	SiteLatitude     *float64   `gorm:"column:site_latitude" json:"site_latitude"`
+	SiteLongitude    *float64   `gorm:"column:site_longitude" json:"site_longitude"`
+	Deadline         *time.Time `gorm:"column:deadline" json:"deadline"`
+	Status           string     `gorm:"column:status;default:'pending'" json:"status"`
+	AwardedBookingID *int64     `gorm:"column:awarded_booking_id" json:"awarded_booking_id"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	User           User                     `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user,omitempty"`
+	Responses      []ServiceRequestResponse `gorm:"foreignKey:RequestID" json:"responses,omitempty"`
+	AwardedBooking *Booking                 `gorm:"foreignKey:AwardedBookingID;references:BookingID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
 }
 
 func (ServiceRequest) TableName() string { return "service_request" }
@@ -121,13 +266,61 @@ type Notification struct {
 
 func (Notification) TableName() string { return "notification" }
 
+const (
+	NotificationActionNone         = ""
+	NotificationActionAddService   = "add_service"
+	NotificationActionServiceAdded = "service_added"
+	// RequestID — заявка: компании — приглашение прислать предложение,
+	// заказчику — полученное предложение.
+	NotificationActionSubmitBid   = "submit_bid"
+	NotificationActionBidReceived = "bid_received"
+	// ActionData — id компании: администратору — ждущей проверки, владельцу —
+	// получившей решение модератора.
+	NotificationActionCompanyReview       = "company_review"
+	NotificationActionCompanyVerification = "company_verification"
+)
+
+var NotificationActionTypes = map[string]bool{
+	NotificationActionNone:         true,
+	NotificationActionAddService:   true,
+	NotificationActionServiceAdded: true,
+	NotificationActionSubmitBid:    true,
+	NotificationActionBidReceived:  true,
+
+	NotificationActionCompanyReview:       true,
+	NotificationActionCompanyVerification: true,
+}
+
 type ServiceRequestResponse struct {
-	ResponseID int64     `gorm:"column:response_id;primaryKey;autoIncrement" json:"response_id"`
-	RequestID  int64     `gorm:"column:request_id;not null;index" json:"request_id"`
-	CompanyID  int64     `gorm:"column:company_id;not null" json:"company_id"`
-	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	ResponseID       int64     `gorm:"column:response_id;primaryKey;autoIncrement" json:"response_id"`
+	RequestID        int64     `gorm:"column:request_id;not null;index" json:"request_id"`
+	CompanyID        int64     `gorm:"column:company_id;not null" json:"company_id"`
+	CompanyServiceID *int64    `gorm:"column:company_service_id" json:"company_service_id"`
+	Price            *float64  `gorm:"column:price" json:"price"`
+	Currency         string    `gorm:"column:currency;not null;default:'RUB'" json:"currency"`
+	LeadTimeDays     *int      `gorm:"column:lead_time_days" json:"lead_time_days"`
+	Comment          *string   `gorm:"column:comment" json:"comment"`
+	Status           string    `gorm:"column:status;not null;default:'submitted'" json:"status"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 
-	Company Company `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"company,omitempty"`
+	Company        Company         `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"company,omitempty"`
+	CompanyService *CompanyService `gorm:"foreignKey:CompanyServiceID;references:CompanyServiceID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
 }
 
 func (ServiceRequestResponse) TableName() string { return "service_request_response" }
+
+// ServiceRequestInvitation — компания, которой разослана заявка. По доле
+// приглашений с откликом оценивается отзывчивость компании.
+type ServiceRequestInvitation struct {
+	InvitationID int64     `gorm:"column:invitation_id;primaryKey;autoIncrement" json:"invitation_id"`
+	RequestID    int64     `gorm:"column:request_id;not null;uniqueIndex:idx_invitation_request_company" json:"request_id"`
+	CompanyID    int64     `gorm:"column:company_id;not null;uniqueIndex:idx_invitation_request_company;index" json:"company_id"`
+	Score        float64   `gorm:"column:score;not null" json:"score"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Request ServiceRequest `gorm:"foreignKey:RequestID;references:RequestID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Company Company        `gorm:"foreignKey:CompanyID;references:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (ServiceRequestInvitation) TableName() string { return "service_request_invitation" }
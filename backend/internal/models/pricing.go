@@ -0,0 +1,90 @@
+package models
+
+import "math"
+
+const DefaultCurrency = "RUB"
+
+var vatRate = 0.22
+
+// SetVATRate задаёт ставку НДС, по которой считается налог в новых бронях.
+func SetVATRate(rate float64) {
+	vatRate = rate
+}
+
+func VATRate() float64 {
+	return vatRate
+}
+
+// PriceSnapshot возвращает цену и валюту, фиксируемые в строке брони:
+// цена компании, а если она не задана — базовая цена услуги из каталога.
+// nil означает «цена по запросу».
+func PriceSnapshot(cs *CompanyService) (*float64, string) {
+	currency := cs.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if cs.Price != nil {
+		p := *cs.Price
+		return &p, currency
+	}
+	if cs.Service.Price != nil {
+		p := *cs.Service.Price
+		return &p, currency
+	}
+	return nil, currency
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ComputeLineTotal считает сумму строки; nil, если цена не зафиксирована.
+func (bs *BookingService) ComputeLineTotal() *float64 {
+	if bs.UnitPrice == nil {
+		return nil
+	}
+	qty := 1
+	if bs.Quantity != nil {
+		qty = *bs.Quantity
+	}
+	total := roundMoney(*bs.UnitPrice * float64(qty))
+	return &total
+}
+
+// ApplyTotals пересчитывает line_total строк и subtotal/tax/total брони.
+// Ставка НДС фиксируется в брони при первом расчёте. Если хотя бы у одной
+// строки нет цены или валюты строк различаются, итоги брони обнуляются.
+func (b *Booking) ApplyTotals(lines []BookingService) {
+	if b.VATRate == nil {
+		rate := vatRate
+		b.VATRate = &rate
+	}
+	for i := range lines {
+		lines[i].LineTotal = lines[i].ComputeLineTotal()
+	}
+
+	b.Subtotal, b.Tax, b.Total = nil, nil, nil
+	b.Currency = DefaultCurrency
+	if len(lines) == 0 {
+		return
+	}
+
+	subtotal := 0.0
+	for i, bs := range lines {
+		lt := bs.LineTotal
+		if lt == nil {
+			return
+		}
+		if i == 0 {
+			b.Currency = bs.Currency
+		} else if bs.Currency != b.Currency {
+			return
+		}
+		subtotal += *lt
+	}
+
+	subtotal = roundMoney(subtotal)
+	tax := roundMoney(subtotal * *b.VATRate)
+	total := roundMoney(subtotal + tax)
+	b.Subtotal, b.Tax, b.Total = &subtotal, &tax, &total
+}
@@ -0,0 +1,112 @@
+package models
+
+import "testing"
+
+func price(v float64) *float64 { return &v }
+
+func qty(n int) *int { return &n }
+
+func TestComputeLineTotal(t *testing.T) {
+	tests := []struct {
+		name string
+		line BookingService
+		want *float64
+	}{
+		{"no price", BookingService{Quantity: qty(3)}, nil},
+		{"default quantity", BookingService{UnitPrice: price(100)}, price(100)},
+		{"quantity", BookingService{UnitPrice: price(10.005), Quantity: qty(3)}, price(30.02)},
+	}
+	for _, tt := range tests {
+		got := tt.line.ComputeLineTotal()
+		if !equalMoney(got, tt.want) {
+			t.Errorf("%s: ComputeLineTotal = %v, want %v", tt.name, fmtMoney(got), fmtMoney(tt.want))
+		}
+	}
+}
+
+func TestApplyTotals(t *testing.T) {
+	defer SetVATRate(VATRate())
+	SetVATRate(0.2)
+
+	tests := []struct {
+		name                 string
+		vat                  *float64
+		lines                []BookingService
+		subtotal, tax, total *float64
+		currency             string
+	}{
+		{
+			name:     "no lines",
+			currency: DefaultCurrency,
+		},
+		{
+			name: "priced lines",
+			lines: []BookingService{
+				{UnitPrice: price(100), Quantity: qty(2), Currency: "RUB"},
+				{UnitPrice: price(50.5), Currency: "RUB"},
+			},
+			subtotal: price(250.5), tax: price(50.1), total: price(300.6), currency: "RUB",
+		},
+		{
+			name:     "fixed vat rate is kept",
+			vat:      price(0.1),
+			lines:    []BookingService{{UnitPrice: price(100), Currency: "USD"}},
+			subtotal: price(100), tax: price(10), total: price(110), currency: "USD",
+		},
+		{
+			name: "line without price",
+			lines: []BookingService{
+				{UnitPrice: price(100), Currency: "RUB"},
+				{Currency: "RUB"},
+			},
+			currency: "RUB",
+		},
+		{
+			name: "mixed currencies",
+			lines: []BookingService{
+				{UnitPrice: price(100), Currency: "RUB"},
+				{UnitPrice: price(10), Currency: "USD"},
+			},
+			currency: "RUB",
+		},
+	}
+	for _, tt := range tests {
+		b := Booking{VATRate: tt.vat, Subtotal: price(1), Tax: price(1), Total: price(1)}
+		b.ApplyTotals(tt.lines)
+
+		wantVAT := 0.2
+		if tt.vat != nil {
+			wantVAT = *tt.vat
+		}
+		if b.VATRate == nil || *b.VATRate != wantVAT {
+			t.Errorf("%s: VATRate = %v, want %v", tt.name, fmtMoney(b.VATRate), wantVAT)
+		}
+		if !equalMoney(b.Subtotal, tt.subtotal) || !equalMoney(b.Tax, tt.tax) || !equalMoney(b.Total, tt.total) {
+			t.Errorf("%s: totals = %v/%v/%v, want %v/%v/%v", tt.name,
+				fmtMoney(b.Subtotal), fmtMoney(b.Tax), fmtMoney(b.Total),
+				fmtMoney(tt.subtotal), fmtMoney(tt.tax), fmtMoney(tt.total))
+		}
+		if b.Currency != tt.currency {
+			t.Errorf("%s: Currency = %q, want %q", tt.name, b.Currency, tt.currency)
+		}
+		for i, bs := range tt.lines {
+			if !equalMoney(bs.LineTotal, bs.ComputeLineTotal()) {
+				t.Errorf("%s: line %d LineTotal = %v", tt.name, i, fmtMoney(bs.LineTotal))
+			}
+		}
+	}
+}
+
+func equalMoney(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func fmtMoney(v *float64) interface{} {
+	if v == nil {
+		return "nil"
+	}
+	return *v
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Статусы запроса на удаление персональных данных.
+const (
+	ErasurePending   = "pending"
+	ErasureCompleted = "completed"
+	ErasureCancelled = "cancelled"
+)
+
+// ErasureRequest — запрос пользователя на удаление персональных данных
+// (152-ФЗ). До ScheduledFor запрос можно отменить, после него фоновая задача
+// обезличивает учётную запись. LastError — причина, по которой последняя
+// попытка не удалась (например, пользователь ещё владеет компанией); задача
+// повторит её при следующем запуске.
+type ErasureRequest struct {
+	RequestID    int64      `gorm:"column:request_id;primaryKey;autoIncrement" json:"request_id"`
+	UserID       int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	Status       string     `gorm:"column:status;not null;default:'pending';index" json:"status"`
+	ScheduledFor time.Time  `gorm:"column:scheduled_for;not null" json:"scheduled_for"`
+	LastError    *string    `gorm:"column:last_error" json:"last_error,omitempty"`
+	CompletedAt  *time.Time `gorm:"column:completed_at" json:"completed_at,omitempty"`
+	CancelledAt  *time.Time `gorm:"column:cancelled_at" json:"cancelled_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (ErasureRequest) TableName() string { return "erasure_request" }
@@ -0,0 +1,9 @@
+package models
+
+const (
+	QuoteStatusPending    = "pending"
+	QuoteStatusAccepted   = "accepted"
+	QuoteStatusRejected   = "rejected"
+	QuoteStatusSuperseded = "superseded"
+	QuoteStatusExpired    = "expired"
+)
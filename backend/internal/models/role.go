@@ -0,0 +1,125 @@
+package models
+
+const (
+	RoleCustomer          = "customer"
+	RoleCompanyOwner      = "company_owner"
+	RoleCompanyDispatcher = "company_dispatcher"
+	RoleAdmin             = "admin"
+	RoleAuditor           = "auditor"
+)
+
+// Permission — право на действие. Middleware проверяет его по роли из токена;
+// доступ к конкретному объекту (своя компания, своя бронь) проверяет policy.
+type Permission string
+
+const (
+	// PermAccount — свой профиль, уведомления и списки «мои …».
+	PermAccount Permission = "account"
+
+	PermCatalogRead   Permission = "catalog:read"
+	PermCatalogWrite  Permission = "catalog:write"
+	PermCatalogManage Permission = "catalog:manage"
+
+	// PermBookingsRead — история и предложения по броням, к которым
+	// пользователь причастен как заказчик или исполнитель.
+	PermBookingsRead      Permission = "bookings:read"
+	PermBookingsCreate    Permission = "bookings:create"
+	PermBookingsReadAll   Permission = "bookings:read_all"
+	PermBookingsManageAll Permission = "bookings:manage_all"
+
+	PermCompaniesCreate Permission = "companies:create"
+	// PermCompaniesManage — профиль своей компании, её услуги и расписание.
+	PermCompaniesManage Permission = "companies:manage"
+	// PermCompaniesOperate — работа с входящими бронями и заявками компании.
+	PermCompaniesOperate   Permission = "companies:operate"
+	PermCompaniesManageAll Permission = "companies:manage_all"
+
+	PermRequestsCreate  Permission = "requests:create"
+	PermRequestsRead    Permission = "requests:read"
+	PermRequestsReadAll Permission = "requests:read_all"
+	PermRequestsManage  Permission = "requests:manage"
+
+	PermUsersRead   Permission = "users:read"
+	PermUsersManage Permission = "users:manage"
+	// PermUsersImpersonate — вход от имени пользователя только для чтения.
+	PermUsersImpersonate Permission = "users:impersonate"
+	PermReportsRead      Permission = "reports:read"
+)
+
+var customerPermissions = []Permission{
+	PermAccount,
+	PermCatalogRead,
+	PermBookingsRead,
+	PermBookingsCreate,
+	PermCompaniesCreate,
+	PermRequestsCreate,
+	PermRequestsRead,
+}
+
+var rolePermissions = map[string]map[Permission]bool{
+	RoleCustomer: permissionSet(customerPermissions...),
+	RoleCompanyOwner: permissionSet(append(customerPermissions,
+		PermCatalogWrite,
+		PermCompaniesManage,
+		PermCompaniesOperate,
+	)...),
+	RoleCompanyDispatcher: permissionSet(
+		PermAccount,
+		PermCatalogRead,
+		PermBookingsRead,
+		PermCompaniesOperate,
+	),
+	RoleAuditor: permissionSet(
+		PermAccount,
+		PermCatalogRead,
+		PermBookingsRead,
+		PermBookingsReadAll,
+		PermRequestsRead,
+		PermRequestsReadAll,
+		PermUsersRead,
+		PermReportsRead,
+	),
+}
+
+func permissionSet(perms ...Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
+
+// RoleRequiresMFA — роли, которым вход без второго фактора не разрешён.
+func RoleRequiresMFA(role string) bool {
+	return role == RoleAdmin
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || rolePermissions[role] != nil
+}
+
+// RoleHasPermission — администратору разрешено всё, остальным — по таблице.
+func RoleHasPermission(role string, perm Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	return rolePermissions[role][perm]
+}
+
+// RolePermissions возвращает права роли в стабильном порядке.
+func RolePermissions(role string) []Permission {
+	all := []Permission{
+		PermAccount, PermCatalogRead, PermCatalogWrite, PermCatalogManage,
+		PermBookingsRead, PermBookingsCreate, PermBookingsReadAll, PermBookingsManageAll,
+		PermCompaniesCreate, PermCompaniesManage, PermCompaniesOperate, PermCompaniesManageAll,
+		PermRequestsCreate, PermRequestsRead, PermRequestsReadAll, PermRequestsManage,
+		PermUsersRead, PermUsersManage, PermUsersImpersonate, PermReportsRead,
+	}
+	perms := []Permission{}
+	for _, p := range all {
+		if RoleHasPermission(role, p) {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
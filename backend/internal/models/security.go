@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Области счётчиков неудачных входов.
+const (
+	LoginScopeIP      = "ip"
+	LoginScopeAccount = "account"
+)
+
+// LoginAttemptCounter — неудачные попытки входа с одного IP или в одну учётную
+// запись (Key — IP или email в нижнем регистре). BlockedUntil — пауза после
+// очередной неудачи, растущая экспоненциально; LockedUntil — временная
+// блокировка после слишком многих неудач.
+type LoginAttemptCounter struct {
+	CounterID     int64      `gorm:"column:counter_id;primaryKey;autoIncrement" json:"counter_id"`
+	Scope         string     `gorm:"column:scope;not null;uniqueIndex:idx_login_counter_key" json:"scope"`
+	Key           string     `gorm:"column:key;not null;uniqueIndex:idx_login_counter_key" json:"key"`
+	Failures      int        `gorm:"column:failures;not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"column:last_failure_at;not null" json:"last_failure_at"`
+	BlockedUntil  *time.Time `gorm:"column:blocked_until" json:"blocked_until,omitempty"`
+	LockedUntil   *time.Time `gorm:"column:locked_until;index" json:"locked_until,omitempty"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (LoginAttemptCounter) TableName() string { return "login_attempt_counter" }
+
+// Типы событий журнала безопасности.
+const (
+	SecurityEventLoginSucceeded   = "login_succeeded"
+	SecurityEventLoginFailed      = "login_failed"
+	SecurityEventLoginThrottled   = "login_throttled"
+	SecurityEventAccountLocked    = "account_locked"
+	SecurityEventAccountUnlocked  = "account_unlocked"
+	SecurityEventPasswordChanged  = "password_changed"
+	SecurityEventPasswordReset    = "password_reset"
+	SecurityEventMFAChallenged    = "2fa_challenged"
+	SecurityEventMFAEnabled       = "2fa_enabled"
+	SecurityEventMFADisabled      = "2fa_disabled"
+	SecurityEventMFAFailed        = "2fa_failed"
+	SecurityEventMFAReset         = "2fa_reset"
+	SecurityEventRecoveryUsed     = "2fa_recovery_code_used"
+	SecurityEventAPIKeyCreated    = "api_key_created"
+	SecurityEventAPIKeyRevoked    = "api_key_revoked"
+	SecurityEventRoleChanged      = "role_changed"
+	SecurityEventSuspended        = "account_suspended"
+	SecurityEventReactivated      = "account_reactivated"
+	SecurityEventDeactivated      = "account_deactivated"
+	SecurityEventOwnerChanged     = "company_owner_changed"
+	SecurityEventDataExported     = "personal_data_exported"
+	SecurityEventErasureRequest   = "erasure_requested"
+	SecurityEventErasureCancel    = "erasure_cancelled"
+	SecurityEventErased           = "personal_data_erased"
+	SecurityEventImpersonation    = "impersonation_started"
+	SecurityEventImpersonationEnd = "impersonation_ended"
+	SecurityEventSessionRevoked   = "session_revoked"
+	SecurityEventSessionsRevoked  = "sessions_revoked"
+)
+
+// SecurityEvent — запись журнала безопасности. UserID пуст, если вход был под
+// неизвестным email; ActorUserID — администратор, выполнивший действие.
+type SecurityEvent struct {
+	EventID     int64     `gorm:"column:event_id;primaryKey;autoIncrement" json:"event_id"`
+	Type        string    `gorm:"column:type;not null;index" json:"type"`
+	UserID      *int64    `gorm:"column:user_id;index" json:"user_id,omitempty"`
+	Email       *string   `gorm:"column:email;index" json:"email,omitempty"`
+	IP          string    `gorm:"column:ip;not null;index" json:"ip"`
+	UserAgent   *string   `gorm:"column:user_agent" json:"user_agent,omitempty"`
+	ActorUserID *int64    `gorm:"column:actor_user_id" json:"actor_user_id,omitempty"`
+	Detail      *string   `gorm:"column:detail" json:"detail,omitempty"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+}
+
+func (SecurityEvent) TableName() string { return "security_event" }
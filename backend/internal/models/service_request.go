@@ -0,0 +1,27 @@
+package models
+
+const (
+	ServiceRequestStatusPending  = "pending"
+	ServiceRequestStatusReviewed = "reviewed"
+	// ServiceRequestStatusAwarded — заказчик выбрал предложение, заявка
+	// превращена в бронь и больше не принимает предложений.
+	ServiceRequestStatusAwarded = "awarded"
+	// ServiceRequestStatusAwardPending — предложение выбрано, но бронь ждёт
+	// внутреннего согласования у заказчика. Новых предложений заявка не
+	// принимает; при отказе согласующего снова открывается.
+	ServiceRequestStatusAwardPending = "award_pending"
+)
+
+const (
+	BidStatusSubmitted = "submitted"
+	BidStatusAwarded   = "awarded"
+	BidStatusLost      = "lost"
+	// BidStatusAwardPending — выбранное предложение, бронь по которому ещё
+	// не согласована у заказчика.
+	BidStatusAwardPending = "award_pending"
+)
+
+// ServiceRequestOpen сообщает, принимает ли заявка предложения компаний.
+func ServiceRequestOpen(status string) bool {
+	return status == ServiceRequestStatusPending || status == ServiceRequestStatusReviewed
+}
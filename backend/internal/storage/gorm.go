@@ -34,24 +34,109 @@ func NewGorm(dsn string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("gorm.Open: %w", err)
 	}
 
+	// До появления статуса строк он хранился только в брони; при первой
+	// миграции строки наследуют статус своей брони.
+	backfillLineStatus := gormDB.Migrator().HasTable(&models.BookingService{}) &&
+		!gormDB.Migrator().HasColumn(&models.BookingService{}, "status")
+	// Компании, созданные до модерации, уже видны заказчикам — считаем их
+	// проверенными.
+	backfillVerification := gormDB.Migrator().HasTable(&models.Company{}) &&
+		!gormDB.Migrator().HasColumn(&models.Company{}, "verification_status")
+
 	if err := gormDB.AutoMigrate(
 		&models.User{},
+		&models.RefreshToken{},
+		&models.UserSession{},
+		&models.RevokedAccessToken{},
+		&models.UserToken{},
+		&models.LoginAttemptCounter{},
+		&models.SecurityEvent{},
+		&models.UserTOTP{},
+		&models.RecoveryCode{},
+		&models.MFAChallenge{},
+		&models.ErasureRequest{},
+		&models.ImpersonationSession{},
+		&models.ImpersonationRequest{},
+		&models.CustomerOrg{},
+		&models.CustomerOrgMember{},
+		&models.CustomerOrgInvitation{},
 		&models.Company{},
+		&models.CompanyMember{},
+		&models.CompanyInvitation{},
+		&models.APIKey{},
 		&models.Service{},
+		&models.CompanyRegion{},
+		&models.CompanyRequestOptOut{},
 		&models.CompanyService{},
+		&models.CompanyServiceBlackout{},
 		&models.Booking{},
 		&models.BookingService{},
+		&models.BookingStatusEvent{},
+		&models.BookingApproval{},
+		&models.BookingQuote{},
+		&models.BookingQuoteLine{},
 		&models.ServiceRequest{},
 		&models.Notification{},
 		&models.ServiceRequestResponse{},
+		&models.ServiceRequestInvitation{},
 	); err != nil {
 		sqlDB.Close()
 		return nil, fmt.Errorf("automigrate: %w", err)
 	}
 
+	if backfillLineStatus {
+		if err := gormDB.Exec(`UPDATE booking_service SET status =
+			(SELECT booking.status FROM booking WHERE booking.booking_id = booking_service.booking_id)`).Error; err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("backfill booking_service.status: %w", err)
+		}
+	}
+	if backfillVerification {
+		if err := gormDB.Exec(`UPDATE company SET verification_status = ?`, models.CompanyVerificationOK).Error; err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("backfill company.verification_status: %w", err)
+		}
+	}
+
 	if err := Seed(gormDB); err != nil {
 		return nil, fmt.Errorf("seed data: %w", err)
 	}
 
+	if err := backfillOwnerRoles(gormDB); err != nil {
+		return nil, fmt.Errorf("backfill company_owner roles: %w", err)
+	}
+
+	if err := backfillOwnerMembers(gormDB); err != nil {
+		return nil, fmt.Errorf("backfill company_member owners: %w", err)
+	}
+
 	return gormDB, nil
 }
+
+// backfillOwnerRoles выдаёт роль company_owner владельцам компаний, которые
+// до появления ролей числились заказчиками. Выполняется, пока в базе нет ни
+// одного пользователя с новыми ролями, то есть фактически один раз.
+func backfillOwnerRoles(db *gorm.DB) error {
+	var migrated int64
+	if err := db.Model(&models.User{}).
+		Where("role IN ?", []string{models.RoleCompanyOwner, models.RoleCompanyDispatcher, models.RoleAuditor}).
+		Count(&migrated).Error; err != nil {
+		return err
+	}
+	if migrated > 0 {
+		return nil
+	}
+	return db.Model(&models.User{}).
+		Where("role = ? AND user_id IN (?)", models.RoleCustomer, db.Model(&models.Company{}).Select("user_id")).
+		Update("role", models.RoleCompanyOwner).Error
+}
+
+// backfillOwnerMembers добавляет владельца каждой компании (company.user_id)
+// в company_member с ролью owner, если его там нет.
+func backfillOwnerMembers(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO company_member (company_id, user_id, role, created_at)
+		SELECT c.company_id, c.user_id, ?, c.created_at FROM company c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM company_member m WHERE m.company_id = c.company_id AND m.user_id = c.user_id
+		)`, models.MemberRoleOwner).Error
+}
@@ -83,45 +83,46 @@ func Seed(db *gorm.DB) error {
 	}
 
 	type svcDef struct {
-		title string
-		desc  string
-		price float64
+		title    string
+		desc     string
+		price    float64
+		category string
 	}
 
 	svcDefs := []svcDef{
-		{"Бурение вертикальных скважин", "Строительство вертикальных нефтяных и газовых скважин глубиной до 5000 м", 450000},
-		{"Бурение наклонно-направленных скважин (ННС)", "Строительство ННС с отклонением до 60° и глубиной до 4500 м", 580000},
-		{"Бурение горизонтальных скважин (ГС)", "ГС с горизонтальным участком до 1500 м, в т.ч. многозабойные", 720000},
-		{"Зарезка боковых стволов (ЗБС)", "Восстановление бездействующего фонда методом зарезки и бурения бокового ствола", 380000},
-		{"Геологоразведочное бурение", "Параметрическое и поисково-разведочное бурение для изучения новых горизонтов", 520000},
-		{"Капитальный ремонт скважин (КРС)", "КРС с применением подъёмных агрегатов А-60/80: изоляция, ОПЗ, ликвидация аварий", 280000},
-		{"Текущий ремонт скважин (ТРС)", "Плановый ТРС: смена насоса, чистка ЭЦН, замена НКТ", 95000},
-		{"Гидравлический разрыв пласта (ГРП)", "Одно- и многостадийный ГРП для интенсификации добычи из низкопроницаемых коллекторов", 8500000},
-		{"Кислотная обработка пласта (КО)", "Соляно-кислотная (СКО) и глинокислотная (ГКО) обработка для восстановления ПЗП", 1200000},
-		{"Сейсморазведка 2D", "Региональные и детальные сейсмические профили для картирования структур", 180000},
-		{"Сейсморазведка 3D", "Площадная съёмка для детализации геологической модели залежи", 420000},
-		{"Геофизические исследования скважин (ГИС)", "Комплексный каротаж: ГК, НКТ, АК, ИК, БК, ПС, кавернометрия", 320000},
-		{"Каротаж в процессе бурения (LWD/MWD)", "Навигация и геонавигация в режиме реального времени при бурении", 45000},
-		{"Интерпретация геофизических данных", "Петрофизическая интерпретация, подсчёт запасов, построение геологических моделей", 280000},
-		{"Строительство промысловых трубопроводов", "Строительство нефте- и газопроводов, водоводов, систем ППД диаметром до 530 мм", 850000},
-		{"Капитальный ремонт трубопроводов", "Переизоляция, врезка катушек, замена участков трубопровода", 120000},
-		{"Внутритрубная диагностика (ВТД)", "Пропуск МФИ- и УЗД-снарядов для выявления дефектов металла и сварных швов", 95000},
-		{"Проектирование СПГ-установок", "Разработка ТЭО и ПД для малотоннажных и крупнотоннажных СПГ-заводов", 12000000},
-		{"Строительство СПГ-терминалов", "Инжиниринг, поставка оборудования и строительство объектов сжижения и хранения СПГ", 95000000},
-		{"Обслуживание СПГ-оборудования", "ТО крио-насосов, испарителей и систем хранения СПГ", 380000},
-		{"Разработка и внедрение АСУ ТП", "Проектирование и ввод в эксплуатацию систем автоматизации технологических процессов", 4500000},
-		{"SCADA-системы", "Диспетчерское управление и сбор данных для объектов добычи и транспорта", 2800000},
-		{"Системы телеметрии скважин", "Онлайн-мониторинг дебита, давления, температуры и динамических уровней", 180000},
-		{"Электромонтаж и КИПиА", "Монтаж силового электрооборудования, приборов и средств автоматизации", 220000},
-		{"Пусконаладочные работы (ПНР)", "Наладка, испытания и ввод в эксплуатацию технологического оборудования", 150000},
-		{"Экологический мониторинг", "Периодический контроль состояния атмосферного воздуха, почвы и водных объектов", 45000},
-		{"Рекультивация загрязнённых земель", "Ликвидация разливов нефтепродуктов, биологическая и техническая рекультивация", 220000},
-		{"Оценка воздействия на окружающую среду (ОВОС)", "Разработка ОВОС и раздела ООС в составе ПД для объектов НГК", 380000},
-		{"Экспертиза промышленной безопасности (ПБ)", "Техническое диагностирование, экспертиза ПБ технических устройств и сооружений", 95000},
-		{"Обучение и аттестация персонала", "Курсы по ПБ, охране труда, пожарной безопасности, аттестация в Ростехнадзоре", 12000},
-		{"Технологическое проектирование", "Разработка ПД и РД для объектов добычи, подготовки и транспорта нефти и газа", 3500000},
-		{"Проектирование кустовых площадок", "Обустройство кустов скважин, ДНС, КНС и промысловой инфраструктуры", 1200000},
-		{"Авторский надзор", "Технический надзор заказчика и авторский надзор проектировщика при строительстве", 85000},
+		{"Бурение вертикальных скважин", "Строительство вертикальных нефтяных и газовых скважин глубиной до 5000 м", 450000, "drilling"},
+		{"Бурение наклонно-направленных скважин (ННС)", "Строительство ННС с отклонением до 60° и глубиной до 4500 м", 580000, "drilling"},
+		{"Бурение горизонтальных скважин (ГС)", "ГС с горизонтальным участком до 1500 м, в т.ч. многозабойные", 720000, "drilling"},
+		{"Зарезка боковых стволов (ЗБС)", "Восстановление бездействующего фонда методом зарезки и бурения бокового ствола", 380000, "drilling"},
+		{"Геологоразведочное бурение", "Параметрическое и поисково-разведочное бурение для изучения новых горизонтов", 520000, "drilling"},
+		{"Капитальный ремонт скважин (КРС)", "КРС с применением подъёмных агрегатов А-60/80: изоляция, ОПЗ, ликвидация аварий", 280000, "well_workover"},
+		{"Текущий ремонт скважин (ТРС)", "Плановый ТРС: смена насоса, чистка ЭЦН, замена НКТ", 95000, "well_workover"},
+		{"Гидравлический разрыв пласта (ГРП)", "Одно- и многостадийный ГРП для интенсификации добычи из низкопроницаемых коллекторов", 8500000, "stimulation"},
+		{"Кислотная обработка пласта (КО)", "Соляно-кислотная (СКО) и глинокислотная (ГКО) обработка для восстановления ПЗП", 1200000, "stimulation"},
+		{"Сейсморазведка 2D", "Региональные и детальные сейсмические профили для картирования структур", 180000, "seismic"},
+		{"Сейсморазведка 3D", "Площадная съёмка для детализации геологической модели залежи", 420000, "seismic"},
+		{"Геофизические исследования скважин (ГИС)", "Комплексный каротаж: ГК, НКТ, АК, ИК, БК, ПС, кавернометрия", 320000, "well_logging"},
+		{"Каротаж в процессе бурения (LWD/MWD)", "Навигация и геонавигация в режиме реального времени при бурении", 45000, "well_logging"},
+		{"Интерпретация геофизических данных", "Петрофизическая интерпретация, подсчёт запасов, построение геологических моделей", 280000, "well_logging"},
+		{"Строительство промысловых трубопроводов", "Строительство нефте- и газопроводов, водоводов, систем ППД диаметром до 530 мм", 850000, "pipelines"},
+		{"Капитальный ремонт трубопроводов", "Переизоляция, врезка катушек, замена участков трубопровода", 120000, "pipelines"},
+		{"Внутритрубная диагностика (ВТД)", "Пропуск МФИ- и УЗД-снарядов для выявления дефектов металла и сварных швов", 95000, "pipelines"},
+		{"Проектирование СПГ-установок", "Разработка ТЭО и ПД для малотоннажных и крупнотоннажных СПГ-заводов", 12000000, "lng"},
+		{"Строительство СПГ-терминалов", "Инжиниринг, поставка оборудования и строительство объектов сжижения и хранения СПГ", 95000000, "lng"},
+		{"Обслуживание СПГ-оборудования", "ТО крио-насосов, испарителей и систем хранения СПГ", 380000, "lng"},
+		{"Разработка и внедрение АСУ ТП", "Проектирование и ввод в эксплуатацию систем автоматизации технологических процессов", 4500000, "automation"},
+		{"SCADA-системы", "Диспетчерское управление и сбор данных для объектов добычи и транспорта", 2800000, "automation"},
+		{"Системы телеметрии скважин", "Онлайн-мониторинг дебита, давления, температуры и динамических уровней", 180000, "automation"},
+		{"Электромонтаж и КИПиА", "Монтаж силового электрооборудования, приборов и средств автоматизации", 220000, "automation"},
+		{"Пусконаладочные работы (ПНР)", "Наладка, испытания и ввод в эксплуатацию технологического оборудования", 150000, "automation"},
+		{"Экологический мониторинг", "Периодический контроль состояния атмосферного воздуха, почвы и водных объектов", 45000, "ecology"},
+		{"Рекультивация загрязнённых земель", "Ликвидация разливов нефтепродуктов, биологическая и техническая рекультивация", 220000, "ecology"},
+		{"Оценка воздействия на окружающую среду (ОВОС)", "Разработка ОВОС и раздела ООС в составе ПД для объектов НГК", 380000, "ecology"},
+		{"Экспертиза промышленной безопасности (ПБ)", "Техническое диагностирование, экспертиза ПБ технических устройств и сооружений", 95000, "industrial_safety"},
+		{"Обучение и аттестация персонала", "Курсы по ПБ, охране труда, пожарной безопасности, аттестация в Ростехнадзоре", 12000, "industrial_safety"},
+		{"Технологическое проектирование", "Разработка ПД и РД для объектов добычи, подготовки и транспорта нефти и газа", 3500000, "engineering"},
+		{"Проектирование кустовых площадок", "Обустройство кустов скважин, ДНС, КНС и промысловой инфраструктуры", 1200000, "engineering"},
+		{"Авторский надзор", "Технический надзор заказчика и авторский надзор проектировщика при строительстве", 85000, "engineering"},
 	}
 
 	services := make([]models.Service, 0, len(svcDefs))
@@ -130,6 +131,7 @@ func Seed(db *gorm.DB) error {
 			Title:       d.title,
 			Description: strPtr(d.desc),
 			Price:       floatPtr(d.price),
+			Category:    strPtr(d.category),
 		}
 		if err := db.Create(&s).Error; err != nil {
 			return fmt.Errorf("создание услуги: %w", err)
@@ -147,6 +149,7 @@ func Seed(db *gorm.DB) error {
 		name       string
 		address    string
 		desc       string
+		regions    []string
 		svcTitles  []string
 	}
 
@@ -156,6 +159,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО «Роснефть»",
 			address:    "г. Москва, Софийская набережная, 26/1",
 			desc:       "Лидер российской нефтяной отрасли — на долю компании приходится около 40 % добычи нефти в РФ. Ведёт деятельность в 50 регионах России и 20 странах мира. Крупнейшие активы — Юганскнефтегаз, Самотлорнефтегаз, Ванкорнефть.",
+			regions:    []string{"ХМАО", "ЯНАО", "Красноярский край"},
 			svcTitles: []string{
 				"Бурение вертикальных скважин",
 				"Бурение наклонно-направленных скважин (ННС)",
@@ -172,6 +176,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО «ЛУКОЙЛ»",
 			address:    "г. Москва, Сретенский бульвар, 11",
 			desc:       "Крупнейшая частная нефтяная компания России с долей около 16,3 % нефтедобычи. Ведёт добычу в Западной Сибири, Тимано-Печоре, Поволжье, а также за рубежом. Полностью интегрированная цепочка — от разведки до розничных продаж.",
+			regions:    []string{"ХМАО", "Республика Коми", "НАО"},
 			svcTitles: []string{
 				"Бурение вертикальных скважин",
 				"Капитальный ремонт скважин (КРС)",
@@ -189,6 +194,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО «Газпром»",
 			address:    "г. Санкт-Петербург, Лахтинская набережная, 2",
 			desc:       "Мировой лидер по запасам и добыче природного газа — около 72 % разведанных запасов газа в России. Управляет Единой системой газоснабжения протяжённостью 175 000 км. Нефтяной бизнес ведёт через дочернюю «Газпром нефть».",
+			regions:    []string{"ЯНАО", "Оренбургская область", "Астраханская область"},
 			svcTitles: []string{
 				"Бурение вертикальных скважин",
 				"Бурение горизонтальных скважин (ГС)",
@@ -206,6 +212,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО «Сургутнефтегаз»",
 			address:    "г. Сургут, ул. Григория Кукуевицкого, 1",
 			desc:       "Одна из крупнейших нефтяных компаний России с долей около 11 % добычи. Известна как наиболее технологически самодостаточная компания отрасли — содержит полный собственный сервисный блок: буровой, геофизический, строительный.",
+			regions:    []string{"ХМАО", "Республика Саха (Якутия)"},
 			svcTitles: []string{
 				"Бурение вертикальных скважин",
 				"Бурение наклонно-направленных скважин (ННС)",
@@ -224,6 +231,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО «Татнефть»",
 			address:    "г. Альметьевск, ул. Ленина, 75",
 			desc:       "Ключевая нефтяная компания Республики Татарстан. Разрабатывает Ромашкинское месторождение — одно из крупнейших в мире. Активно развивает нефтехимию (ТАНЕКО), глубокую переработку и производство шин (КАМА).",
+			regions:    []string{"Республика Татарстан", "Самарская область"},
 			svcTitles: []string{
 				"Бурение вертикальных скважин",
 				"Зарезка боковых стволов (ЗБС)",
@@ -241,6 +249,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО «НОВАТЭК»",
 			address:    "г. Москва, ул. Таганская, 17–23",
 			desc:       "Крупнейший независимый производитель природного газа в России. Специализируется на СПГ-проектах мирового масштаба: «Ямал СПГ» и «Арктик СПГ 2». Запасы газа — более 2,8 трлн м³.",
+			regions:    []string{"ЯНАО", "Красноярский край"},
 			svcTitles: []string{
 				"Проектирование СПГ-установок",
 				"Строительство СПГ-терминалов",
@@ -259,6 +268,7 @@ func Seed(db *gorm.DB) error {
 			name:       "ПАО АНК «Башнефть»",
 			address:    "г. Уфа, ул. Карла Маркса, 30",
 			desc:       "Крупная вертикально интегрированная нефтяная компания Башкортостана, входящая в структуру «Роснефти». Разрабатывает месторождения в Башкирии, Западной Сибири и Ненецком АО. Располагает тремя НПЗ в Уфе суммарной мощностью 24 млн т/год.",
+			regions:    []string{"Республика Башкортостан", "ХМАО", "НАО"},
 			svcTitles: []string{
 				"Бурение вертикальных скважин",
 				"Бурение наклонно-направленных скважин (ННС)",
@@ -290,11 +300,19 @@ func Seed(db *gorm.DB) error {
 			Name:        cd.name,
 			Address:     strPtr(cd.address),
 			Description: strPtr(cd.desc),
+			// Демо-компании сразу видны заказчикам.
+			VerificationStatus: models.CompanyVerificationOK,
 		}
 		if err := db.Create(&company).Error; err != nil {
 			return fmt.Errorf("создание компании %s: %w", cd.name, err)
 		}
 
+		for _, region := range cd.regions {
+			if err := db.Create(&models.CompanyRegion{CompanyID: company.CompanyID, Region: region}).Error; err != nil {
+				return fmt.Errorf("регион компании %s: %w", cd.name, err)
+			}
+		}
+
 		for _, t := range cd.svcTitles {
 			sid, ok := svcID[t]
 			if !ok {
@@ -385,6 +403,11 @@ func Seed(db *gorm.DB) error {
 		},
 	}
 
+	svcPrice := map[string]float64{}
+	for _, d := range svcDefs {
+		svcPrice[d.title] = d.price
+	}
+
 	for _, bd := range bookingDefs {
 		booking := models.Booking{
 			UserID:      intPtr(uid[bd.userEmail]),
@@ -395,20 +418,31 @@ func Seed(db *gorm.DB) error {
 			return fmt.Errorf("создание бронирования: %w", err)
 		}
 
+		lines := make([]models.BookingService, 0, len(bd.services))
 		for _, bs := range bd.services {
 			key := bs.company + "|" + bs.service
 			csID, ok := csKey[key]
 			if !ok {
 				return fmt.Errorf("company_service не найден: %s", key)
 			}
-			bsRec := models.BookingService{
+			lines = append(lines, models.BookingService{
 				BookingID:        booking.BookingID,
 				CompanyServiceID: csID,
-			}
-			if err := db.Create(&bsRec).Error; err != nil {
+				Status:           bd.status,
+				UnitPrice:        floatPtr(svcPrice[bs.service]),
+				Currency:         models.DefaultCurrency,
+			})
+		}
+
+		booking.ApplyTotals(lines)
+		for i := range lines {
+			if err := db.Create(&lines[i]).Error; err != nil {
 				return fmt.Errorf("создание booking_service: %w", err)
 			}
 		}
+		if err := db.Save(&booking).Error; err != nil {
+			return fmt.Errorf("итоги бронирования: %w", err)
+		}
 	}
 
 	fmt.Printf("Готово: %d услуг, %d компаний, %d бронирований\n",
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RemoveAvatars удаляет все файлы аватара пользователя: аватар сохраняется
+// как uploads/avatars/<user_id>.<ext>, и после смены формата старый файл
+// мог остаться.
+func RemoveAvatars(uploadsDir string, userID int64) error {
+	files, err := filepath.Glob(filepath.Join(uploadsDir, "avatars", fmt.Sprintf("%d.*", userID)))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret — ключ "12345678901234567890" из RFC 6238, приложение B, в base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Векторы RFC 6238 для HMAC-SHA1; в RFC коды из 8 цифр, здесь — их последние 6.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("Code(%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		at := time.Unix(v.unix, 0)
+		step, ok := Validate(rfcSecret, v.code, at, 0)
+		if !ok || step != Step(at) {
+			t.Errorf("Validate(%d) = %d, %v; want %d, true", v.unix, step, ok, Step(at))
+		}
+	}
+}
+
+func TestValidateReplay(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	code := "050471"
+
+	step, ok := Validate(rfcSecret, code, at, 0)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+	if _, ok := Validate(rfcSecret, code, at, step); ok {
+		t.Error("replayed code accepted")
+	}
+	// Код прошлого шага в пределах Skew принимается, но не после того, как
+	// был принят код этого же или более нового шага.
+	later := at.Add(Period)
+	if got, ok := Validate(rfcSecret, code, later, step-1); !ok || got != step {
+		t.Errorf("code within skew: got %d, %v", got, ok)
+	}
+	if _, ok := Validate(rfcSecret, code, later, step); ok {
+		t.Error("code within skew accepted after its step was used")
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+	}{
+		{"wrong code", "123456", at},
+		{"too short", "05047", at},
+		{"8-digit RFC code", "14050471", at},
+		{"outside skew", "050471", at.Add(time.Duration(Skew+1) * Period)},
+	}
+	for _, tt := range tests {
+		if _, ok := Validate(rfcSecret, tt.code, tt.at, 0); ok {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+	if _, ok := Validate(rfcSecret, " 050 471 ", at, 0); !ok {
+		t.Error("code with spaces rejected")
+	}
+	if _, ok := Validate("not base32!", "050471", at, 0); ok {
+		t.Error("invalid secret accepted")
+	}
+}
@@ -0,0 +1,56 @@
+import api from "./client";
+import type { APIKey, APIKeyScope } from "../types";
+
+export type CreateAPIKeyPayload = {
+    name: string;
+    scopes: APIKeyScope[];
+    expires_in_days?: number;
+};
+
+export type APIKeyFilter = {
+    user_id?: number;
+    company_id?: number;
+    active?: boolean;
+};
+
+export async function getMyAPIKeys(): Promise<APIKey[]> {
+    const res = await api.get("/auth/api-keys");
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function createMyAPIKey(payload: CreateAPIKeyPayload): Promise<APIKey> {
+    const res = await api.post("/auth/api-keys", payload);
+    return res.data;
+}
+
+export async function revokeMyAPIKey(id: number): Promise<void> {
+    await api.delete(`/auth/api-keys/${id}`);
+}
+
+export async function getCompanyAPIKeys(companyId: number): Promise<APIKey[]> {
+    const res = await api.get(`/companies/${companyId}/api-keys`);
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function createCompanyAPIKey(companyId: number, payload: CreateAPIKeyPayload): Promise<APIKey> {
+    const res = await api.post(`/companies/${companyId}/api-keys`, payload);
+    return res.data;
+}
+
+export async function revokeCompanyAPIKey(companyId: number, id: number): Promise<void> {
+    await api.delete(`/companies/${companyId}/api-keys/${id}`);
+}
+
+export async function getAllAPIKeys(filter: APIKeyFilter = {}): Promise<APIKey[]> {
+    const res = await api.get("/api-keys", { params: filter });
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function createUserAPIKey(userId: number, payload: CreateAPIKeyPayload): Promise<APIKey> {
+    const res = await api.post(`/users/${userId}/api-keys`, payload);
+    return res.data;
+}
+
+export async function revokeAPIKey(id: number): Promise<void> {
+    await api.delete(`/api-keys/${id}`);
+}
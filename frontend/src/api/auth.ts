@@ -1,16 +1,48 @@
-import api from "./client";
+import api, { clearSession, type AuthSession } from "./client";
 import type { Me } from "../types";
 
-export async function login(email: string, password: string): Promise<{ token: string; role: string }> {
+export type MFAChallenge = {
+    mfa_required: true;
+    enrollment_required: boolean;
+    mfa_token: string;
+    expires_in: number;
+};
+
+export type MFASetup = {
+    secret: string;
+    otpauth_uri: string;
+};
+
+export type MFAStatus = {
+    enabled: boolean;
+    required: boolean;
+    recovery_codes_left: number;
+    confirmed_at?: string;
+};
+
+export function isMFAChallenge(data: AuthSession | MFAChallenge): data is MFAChallenge {
+    return (data as MFAChallenge).mfa_required === true;
+}
+
+export async function login(email: string, password: string): Promise<AuthSession | MFAChallenge> {
     const res = await api.post("/auth/login", { email, password });
     return res.data;
 }
 
-export async function register(name: string, email: string, password: string): Promise<{ token: string; role: string }> {
+export async function register(name: string, email: string, password: string): Promise<AuthSession> {
     const res = await api.post("/auth/register", { name, email, password });
     return res.data;
 }
 
+export async function logout(): Promise<void> {
+    const refreshToken = localStorage.getItem("refreshToken");
+    try {
+        await api.post("/auth/logout", refreshToken ? { refresh_token: refreshToken } : undefined);
+    } finally {
+        clearSession();
+    }
+}
+
 export async function getMe(): Promise<Me> {
     const res = await api.get("/auth/me");
     return res.data;
@@ -32,3 +64,62 @@ export async function getMyStats(): Promise<{ total_bookings: number; active_boo
     const res = await api.get("/auth/me/stats");
     return res.data;
 }
+
+export async function changePassword(oldPassword: string, newPassword: string): Promise<AuthSession> {
+    const res = await api.post("/auth/password/change", { old_password: oldPassword, new_password: newPassword });
+    return res.data;
+}
+
+export async function forgotPassword(email: string): Promise<void> {
+    await api.post("/auth/password/forgot", { email });
+}
+
+export async function resetPassword(token: string, password: string): Promise<void> {
+    await api.post("/auth/password/reset", { token, password });
+}
+
+export async function verifyEmail(token: string): Promise<Me> {
+    const res = await api.post("/auth/email/verify", { token });
+    return res.data;
+}
+
+export async function resendEmailVerification(): Promise<void> {
+    await api.post("/auth/email/verification");
+}
+
+export async function loginMFA(
+    mfaToken: string,
+    second: { code?: string; recovery_code?: string },
+): Promise<AuthSession & { recovery_codes?: string[] }> {
+    const res = await api.post("/auth/login/2fa", { mfa_token: mfaToken, ...second });
+    return res.data;
+}
+
+export async function loginMFASetup(mfaToken: string): Promise<MFASetup> {
+    const res = await api.post("/auth/login/2fa/setup", { mfa_token: mfaToken });
+    return res.data;
+}
+
+export async function getMFAStatus(): Promise<MFAStatus> {
+    const res = await api.get("/auth/2fa");
+    return res.data;
+}
+
+export async function setupMFA(): Promise<MFASetup> {
+    const res = await api.post("/auth/2fa/setup");
+    return res.data;
+}
+
+export async function confirmMFA(code: string): Promise<{ recovery_codes: string[] }> {
+    const res = await api.post("/auth/2fa/confirm", { code });
+    return res.data;
+}
+
+export async function disableMFA(password: string, second: { code?: string; recovery_code?: string }): Promise<void> {
+    await api.post("/auth/2fa/disable", { password, ...second });
+}
+
+export async function regenerateRecoveryCodes(code: string): Promise<{ recovery_codes: string[] }> {
+    const res = await api.post("/auth/2fa/recovery-codes", { code });
+    return res.data;
+}
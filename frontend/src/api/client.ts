@@ -7,6 +7,12 @@ export const api = axios.create({
     baseURL: API_BASE_URL,
 });
 
+export type AuthSession = {
+    token: string;
+    refresh_token: string;
+    role: string;
+};
+
 export function setAuthToken(token: string | null) {
     if (token) {
         localStorage.setItem("authToken", token);
@@ -15,6 +21,18 @@ export function setAuthToken(token: string | null) {
     }
 }
 
+export function saveSession(session: AuthSession) {
+    localStorage.setItem("authToken", session.token);
+    localStorage.setItem("refreshToken", session.refresh_token);
+    localStorage.setItem("userRole", session.role);
+}
+
+export function clearSession() {
+    localStorage.removeItem("authToken");
+    localStorage.removeItem("refreshToken");
+    localStorage.removeItem("userRole");
+}
+
 api.interceptors.request.use((config) => {
     const token = localStorage.getItem("authToken");
     if (token) {
@@ -24,12 +42,41 @@ api.interceptors.request.use((config) => {
     return config;
 });
 
+// Один общий запрос обновления на все запросы, получившие 401 одновременно:
+// refresh-токен одноразовый, второй обмен отозвал бы всю цепочку.
+let refreshing: Promise<string | null> | null = null;
+
+function refreshAccessToken(): Promise<string | null> {
+    const refreshToken = localStorage.getItem("refreshToken");
+    if (!refreshToken) return Promise.resolve(null);
+    if (!refreshing) {
+        refreshing = axios
+            .post<AuthSession>(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+            .then((res) => {
+                saveSession(res.data);
+                return res.data.token;
+            })
+            .catch(() => null)
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+}
+
 api.interceptors.response.use(
     (response) => response,
-    (error) => {
-        if (error.response?.status === 401) {
-            localStorage.removeItem("authToken");
-            localStorage.removeItem("userRole");
+    async (error) => {
+        const original = error.config;
+        if (error.response?.status === 401 && original && !original._retry) {
+            original._retry = true;
+            const token = await refreshAccessToken();
+            if (token) {
+                original.headers = original.headers ?? {};
+                original.headers["Authorization"] = `Bearer ${token}`;
+                return api(original);
+            }
+            clearSession();
             window.location.href = "/login";
         }
         return Promise.reject(error);
@@ -1,5 +1,5 @@
 import api from "./client";
-import type { Company } from "../types";
+import type { Company, CompanyInvitation, CompanyMember, CompanyVerificationStatus, MemberRole } from "../types";
 
 export async function getMyCompanies(): Promise<Company[]> {
     const res = await api.get("/companies/my");
@@ -24,9 +24,62 @@ export async function deleteCompany(id: number): Promise<void> {
     await api.delete(`/companies/${id}`);
 }
 
+export async function submitCompanyForReview(id: number): Promise<Company> {
+    const res = await api.post(`/companies/${id}/verification`);
+    return res.data;
+}
+
+export async function getCompanyModerationQueue(status: CompanyVerificationStatus = "pending_review"): Promise<Company[]> {
+    const res = await api.get("/companies/moderation", { params: { status } });
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function reviewCompany(id: number, decision: "verified" | "rejected", notes?: string): Promise<Company> {
+    const res = await api.post(`/companies/${id}/review`, { decision, notes });
+    return res.data;
+}
+
 export async function uploadCompanyLogo(id: number, file: File): Promise<{ logo_url: string }> {
     const form = new FormData();
     form.append("file", file);
     const res = await api.post(`/upload/companies/${id}/logo`, form);
     return res.data;
 }
+
+export async function getCompanyMembers(id: number): Promise<CompanyMember[]> {
+    const res = await api.get(`/companies/${id}/members`);
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function updateCompanyMemberRole(id: number, userId: number, role: MemberRole): Promise<CompanyMember[]> {
+    const res = await api.patch(`/companies/${id}/members/${userId}`, { role });
+    return res.data;
+}
+
+export async function removeCompanyMember(id: number, userId: number): Promise<void> {
+    await api.delete(`/companies/${id}/members/${userId}`);
+}
+
+export async function transferCompanyOwnership(id: number, userId: number): Promise<CompanyMember[]> {
+    const res = await api.post(`/companies/${id}/owner`, { user_id: userId });
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function inviteCompanyMember(id: number, email: string, role: MemberRole): Promise<CompanyInvitation> {
+    const res = await api.post(`/companies/${id}/invitations`, { email, role });
+    return res.data;
+}
+
+export async function getCompanyInvitations(id: number): Promise<CompanyInvitation[]> {
+    const res = await api.get(`/companies/${id}/invitations`);
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function revokeCompanyInvitation(id: number, invitationId: number): Promise<void> {
+    await api.delete(`/companies/${id}/invitations/${invitationId}`);
+}
+
+export async function acceptInvitation(token: string): Promise<{ company_id: number; company_name: string; role: MemberRole }> {
+    const res = await api.post("/invitations/accept", { token });
+    return res.data;
+}
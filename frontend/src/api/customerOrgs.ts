@@ -0,0 +1,79 @@
+import api from "./client";
+import type { Booking, BookingApproval, CustomerOrg, OrgRole } from "../types";
+
+export type CustomerOrgInput = {
+    name?: string;
+    auto_approve_limit?: number;
+};
+
+export type CustomerOrgMemberInput = {
+    email?: string;
+    role: OrgRole;
+    approval_limit?: number | null;
+};
+
+export type CustomerOrgInvitation = {
+    invitation_id: number;
+    org_id: number;
+    email: string;
+    role: OrgRole;
+    approval_limit: number | null;
+    expires_at: string;
+    status: "pending" | "accepted" | "revoked" | "expired";
+};
+
+export type PendingApproval = Booking & { can_approve: boolean };
+
+export async function createCustomerOrg(data: CustomerOrgInput): Promise<CustomerOrg> {
+    const res = await api.post("/customer-orgs", data);
+    return res.data;
+}
+
+export async function getMyCustomerOrg(): Promise<CustomerOrg> {
+    const res = await api.get("/customer-orgs/my");
+    return res.data;
+}
+
+export async function updateCustomerOrg(id: number, data: CustomerOrgInput): Promise<CustomerOrg> {
+    const res = await api.put(`/customer-orgs/${id}`, data);
+    return res.data;
+}
+
+export async function inviteCustomerOrgMember(id: number, data: CustomerOrgMemberInput): Promise<CustomerOrgInvitation> {
+    const res = await api.post(`/customer-orgs/${id}/members`, data);
+    return res.data;
+}
+
+export async function acceptCustomerOrgInvitation(token: string): Promise<CustomerOrg> {
+    const res = await api.post("/customer-orgs/invitations/accept", { token });
+    return res.data;
+}
+
+export async function updateCustomerOrgMember(id: number, userId: number, data: CustomerOrgMemberInput): Promise<CustomerOrg> {
+    const res = await api.patch(`/customer-orgs/${id}/members/${userId}`, data);
+    return res.data;
+}
+
+export async function removeCustomerOrgMember(id: number, userId: number): Promise<void> {
+    await api.delete(`/customer-orgs/${id}/members/${userId}`);
+}
+
+export async function getPendingApprovals(id: number): Promise<PendingApproval[]> {
+    const res = await api.get(`/customer-orgs/${id}/approvals`);
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function getBookingApprovals(bookingId: number): Promise<BookingApproval[]> {
+    const res = await api.get(`/bookings/${bookingId}/approvals`);
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function decideBookingApproval(
+    bookingId: number,
+    decision: "approve" | "reject",
+    comment?: string,
+    waitlist?: boolean,
+): Promise<Booking> {
+    const res = await api.post(`/bookings/${bookingId}/approval`, { decision, comment, waitlist });
+    return res.data;
+}
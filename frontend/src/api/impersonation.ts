@@ -0,0 +1,34 @@
+import api from "./client";
+import type { ImpersonationRequest, ImpersonationSession } from "../types";
+
+export type ImpersonationStart = {
+    session: ImpersonationSession;
+    // Токен только для чтения; refresh-токена нет.
+    token: string;
+    expires_in: number;
+};
+
+export type ImpersonationFilter = {
+    admin_id?: number;
+    user_id?: number;
+    active?: boolean;
+};
+
+export async function impersonateUser(userId: number, reason: string): Promise<ImpersonationStart> {
+    const res = await api.post(`/users/${userId}/impersonate`, { reason });
+    return res.data;
+}
+
+export async function endImpersonation(sessionId: number): Promise<void> {
+    await api.delete(`/impersonations/${sessionId}`);
+}
+
+export async function getImpersonations(filter: ImpersonationFilter = {}): Promise<ImpersonationSession[]> {
+    const res = await api.get("/impersonations", { params: filter });
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function getImpersonationRequests(sessionId: number): Promise<ImpersonationRequest[]> {
+    const res = await api.get(`/impersonations/${sessionId}/requests`);
+    return Array.isArray(res.data) ? res.data : [];
+}
@@ -0,0 +1,28 @@
+import api from "./client";
+import type { ErasureRequest, ErasureStatus } from "../types";
+
+// Выгрузка персональных данных: zip — data.json и аватар, json — только данные.
+export async function exportMyData(format: "zip" | "json" = "zip"): Promise<Blob> {
+    const res = await api.get("/auth/me/export", { params: { format }, responseType: "blob" });
+    return res.data;
+}
+
+// Ожидающий запрос на удаление данных; 404 — запроса нет.
+export async function getMyErasureRequest(): Promise<ErasureRequest> {
+    const res = await api.get("/auth/me/erasure");
+    return res.data;
+}
+
+export async function requestErasure(password: string): Promise<ErasureRequest> {
+    const res = await api.post("/auth/me/erasure", { password });
+    return res.data;
+}
+
+export async function cancelErasure(): Promise<void> {
+    await api.delete("/auth/me/erasure");
+}
+
+export async function getErasureRequests(status?: ErasureStatus): Promise<ErasureRequest[]> {
+    const res = await api.get("/privacy/erasure-requests", { params: status ? { status } : {} });
+    return Array.isArray(res.data) ? res.data : [];
+}
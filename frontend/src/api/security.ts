@@ -0,0 +1,26 @@
+import api from "./client";
+import type { LockedAccount, SecurityEvent } from "../types";
+
+export type SecurityEventFilter = {
+    type?: string;
+    user_id?: number;
+    email?: string;
+    ip?: string;
+    since?: string;
+    until?: string;
+    limit?: number;
+};
+
+export async function getLockedAccounts(): Promise<LockedAccount[]> {
+    const res = await api.get("/security/locks");
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function unlockAccount(id: number): Promise<void> {
+    await api.delete(`/security/locks/${id}`);
+}
+
+export async function getSecurityEvents(filter: SecurityEventFilter = {}): Promise<SecurityEvent[]> {
+    const res = await api.get("/security/events", { params: filter });
+    return Array.isArray(res.data) ? res.data : [];
+}
@@ -4,16 +4,44 @@ export type ServiceRequestResponse = {
     response_id: number;
     request_id: number;
     company_id: number;
+    company_service_id?: number | null;
+    price?: number | null;
+    currency?: string;
+    lead_time_days?: number | null;
+    comment?: string | null;
+    status?: "submitted" | "award_pending" | "awarded" | "lost";
     created_at: string;
     company?: { CompanyID: number; Name: string };
 };
 
+export type ServiceRequestBid = ServiceRequestResponse & {
+    company_name: string;
+    total: number | null;
+};
+
+export type ServiceRequestBidInput = {
+    price?: number;
+    currency?: string;
+    lead_time_days?: number;
+    comment?: string;
+};
+
 export type ServiceRequest = {
     request_id: number;
     user_id: number;
     service_name: string;
+    category?: string | null;
+    region?: string | null;
     comment: string | null;
-    status: "pending" | "reviewed";
+    scope?: string | null;
+    quantity?: number;
+    site_address?: string | null;
+    site_field?: string | null;
+    site_pad?: string | null;
+    site_well?: string | null;
+    deadline?: string | null;
+    status: "pending" | "reviewed" | "award_pending" | "awarded";
+    awarded_booking_id?: number | null;
     created_at: string;
     user?: { Name: string; Email?: string | null };
     responses?: ServiceRequestResponse[];
@@ -36,11 +64,46 @@ export async function updateServiceRequestStatus(id: number, status: "pending" |
     await api.put(`/service-requests/${id}/status`, { status });
 }
 
-export async function notifyCompanies(id: number): Promise<{ notified: number }> {
-    const res = await api.post(`/service-requests/${id}/notify-companies`);
+export type CompanyMatch = {
+    company_id: number;
+    company_name: string;
+    owner_user_id: number;
+    score: number;
+    catalog_score: number;
+    category_score: number;
+    region_score: number;
+    responsiveness_score: number;
+    invited: number;
+    responded: number;
+    already_invited: boolean;
+};
+
+export async function previewMatches(id: number, limit?: number): Promise<{ category: string; limit: number; candidates: number; would_notify: CompanyMatch[] }> {
+    const res = await api.get(`/service-requests/${id}/matches`, { params: limit ? { limit } : undefined });
+    return res.data;
+}
+
+export async function notifyCompanies(id: number, limit?: number): Promise<{ notified: number; companies: CompanyMatch[] }> {
+    const res = await api.post(`/service-requests/${id}/notify-companies`, undefined, { params: limit ? { limit } : undefined });
     return res.data;
 }
 
-export async function respondToServiceRequest(id: number, companyId: number): Promise<void> {
-    await api.post(`/service-requests/${id}/respond`, { company_id: companyId });
+export async function respondToServiceRequest(id: number, companyId: number, bid: ServiceRequestBidInput = {}): Promise<ServiceRequestResponse> {
+    const res = await api.post(`/service-requests/${id}/respond`, { company_id: companyId, ...bid });
+    return res.data;
+}
+
+export async function getMyServiceRequests(): Promise<ServiceRequest[]> {
+    const res = await api.get("/service-requests/my");
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function getServiceRequestBids(id: number): Promise<{ request: ServiceRequest; bids: ServiceRequestBid[] }> {
+    const res = await api.get(`/service-requests/${id}/bids`);
+    return res.data;
+}
+
+export async function awardServiceRequest(id: number, responseId: number): Promise<{ BookingID: number }> {
+    const res = await api.post(`/service-requests/${id}/award`, { response_id: responseId });
+    return res.data;
 }
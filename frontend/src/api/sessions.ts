@@ -0,0 +1,31 @@
+import api from "./client";
+import type { UserSession } from "../types";
+
+export async function getMySessions(): Promise<UserSession[]> {
+    const res = await api.get("/auth/sessions");
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function revokeMySession(id: number): Promise<void> {
+    await api.delete(`/auth/sessions/${id}`);
+}
+
+// «Выйти везде»; keepCurrent оставляет текущий сеанс.
+export async function revokeAllMySessions(keepCurrent = false): Promise<number> {
+    const res = await api.delete("/auth/sessions", { params: keepCurrent ? { keep_current: true } : {} });
+    return res.data?.revoked ?? 0;
+}
+
+export async function getUserSessions(userId: number, all = false): Promise<UserSession[]> {
+    const res = await api.get(`/users/${userId}/sessions`, { params: all ? { all: true } : {} });
+    return Array.isArray(res.data) ? res.data : [];
+}
+
+export async function revokeUserSession(userId: number, sessionId: number): Promise<void> {
+    await api.delete(`/users/${userId}/sessions/${sessionId}`);
+}
+
+export async function revokeAllUserSessions(userId: number): Promise<number> {
+    const res = await api.delete(`/users/${userId}/sessions`);
+    return res.data?.revoked ?? 0;
+}
@@ -9,3 +9,20 @@ export async function getAllUsers(): Promise<User[]> {
 export async function deleteUser(id: number): Promise<void> {
     await api.delete(`/users/${id}`);
 }
+
+export async function resetUserMFA(id: number, reason?: string): Promise<void> {
+    await api.delete(`/users/${id}/2fa`, { data: reason ? { reason } : undefined });
+}
+
+export async function updateUserRole(id: number, role: string): Promise<User> {
+    const res = await api.patch(`/users/${id}/role`, { role });
+    return res.data;
+}
+
+export async function suspendUser(id: number, reason?: string): Promise<void> {
+    await api.post(`/users/${id}/suspend`, reason ? { reason } : {});
+}
+
+export async function reactivateUser(id: number): Promise<void> {
+    await api.post(`/users/${id}/reactivate`);
+}
@@ -3,6 +3,7 @@ import { useEffect, useState } from "react";
 import { useUser } from "../context/UserContext";
 import { BASE_URL } from "../api/client";
 import { getUnreadCount } from "../api/notifications";
+import { logout } from "../api/auth";
 
 function Navbar() {
     const navigate = useNavigate();
@@ -10,9 +11,7 @@ function Navbar() {
     const { me, avatarVersion } = useUser();
 
     function handleLogout() {
-        localStorage.removeItem("authToken");
-        localStorage.removeItem("userRole");
-        navigate("/login");
+        logout().catch(() => {}).finally(() => navigate("/login"));
     }
 
     const [unreadCount, setUnreadCount] = useState(0);
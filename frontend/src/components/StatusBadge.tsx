@@ -24,10 +24,16 @@ function StatusBadge({ status }: Props) {
         if (s === "requested") {
             return { ...base, background: "#e8f0fe", color: "#1a56db" };
         }
-        if (s === "approved") {
+        if (s === "waitlisted") {
+            return { ...base, background: "#f3e8fd", color: "#7b1fa2" };
+        }
+        if (s === "approved" || s === "partially_approved") {
             return { ...base, background: "#e6f4ea", color: "#1e7e34" };
         }
-        if (s === "completed") {
+        if (s === "in_progress") {
+            return { ...base, background: "#fef7e0", color: "#b06000" };
+        }
+        if (s === "completed" || s === "partially_completed") {
             return { ...base, background: "#f1f3f4", color: "#5f6368" };
         }
         if (s === "rejected") {
@@ -69,7 +69,7 @@ export function useCompanyBookings() {
         let list = bookings;
 
         if (statusFilter !== "all") {
-            list = list.filter((b) => b.Status === statusFilter);
+            list = list.filter((b) => (b.CompanyStatus ?? b.Status) === statusFilter);
         }
 
         if (serviceFilter) {
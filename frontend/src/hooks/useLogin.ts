@@ -1,14 +1,25 @@
 import { useState } from "react";
 import { useNavigate } from "react-router-dom";
-import { login } from "../api/auth";
+import { isMFAChallenge, login, loginMFA, loginMFASetup, type MFAChallenge, type MFASetup } from "../api/auth";
 import { useUser } from "../context/UserContext";
+import { saveSession, type AuthSession } from "../api/client";
 
 export function useLogin() {
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState<string | null>(null);
+    const [challenge, setChallenge] = useState<MFAChallenge | null>(null);
+    const [setup, setSetup] = useState<MFASetup | null>(null);
+    const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+    const [pending, setPending] = useState<AuthSession | null>(null);
     const navigate = useNavigate();
     const { refresh } = useUser();
 
+    function finish(session: AuthSession) {
+        saveSession(session);
+        refresh();
+        navigate("/search");
+    }
+
     async function handleLogin(email: string, password: string) {
         if (!email.trim() || !password.trim()) {
             setError("Заполните все поля");
@@ -18,10 +29,14 @@ export function useLogin() {
             setLoading(true);
             setError(null);
             const data = await login(email.trim(), password);
-            localStorage.setItem("authToken", data.token);
-            localStorage.setItem("userRole", data.role);
-            refresh();
-            navigate("/search");
+            if (!isMFAChallenge(data)) {
+                finish(data);
+                return;
+            }
+            setChallenge(data);
+            if (data.enrollment_required) {
+                setSetup(await loginMFASetup(data.mfa_token));
+            }
         } catch {
             setError("Неверный email или пароль");
         } finally {
@@ -29,5 +44,32 @@ export function useLogin() {
         }
     }
 
-    return { loading, error, handleLogin };
+    // handleCode — второй шаг входа: код из приложения или резервный код.
+    async function handleCode(code: string, useRecovery: boolean) {
+        if (!challenge || !code.trim()) {
+            setError("Введите код");
+            return;
+        }
+        try {
+            setLoading(true);
+            setError(null);
+            const data = await loginMFA(challenge.mfa_token, useRecovery ? { recovery_code: code.trim() } : { code: code.trim() });
+            if (data.recovery_codes?.length) {
+                setRecoveryCodes(data.recovery_codes);
+                setPending(data);
+                return;
+            }
+            finish(data);
+        } catch {
+            setError("Неверный код");
+        } finally {
+            setLoading(false);
+        }
+    }
+
+    function acknowledgeRecoveryCodes() {
+        if (pending) finish(pending);
+    }
+
+    return { loading, error, challenge, setup, recoveryCodes, handleLogin, handleCode, acknowledgeRecoveryCodes };
 }
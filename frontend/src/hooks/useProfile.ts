@@ -1,6 +1,6 @@
 import { useEffect, useRef, useState } from "react";
 import { useNavigate } from "react-router-dom";
-import { uploadAvatar, updateMe, getMyStats } from "../api/auth";
+import { uploadAvatar, updateMe, getMyStats, logout } from "../api/auth";
 import { useUser } from "../context/UserContext";
 
 type Stats = { total_bookings: number; active_bookings: number; completed_bookings: number };
@@ -24,9 +24,7 @@ export function useProfile() {
     }, []);
 
     function handleLogout() {
-        localStorage.removeItem("authToken");
-        localStorage.removeItem("userRole");
-        navigate("/login");
+        logout().catch(() => {}).finally(() => navigate("/login"));
     }
 
     function startEditing() {
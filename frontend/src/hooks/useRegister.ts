@@ -1,6 +1,7 @@
 import { useState } from "react";
 import { useNavigate } from "react-router-dom";
 import { register } from "../api/auth";
+import { saveSession } from "../api/client";
 
 export function useRegister() {
     const [loading, setLoading] = useState(false);
@@ -18,8 +19,7 @@ export function useRegister() {
             setLoading(true);
             setStatus(null);
             const data = await register(name.trim(), email.trim(), password.trim());
-            localStorage.setItem("authToken", data.token);
-            localStorage.setItem("userRole", data.role);
+            saveSession(data);
             setStatus("Регистрация успешна!");
             setIsError(false);
             setTimeout(() => navigate("/search"), 1000);
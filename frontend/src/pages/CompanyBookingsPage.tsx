@@ -7,7 +7,9 @@ const pageStyle: React.CSSProperties = { maxWidth: 1040, margin: "0 auto", paddi
 
 const NEXT_STATUSES: Record<string, string[]> = {
     requested: ["approved", "rejected"],
-    approved:  ["completed", "rejected"],
+    waitlisted: ["requested", "rejected"],
+    approved:  ["in_progress", "rejected"],
+    in_progress: ["completed"],
     completed: [],
     rejected:  [],
     cancelled: [],
@@ -15,6 +17,7 @@ const NEXT_STATUSES: Record<string, string[]> = {
 
 const ACTION_STYLE: Record<string, React.CSSProperties> = {
     approved:  { background: "#e6f4ea", color: "#1e7e34", border: "1px solid #b7dfbf" },
+    in_progress: { background: "#fef7e0", color: "#b06000", border: "1px solid #f9dfa0" },
     completed: { background: "#000",    color: "#fff",    border: "1px solid #000" },
     rejected:  { background: "#fce8e6", color: "#c0392b", border: "1px solid #f5c0bb" },
 };
@@ -90,7 +93,7 @@ function CompanyBookingsPage() {
                             {s === "all" ? "Все" : BOOKING_STATUS_LABELS[s]}
                             {s !== "all" && (
                                 <span style={{ marginLeft: 5, opacity: 0.6 }}>
-                                    {bookings.filter((b) => b.Status === s).length}
+                                    {bookings.filter((b) => (b.CompanyStatus ?? b.Status) === s).length}
                                 </span>
                             )}
                         </button>
@@ -176,7 +179,8 @@ function CompanyBookingsPage() {
             ) : (
                 <div style={{ display: "flex", flexDirection: "column", gap: 12 }}>
                     {filtered.map((b) => {
-                        const nextStatuses = NEXT_STATUSES[b.Status] ?? [];
+                        const companyStatus = b.CompanyStatus ?? b.Status;
+                        const nextStatuses = NEXT_STATUSES[companyStatus] ?? [];
                         const companiesMap = new Map<number, { CompanyID: number; Name: string; logo_url?: string | null }>();
                         (b.BookingServices ?? []).forEach(bs => {
                             const c = bs.CompanyService?.Company;
@@ -192,7 +196,7 @@ function CompanyBookingsPage() {
                                 onMouseLeave={e => (e.currentTarget.style.boxShadow = "none")}
                             >
                                 <div style={{ padding: "14px 20px", display: "flex", alignItems: "center", justifyContent: "space-between", borderBottom: "1px solid #f4f4f4" }}>
-                                    <StatusBadge status={b.Status} />
+                                    <StatusBadge status={companyStatus} />
                                     {b.CreatedAt && (
                                         <span style={{ fontSize: 12, color: "#bbb" }}>
                                             {formatDate(b.CreatedAt)}
@@ -5,11 +5,17 @@ import { useLogin } from "../hooks/useLogin";
 function LoginPage() {
     const [email, setEmail] = useState("");
     const [password, setPassword] = useState("");
-    const { loading, error, handleLogin } = useLogin();
+    const [code, setCode] = useState("");
+    const [useRecovery, setUseRecovery] = useState(false);
+    const { loading, error, challenge, setup, recoveryCodes, handleLogin, handleCode, acknowledgeRecoveryCodes } = useLogin();
 
     function onSubmit(e: React.FormEvent) {
         e.preventDefault();
-        handleLogin(email, password);
+        if (challenge) {
+            handleCode(code, useRecovery);
+        } else {
+            handleLogin(email, password);
+        }
     }
 
     return (
@@ -38,37 +44,80 @@ function LoginPage() {
                         <p>OilGas Booking</p>
                     </div>
 
-                    <form onSubmit={onSubmit} className="auth-form">
-                        <div className="form-group">
-                            <label>Email</label>
-                            <input
-                                type="email"
-                                value={email}
-                                onChange={(e) => setEmail(e.target.value)}
-                                placeholder="your@email.com"
-                                disabled={loading}
-                                required
-                            />
+                    {recoveryCodes ? (
+                        <div className="auth-form">
+                            <p>Двухфакторная аутентификация включена. Сохраните резервные коды — они понадобятся, если телефон будет недоступен. Каждый код действует один раз.</p>
+                            <pre>{recoveryCodes.join("\n")}</pre>
+                            <button type="button" onClick={acknowledgeRecoveryCodes} className="submit-btn">
+                                Я сохранил коды
+                            </button>
                         </div>
+                    ) : (
+                        <form onSubmit={onSubmit} className="auth-form">
+                            {!challenge && (
+                                <>
+                                    <div className="form-group">
+                                        <label>Email</label>
+                                        <input
+                                            type="email"
+                                            value={email}
+                                            onChange={(e) => setEmail(e.target.value)}
+                                            placeholder="your@email.com"
+                                            disabled={loading}
+                                            required
+                                        />
+                                    </div>
 
-                        <div className="form-group">
-                            <label>Пароль</label>
-                            <input
-                                type="password"
-                                value={password}
-                                onChange={(e) => setPassword(e.target.value)}
-                                placeholder="••••••••"
-                                disabled={loading}
-                                required
-                            />
-                        </div>
+                                    <div className="form-group">
+                                        <label>Пароль</label>
+                                        <input
+                                            type="password"
+                                            value={password}
+                                            onChange={(e) => setPassword(e.target.value)}
+                                            placeholder="••••••••"
+                                            disabled={loading}
+                                            required
+                                        />
+                                    </div>
+                                </>
+                            )}
+
+                            {challenge && setup && (
+                                <div className="form-group">
+                                    <p>Для вашей роли обязательна двухфакторная аутентификация. Добавьте ключ в приложение-аутентификатор и введите код из него.</p>
+                                    <label>Ключ</label>
+                                    <input type="text" value={setup.secret} readOnly />
+                                    <a href={setup.otpauth_uri}>Открыть в приложении</a>
+                                </div>
+                            )}
+
+                            {challenge && (
+                                <div className="form-group">
+                                    <label>{useRecovery ? "Резервный код" : "Код из приложения"}</label>
+                                    <input
+                                        type="text"
+                                        value={code}
+                                        onChange={(e) => setCode(e.target.value)}
+                                        placeholder={useRecovery ? "xxxxx-xxxxx" : "123456"}
+                                        autoComplete="one-time-code"
+                                        disabled={loading}
+                                        required
+                                    />
+                                    {!challenge.enrollment_required && (
+                                        <button type="button" className="link-btn" onClick={() => setUseRecovery(!useRecovery)}>
+                                            {useRecovery ? "Ввести код из приложения" : "Использовать резервный код"}
+                                        </button>
+                                    )}
+                                </div>
+                            )}
 
-                        {error && <div className="error-message">{error}</div>}
+                            {error && <div className="error-message">{error}</div>}
 
-                        <button type="submit" disabled={loading} className="submit-btn">
-                            {loading ? "Вход..." : "Войти"}
-                        </button>
-                    </form>
+                            <button type="submit" disabled={loading} className="submit-btn">
+                                {loading ? "Вход..." : challenge ? "Подтвердить" : "Войти"}
+                            </button>
+                        </form>
+                    )}
 
                     <div className="auth-footer">
                         <p>Нет аккаунта? <Link to="/register">Зарегистрироваться</Link></p>
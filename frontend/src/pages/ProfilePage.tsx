@@ -1,5 +1,6 @@
 import { useProfile } from "../hooks/useProfile";
 import { BASE_URL } from "../api/client";
+import { ROLE_LABELS } from "../types";
 
 function ProfilePage() {
     const {
@@ -45,7 +46,7 @@ function ProfilePage() {
 
                 <div style={{ flex: 1 }}>
                     <div style={{ fontSize: 11, fontWeight: 600, color: "#999", textTransform: "uppercase", letterSpacing: "1.2px", marginBottom: 6 }}>
-                        {me?.role === "admin" ? "Администратор" : "Пользователь"}
+                        {(me && ROLE_LABELS[me.role]) ?? "Пользователь"}
                     </div>
 
                     {editing ? (
@@ -42,7 +42,7 @@ function ServicesPage() {
             await loadServices();
         } catch (err: any) {
             console.log("CREATE SERVICE ERROR", err.response?.status, err.response?.data);
-            alert("Ошибка создания услуги (нужно быть админом)");
+            alert("Ошибка создания услуги (нужна роль владельца компании или администратора)");
         } finally {
             setCreating(false);
         }
@@ -56,7 +56,7 @@ function ServicesPage() {
             <h1>Услуги</h1>
 
             <form onSubmit={handleCreate} style={{ marginBottom: 20 }}>
-                <h3>Добавить услугу (для админа)</h3>
+                <h3>Добавить услугу</h3>
                 <input
                     type="text"
                     placeholder="Название услуги"
@@ -1,7 +1,22 @@
+export type CompanyVerificationStatus = "draft" | "pending_review" | "verified" | "rejected";
+
+export const COMPANY_VERIFICATION_LABELS: Record<CompanyVerificationStatus, string> = {
+    draft: "Черновик",
+    pending_review: "На проверке",
+    verified: "Проверена",
+    rejected: "Отклонена",
+};
+
 export type Company = {
     CompanyID: number;
     Name: string;
     logo_url?: string | null;
+    verification_status?: CompanyVerificationStatus;
+    // Комментарий модератора — только для сотрудников компании и персонала.
+    review_notes?: string;
+    submitted_at?: string;
+    reviewed_at?: string;
+    reviewed_by_user_id?: number;
 };
 
 export type Service = {
@@ -21,7 +36,19 @@ export type CompanyService = {
 export type Booking = {
     BookingID: number;
     Status: string;
+    CompanyStatus?: string;
     Description?: string | null;
+    ScheduledStart?: string | null;
+    ScheduledEnd?: string | null;
+    SiteAddress?: string | null;
+    SiteField?: string | null;
+    SitePad?: string | null;
+    SiteWell?: string | null;
+    SiteLatitude?: number | null;
+    SiteLongitude?: number | null;
+    SiteContactName?: string | null;
+    SiteContactPhone?: string | null;
+    OrgID?: number | null;
     CreatedAt?: string;
     User?: User | null;
     BookingServices?: BookingService[];
@@ -32,14 +59,100 @@ export type User = {
     Name: string;
     Email?: string | null;
     Role: string;
+    SuspendedAt?: string | null;
+    SuspensionReason?: string | null;
+    DeactivatedAt?: string | null;
 };
 
 export type Me = {
     id: number;
     name: string;
     email: string | null;
+    email_verified?: boolean;
+    pending_email?: string;
     role: string;
+    permissions?: string[];
     avatar_url?: string | null;
+    // Администратор, вошедший от имени пользователя (только чтение).
+    impersonated_by?: number;
+};
+
+export const ROLE_LABELS: Record<string, string> = {
+    customer: "Заказчик",
+    company_owner: "Владелец компании",
+    company_dispatcher: "Диспетчер компании",
+    admin: "Администратор",
+    auditor: "Аудитор",
+};
+
+export const MEMBER_ROLES = ["owner", "manager", "dispatcher", "viewer"] as const;
+export type MemberRole = typeof MEMBER_ROLES[number];
+
+export const MEMBER_ROLE_LABELS: Record<string, string> = {
+    owner: "Владелец",
+    manager: "Менеджер",
+    dispatcher: "Диспетчер",
+    viewer: "Наблюдатель",
+};
+
+export type CompanyMember = {
+    company_id: number;
+    user_id: number;
+    role: MemberRole;
+    invited_by_user_id?: number;
+    created_at: string;
+    name: string;
+    email: string | null;
+    primary_owner: boolean;
+};
+
+export type CompanyInvitation = {
+    invitation_id: number;
+    company_id: number;
+    email: string;
+    role: MemberRole;
+    expires_at: string;
+    invited_by_user_id: number;
+    accepted_at?: string;
+    accepted_by_user_id?: number;
+    revoked_at?: string;
+    created_at: string;
+    status: "pending" | "accepted" | "revoked" | "expired";
+};
+
+export type OrgRole = "admin" | "manager" | "engineer";
+
+export type CustomerOrgMember = {
+    org_id: number;
+    user_id: number;
+    role: OrgRole;
+    approval_limit: number | null;
+    created_at: string;
+    name: string;
+    email: string | null;
+};
+
+export type CustomerOrg = {
+    org_id: number;
+    name: string;
+    auto_approve_limit: number;
+    created_by_user_id: number;
+    created_at: string;
+    updated_at: string;
+    my_role: OrgRole;
+    my_approval_limit: number | null;
+    members: CustomerOrgMember[];
+};
+
+export type BookingApproval = {
+    approval_id: number;
+    booking_id: number;
+    org_id: number;
+    approver_user_id: number;
+    decision: "approved" | "escalated" | "rejected";
+    total: number | null;
+    comment?: string;
+    created_at: string;
 };
 
 export type ActiveUser = {
@@ -63,19 +176,120 @@ export type BookingService = {
     CompanyServiceID: number;
     Notes?: string | null;
     Quantity?: number | null;
+    Status?: string;
     CompanyService?: {
         Company?: { CompanyID: number; Name: string; logo_url?: string | null };
         Service?: { ServiceID: number; Title: string };
     };
 };
 
-export const BOOKING_STATUSES = ["requested", "approved", "completed", "rejected", "cancelled"] as const;
+export const BOOKING_STATUSES = ["requested", "waitlisted", "approved", "in_progress", "completed", "rejected", "cancelled"] as const;
 export type BookingStatus = typeof BOOKING_STATUSES[number];
 
 export const BOOKING_STATUS_LABELS: Record<string, string> = {
     requested: "Заявка",
+    waitlisted: "Лист ожидания",
     approved:  "Подтверждено",
+    in_progress: "В работе",
     completed: "Выполнено",
     rejected:  "Отказ",
     cancelled: "Отменено клиентом",
+    partially_approved: "Частично подтверждено",
+    partially_completed: "Частично выполнено",
+    pending_internal_approval: "Ждёт внутреннего согласования",
+};
+
+export type LockedAccount = {
+    counter_id: number;
+    scope: "account";
+    key: string;
+    failures: number;
+    last_failure_at: string;
+    blocked_until?: string;
+    locked_until: string;
+    updated_at: string;
+    user_id: number | null;
+    name: string | null;
+};
+
+export type SecurityEvent = {
+    event_id: number;
+    type: string;
+    user_id?: number;
+    email?: string;
+    ip: string;
+    user_agent?: string;
+    actor_user_id?: number;
+    detail?: string;
+    created_at: string;
+};
+
+export type APIKeyScope = "bookings:read" | "bookings:write" | "catalog:write";
+
+export type APIKey = {
+    key_id: number;
+    user_id: number;
+    company_id?: number;
+    name: string;
+    prefix: string;
+    scopes: APIKeyScope[];
+    status: "active" | "revoked" | "expired";
+    expires_at: string;
+    last_used_at?: string;
+    last_used_ip?: string;
+    created_by_user_id: number;
+    revoked_at?: string;
+    created_at: string;
+    // Полный ключ — только в ответе на создание.
+    key?: string;
+};
+
+export type ErasureStatus = "pending" | "completed" | "cancelled";
+
+export type ErasureRequest = {
+    request_id: number;
+    user_id: number;
+    status: ErasureStatus;
+    scheduled_for: string;
+    last_error?: string;
+    completed_at?: string;
+    cancelled_at?: string;
+    created_at: string;
+};
+
+export type ImpersonationSession = {
+    session_id: number;
+    admin_user_id: number;
+    user_id: number;
+    reason: string;
+    expires_at: string;
+    ended_at?: string;
+    created_at: string;
+};
+
+export type ImpersonationRequest = {
+    request_id: number;
+    session_id?: number;
+    admin_user_id: number;
+    user_id: number;
+    method: string;
+    path: string;
+    status?: number;
+    blocked: boolean;
+    ip: string;
+    created_at: string;
+};
+
+export type UserSession = {
+    session_id: number;
+    user_id: number;
+    user_agent: string;
+    ip: string;
+    last_seen_at: string;
+    last_seen_ip: string;
+    expires_at: string;
+    revoked_at?: string;
+    created_at: string;
+    // Сеанс, из которого сделан запрос.
+    current: boolean;
 };